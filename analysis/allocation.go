@@ -0,0 +1,434 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// 分组中现金和证券主数据里没有的持仓使用的名称
+const (
+	ExposureCash         = "cash"
+	ExposureUnclassified = "unclassified"
+)
+
+// TargetWeight 一个标的的目标权重（占净资产的百分比），对应配置中的 [[allocation.targets]]
+type TargetWeight struct {
+	Symbol string  `mapstructure:"symbol" json:"symbol"`
+	Weight float64 `mapstructure:"weight" json:"weight"`
+}
+
+// AllocationConfig 资产配置的目标权重和调仓约束，对应配置中的 [allocation]
+type AllocationConfig struct {
+	MinTrade float64        `mapstructure:"min_trade"` // 单笔调仓的最小金额（基础货币），更小的偏离不交易
+	Targets  []TargetWeight `mapstructure:"targets"`
+}
+
+// Exposure 一个分组的市值和权重（占净资产的百分比）。
+// 目标权重为组内各标的目标之和，未设目标的标的按当前权重计（不调仓），现金为剩余部分
+type Exposure struct {
+	Name         string  `json:"name"`
+	Value        float64 `json:"value"`
+	Weight       float64 `json:"weight"`
+	TargetWeight float64 `json:"target_weight"`
+	Drift        float64 `json:"drift"` // Weight - TargetWeight
+}
+
+// RebalanceTrade 调仓计划中的一个标的，Quantity 正为买入、负为卖出、0 为偏离不足 min_trade 或现金不足
+type RebalanceTrade struct {
+	Symbol       string  `json:"symbol"`
+	Currency     string  `json:"currency"`
+	Price        float64 `json:"price"` // 交易币种，持有的为 markPrice，否则为最近成交价
+	Quantity     float64 `json:"quantity"`
+	Value        float64 `json:"value"` // 基础货币，正为买入
+	Weight       float64 `json:"weight"`
+	TargetWeight float64 `json:"target_weight"`
+	NewWeight    float64 `json:"new_weight"`
+}
+
+type AllocationReport struct {
+	AsOf           string           `json:"as_of"`
+	NetLiquidation float64          `json:"net_liquidation"` // 持仓市值 + 现金，权重的分母
+	Cash           float64          `json:"cash"`
+	ByCategory     []Exposure       `json:"by_asset_category"`
+	ByCurrency     []Exposure       `json:"by_currency"`
+	BySector       []Exposure       `json:"by_sector"`  // 未配置证券主数据时为空
+	ByCountry      []Exposure       `json:"by_country"` // 同上
+	ByName         []Exposure       `json:"by_name"`    // 期权、期货归入标的
+	Unclassified   []string         `json:"unclassified"`
+	HasTargets     bool             `json:"has_targets"`
+	Rebalance      []RebalanceTrade `json:"rebalance"`
+	Unpriced       []string         `json:"unpriced"` // 有目标但没有价格，未列入分组和调仓计划
+	MinTrade       float64          `json:"min_trade"`
+	CashAfter      float64          `json:"cash_after"`
+}
+
+// allocationItem 一个持仓（多个账户合并）及其所属分组
+type allocationItem struct {
+	symbol, category, currency, sector, country, name string
+	value, quantity, unitValue                        float64 // 基础货币；unitValue 为每股/每张的市值
+	price                                             float64 // 交易币种
+}
+
+// latestFXRates 各币种最近的 fxRateToBase（持仓、交易、现金流水中日期最晚的一条）
+func latestFXRates(statements []flex.FlexStatement) map[string]float64 {
+	rates := make(map[string]float64)
+	dates := make(map[string]string)
+	observe := func(date, currency string, rate float64) {
+		if currency != "" && rate > 0 && date >= dates[currency] {
+			rates[currency], dates[currency] = rate, date
+		}
+	}
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			observe(normalizeDate(op.ReportDate), op.Currency, op.FxRateToBase)
+		}
+		for _, t := range stmt.Trades {
+			if !isFXTrade(t) {
+				observe(normalizeDate(t.TradeDate), t.Currency, t.FxRateToBase)
+			}
+		}
+		for _, ct := range stmt.CashTransactions {
+			observe(datePart(ct.DateTime), ct.Currency, ct.FxRateToBase)
+		}
+	}
+	rates[baseCurrency(statements)] = 1
+	return rates
+}
+
+// AnalyzeAllocation 按最近一期 OpenPositions 和 CashReport 计算资产类别、币种、行业、国家/地区和单一标的的敞口，
+// 与 opts.Allocation 的目标权重比较，并给出调仓计划：按目标与当前市值之差取整股数，
+// 小于 min_trade 的不交易，买入总额超过现金加卖出所得时按比例缩减
+func AnalyzeAllocation(statements []flex.FlexStatement, opts Options) *AllocationReport {
+	report := &AllocationReport{MinTrade: opts.Allocation.MinTrade}
+	rates := latestFXRates(statements)
+
+	// 持仓：多个账户的同一标的合并，按大写代码索引
+	items := make(map[string]*allocationItem)
+	newItem := func(op flex.OpenPosition) *allocationItem {
+		it := &allocationItem{
+			symbol: op.Symbol, category: op.AssetCategory, currency: op.Currency,
+			sector: ExposureUnclassified, country: ExposureUnclassified,
+			name: op.Symbol, price: op.MarkPrice,
+		}
+		if op.UnderlyingSymbol != "" {
+			it.name = op.UnderlyingSymbol
+		}
+		if s, ok := opts.Securities.lookup(op); ok {
+			it.sector, it.country = s.Sector, s.Country
+		} else if opts.Securities != nil && !slices.Contains(report.Unclassified, it.name) {
+			report.Unclassified = append(report.Unclassified, it.name)
+		}
+		items[strings.ToUpper(op.Symbol)] = it
+		return it
+	}
+	for _, op := range latestPositions(statements) {
+		report.AsOf = max(report.AsOf, normalizeDate(op.ReportDate))
+		if op.Position == 0 || op.AssetCategory == "CASH" {
+			continue // 外汇头寸在现金中
+		}
+		it, ok := items[strings.ToUpper(op.Symbol)]
+		if !ok {
+			it = newItem(op)
+		}
+		it.value += toBase(op.PositionValue, op.FxRateToBase)
+		it.quantity += op.Position
+	}
+	for _, it := range items {
+		if it.quantity != 0 {
+			it.unitValue = it.value / it.quantity
+		}
+		report.NetLiquidation += it.value
+	}
+
+	// 有目标但未持有的标的：市值为 0，价格取最近一笔交易，没有交易的无法调仓
+	targets := make(map[string]float64)
+	for _, t := range opts.Allocation.Targets {
+		targets[strings.ToUpper(strings.TrimSpace(t.Symbol))] = t.Weight
+	}
+	report.HasTargets = len(targets) > 0
+	lastTrade := make(map[string]flex.Trade)
+	for _, t := range uniqueTrades(statements) {
+		symbol := strings.ToUpper(t.Symbol)
+		if _, ok := targets[symbol]; ok && t.TradePrice > 0 && normalizeDate(t.TradeDate) >= normalizeDate(lastTrade[symbol].TradeDate) {
+			lastTrade[symbol] = t
+		}
+	}
+	for symbol := range targets {
+		if items[symbol] != nil {
+			continue
+		}
+		t, ok := lastTrade[symbol]
+		if !ok {
+			// 不列入持仓和分组，只在 Unpriced 中提示
+			report.Unpriced = append(report.Unpriced, symbol)
+			continue
+		}
+		it := newItem(flex.OpenPosition{
+			Symbol: t.Symbol, ISIN: t.ISIN, AssetCategory: t.AssetCategory, Currency: t.Currency, MarkPrice: t.TradePrice,
+		})
+		mult := t.Multiplier
+		if mult == 0 {
+			mult = 1
+		}
+		it.unitValue = toBase(t.TradePrice*mult, t.FxRateToBase)
+	}
+	sort.Strings(report.Unpriced)
+	sort.Strings(report.Unclassified)
+
+	// 现金：各账户 BASE_SUMMARY 的期末现金，按币种拆分时汇率未知的币种合并为一组
+	cashByCurrency := make(map[string]float64)
+	var unknown []string
+	var known float64
+	for key, amount := range latestCashReport(statements) {
+		_, currency, _ := strings.Cut(key, "|")
+		if currency == "BASE_SUMMARY" {
+			report.Cash += amount
+			continue
+		}
+		if rate, ok := rates[currency]; ok {
+			cashByCurrency[currency] += amount * rate
+			known += amount * rate
+		} else if amount != 0 && !slices.Contains(unknown, currency) {
+			unknown = append(unknown, currency)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		cashByCurrency[strings.Join(unknown, "/")] += report.Cash - known
+	}
+	report.NetLiquidation += report.Cash
+
+	// 目标市值：未设目标的标的保持当前市值，现金为剩余部分
+	targetValue := func(symbol string, it *allocationItem) float64 {
+		if w, ok := targets[symbol]; ok {
+			return w / 100 * report.NetLiquidation
+		}
+		return it.value
+	}
+	targetCash := report.NetLiquidation
+	for symbol, it := range items {
+		targetCash -= targetValue(symbol, it)
+	}
+
+	// group 按维度汇总持仓和现金；现金按币种拆分时目标现金按各币种现金的比例分摊
+	group := func(key func(*allocationItem) string, cash map[string]float64) []Exposure {
+		values, goals := make(map[string]float64), make(map[string]float64)
+		for symbol, it := range items {
+			values[key(it)] += it.value
+			goals[key(it)] += targetValue(symbol, it)
+		}
+		var cashTotal float64
+		for _, v := range cash {
+			cashTotal += v
+		}
+		for name, v := range cash {
+			values[name] += v
+			if cashTotal != 0 {
+				goals[name] += targetCash * v / cashTotal
+			} else {
+				goals[name] += targetCash / float64(len(cash))
+			}
+		}
+		var exposures []Exposure
+		for name, v := range values {
+			e := Exposure{Name: name, Value: v}
+			if report.NetLiquidation != 0 {
+				e.Weight = v / report.NetLiquidation * 100
+				e.TargetWeight = goals[name] / report.NetLiquidation * 100
+				e.Drift = e.Weight - e.TargetWeight
+			}
+			exposures = append(exposures, e)
+		}
+		sort.Slice(exposures, func(i, j int) bool {
+			if exposures[i].Value != exposures[j].Value {
+				return exposures[i].Value > exposures[j].Value
+			}
+			return exposures[i].Name < exposures[j].Name
+		})
+		return exposures
+	}
+	if len(cashByCurrency) == 0 {
+		cashByCurrency[baseCurrency(statements)] = report.Cash
+	}
+	cashAll := map[string]float64{ExposureCash: report.Cash}
+
+	report.ByCategory = group(func(it *allocationItem) string { return it.category }, cashAll)
+	report.ByCurrency = group(func(it *allocationItem) string { return it.currency }, cashByCurrency)
+	report.ByName = group(func(it *allocationItem) string { return it.name }, cashAll)
+	if opts.Securities != nil {
+		report.BySector = group(func(it *allocationItem) string { return it.sector }, cashAll)
+		report.ByCountry = group(func(it *allocationItem) string { return it.country }, cashAll)
+	}
+
+	report.Rebalance, report.CashAfter = rebalance(items, targets, report)
+	report.AsOf = isoDate(report.AsOf)
+	return report
+}
+
+// rebalance 按目标权重生成调仓计划，返回计划和调仓后的现金
+func rebalance(items map[string]*allocationItem, targets map[string]float64, report *AllocationReport) ([]RebalanceTrade, float64) {
+	if len(targets) == 0 || report.NetLiquidation == 0 {
+		return nil, report.Cash
+	}
+
+	var plan []RebalanceTrade
+	var wanted, units []float64 // 每个计划项按目标需要的金额（正为买入）和每股市值
+	for symbol, w := range targets {
+		it := items[symbol]
+		if it == nil || it.unitValue == 0 {
+			continue // 见 Unpriced
+		}
+		plan = append(plan, RebalanceTrade{
+			Symbol: it.symbol, Currency: it.currency, Price: it.price,
+			Weight: it.value / report.NetLiquidation * 100, TargetWeight: w,
+		})
+		wanted = append(wanted, w/100*report.NetLiquidation-it.value)
+		units = append(units, it.unitValue)
+	}
+
+	// size 按金额取整股（向零取整），小于 min_trade 的不交易
+	size := func(i int, amount float64) {
+		qty := math.Trunc(amount / units[i])
+		if math.Abs(qty*units[i]) < report.MinTrade {
+			qty = 0
+		}
+		plan[i].Quantity = qty
+		plan[i].Value = qty * units[i]
+	}
+
+	var buys, sells float64
+	for i := range plan {
+		size(i, wanted[i])
+		if plan[i].Value > 0 {
+			buys += plan[i].Value
+		} else {
+			sells -= plan[i].Value
+		}
+	}
+	// 买入不超过现金加卖出所得
+	if available := report.Cash + sells; buys > available+reconcileTolerance {
+		scale := max(available, 0) / buys
+		buys = 0
+		for i := range plan {
+			if plan[i].Value > 0 {
+				size(i, wanted[i]*scale)
+				buys += plan[i].Value
+			}
+		}
+	}
+
+	for i := range plan {
+		plan[i].NewWeight = plan[i].Weight + plan[i].Value/report.NetLiquidation*100
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		if plan[i].Value != plan[j].Value {
+			return plan[i].Value < plan[j].Value // 先卖后买
+		}
+		return plan[i].Symbol < plan[j].Symbol
+	})
+	return plan, report.Cash + sells - buys
+}
+
+// exposureName 分组名称的显示名称
+func exposureName(name string) string {
+	switch name {
+	case ExposureCash:
+		return i18n.T("现金")
+	case ExposureUnclassified:
+		return i18n.T("未分类")
+	}
+	return name
+}
+
+func PrintAllocationReport(r *AllocationReport) {
+	printTitle("资产配置")
+	i18n.Printf("截至 %s\n", formatDate(normalizeDate(r.AsOf)))
+	i18n.Printf("净资产: %s\n", fmtMoney(r.NetLiquidation))
+	i18n.Printf("现金:   %s\n", fmtMoney(r.Cash))
+	fmt.Println()
+
+	for _, g := range []struct {
+		title     string
+		exposures []Exposure
+	}{
+		{"按资产类别", r.ByCategory},
+		{"按币种", r.ByCurrency},
+		{"按行业", r.BySector},
+		{"按国家/地区", r.ByCountry},
+		{"按单一标的", r.ByName},
+	} {
+		if len(g.exposures) == 0 {
+			continue
+		}
+		printSection(g.title)
+		headers := []string{"名称", "市值", "占比"}
+		if r.HasTargets {
+			headers = append(headers, "目标", "偏离")
+		}
+		var rows [][]string
+		for _, e := range g.exposures {
+			row := []string{exposureName(e.Name), fmtMoney(e.Value), fmt.Sprintf("%.1f%%", e.Weight)}
+			if r.HasTargets {
+				row = append(row, fmt.Sprintf("%.1f%%", e.TargetWeight), fmt.Sprintf("%+.1f%%", e.Drift))
+			}
+			rows = append(rows, row)
+		}
+		printTable(headers, rows)
+	}
+	if len(r.Unclassified) > 0 {
+		i18n.Printf("证券主数据中没有，归入未分类: %s\n\n", strings.Join(r.Unclassified, ", "))
+	}
+
+	printSection("调仓计划")
+	if !r.HasTargets {
+		i18n.Println("未配置目标权重（[[allocation.targets]]），不生成调仓计划")
+		return
+	}
+	var rows [][]string
+	for _, t := range r.Rebalance {
+		rows = append(rows, []string{
+			t.Symbol, t.Currency, fmtMoney(t.Price), fmt.Sprintf("%+g", t.Quantity), fmtMoney(t.Value),
+			fmt.Sprintf("%.1f%%", t.Weight), fmt.Sprintf("%.1f%%", t.TargetWeight), fmt.Sprintf("%.1f%%", t.NewWeight),
+		})
+	}
+	printTable([]string{"标的", "币种", "价格", "数量", "金额", "当前占比", "目标占比", "调整后占比"}, rows)
+	i18n.Printf("调仓后现金: %s（单笔最小金额 %s）\n", fmtMoney(r.CashAfter), fmtMoney(r.MinTrade))
+	if len(r.Unpriced) > 0 {
+		i18n.Printf("⚠ 没有价格（未持有也没有交易记录），未列入调仓计划: %s\n", strings.Join(r.Unpriced, ", "))
+	}
+}
+
+// Tables 导出用的表：汇总、各维度敞口、调仓计划
+func (r *AllocationReport) Tables() []Table {
+	exposures := func(name, title string, list []Exposure) Table {
+		t := newTable(name, title, "名称", "市值", "占比 (%)", "目标 (%)", "偏离 (%)")
+		for _, e := range list {
+			t.Rows = append(t.Rows, []any{exposureName(e.Name), e.Value, e.Weight, e.TargetWeight, e.Drift})
+		}
+		return t
+	}
+	plan := newTable("rebalance", "调仓计划", "标的", "币种", "价格", "数量", "金额", "当前占比 (%)", "目标占比 (%)", "调整后占比 (%)")
+	for _, t := range r.Rebalance {
+		plan.Rows = append(plan.Rows, []any{t.Symbol, t.Currency, t.Price, t.Quantity, t.Value, t.Weight, t.TargetWeight, t.NewWeight})
+	}
+
+	return []Table{
+		totalsTable(
+			[]any{"净资产", r.NetLiquidation},
+			[]any{"现金", r.Cash},
+			[]any{"调仓后现金", r.CashAfter},
+		),
+		exposures("by_asset_category", "按资产类别", r.ByCategory),
+		exposures("by_currency", "按币种", r.ByCurrency),
+		exposures("by_sector", "按行业", r.BySector),
+		exposures("by_country", "按国家/地区", r.ByCountry),
+		exposures("by_name", "按单一标的", r.ByName),
+		plan,
+	}
+}
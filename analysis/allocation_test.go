@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+func TestRebalance(t *testing.T) {
+	items := func() map[string]*allocationItem {
+		return map[string]*allocationItem{
+			"AAPL": {symbol: "AAPL", currency: "USD", value: 6000, quantity: 30, unitValue: 200, price: 200},
+			"MSFT": {symbol: "MSFT", currency: "USD", unitValue: 400, price: 400},
+		}
+	}
+	tests := []struct {
+		name      string
+		targets   map[string]float64
+		cash      float64
+		minTrade  float64
+		want      map[string]float64 // 标的 → 调仓数量
+		cashAfter float64
+	}{
+		{
+			name:      "sell overweight and buy underweight in whole shares",
+			targets:   map[string]float64{"AAPL": 40, "MSFT": 30},
+			cash:      4000,
+			want:      map[string]float64{"AAPL": -10, "MSFT": 7},
+			cashAfter: 4000 + 2000 - 2800,
+		},
+		{
+			name:      "trades below min_trade are skipped",
+			targets:   map[string]float64{"AAPL": 40, "MSFT": 30},
+			cash:      4000,
+			minTrade:  2500,
+			want:      map[string]float64{"AAPL": 0, "MSFT": 7},
+			cashAfter: 4000 - 2800,
+		},
+		{
+			name:      "buys are scaled down to the available cash",
+			targets:   map[string]float64{"MSFT": 30},
+			cash:      1000,
+			want:      map[string]float64{"MSFT": 2},
+			cashAfter: 1000 - 800,
+		},
+		{
+			name:      "targets without an item are left out",
+			targets:   map[string]float64{"MSFT": 30, "VOO": 10},
+			cash:      4000,
+			want:      map[string]float64{"MSFT": 7},
+			cashAfter: 4000 - 2800,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &AllocationReport{NetLiquidation: 6000 + tt.cash, Cash: tt.cash, MinTrade: tt.minTrade}
+			plan, cashAfter := rebalance(items(), tt.targets, report)
+			got := make(map[string]float64)
+			for _, p := range plan {
+				got[p.Symbol] = p.Quantity
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("plan = %+v, want %v", plan, tt.want)
+			}
+			for symbol, qty := range tt.want {
+				if q, ok := got[symbol]; !ok || q != qty {
+					t.Errorf("%s quantity = %g, want %g", symbol, q, qty)
+				}
+			}
+			if !approxEqual(cashAfter, tt.cashAfter) {
+				t.Errorf("cash after = %g, want %g", cashAfter, tt.cashAfter)
+			}
+			for i := 1; i < len(plan); i++ {
+				if plan[i-1].Value > plan[i].Value {
+					t.Errorf("plan not ordered sells first: %+v", plan)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeAllocationUnpricedTarget(t *testing.T) {
+	statements := []flex.FlexStatement{{
+		AccountID: "U1", FromDate: "20250101", ToDate: "20250131",
+		AccountInformation: &flex.AccountInformation{AccountID: "U1", Currency: "USD"},
+		OpenPositions: []flex.OpenPosition{
+			{Symbol: "AAPL", AssetCategory: "STK", Currency: "USD", Position: 30, MarkPrice: 200, PositionValue: 6000, FxRateToBase: 1, ReportDate: "20250131"},
+		},
+		CashReport: []flex.CashReportCurrency{
+			{AccountID: "U1", Currency: "BASE_SUMMARY", FromDate: "20250101", ToDate: "20250131", EndingCash: 4000},
+		},
+	}}
+	opts := Options{Allocation: AllocationConfig{Targets: []TargetWeight{{Symbol: "AAPL", Weight: 50}, {Symbol: "VOO", Weight: 20}}}}
+
+	r := AnalyzeAllocation(statements, opts)
+	if !slices.Equal(r.Unpriced, []string{"VOO"}) {
+		t.Errorf("Unpriced = %v, want [VOO]", r.Unpriced)
+	}
+	for _, groups := range [][]Exposure{r.ByCategory, r.ByCurrency, r.ByName} {
+		var total float64
+		for _, e := range groups {
+			if e.Name == "VOO" || e.Name == ExposureUnclassified {
+				t.Errorf("unpriced target listed as exposure %+v", e)
+			}
+			total += e.Value
+		}
+		if !approxEqual(total, r.NetLiquidation) {
+			t.Errorf("exposures sum to %g, want %g", total, r.NetLiquidation)
+		}
+	}
+	if len(r.Rebalance) != 1 || r.Rebalance[0].Symbol != "AAPL" || r.Rebalance[0].Quantity != -5 {
+		t.Errorf("Rebalance = %+v, want AAPL -5", r.Rebalance)
+	}
+}
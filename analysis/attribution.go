@@ -0,0 +1,626 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// AttributionLine 一个标的（现金行为一个币种的现金）或一组的收益分解，金额为基础货币。
+// Contribution 为合计占 Modified Dietz 分母的百分比，各行相加等于组合收益率（不含残差）
+type AttributionLine struct {
+	Name         string  `json:"name"`
+	Category     string  `json:"category"`
+	Currency     string  `json:"currency"`
+	Price        float64 `json:"price"`  // 价格变动，按期末汇率折算
+	Income       float64 `json:"income"` // 股息、代付股息、债券利息，扣除预扣税
+	Costs        float64 `json:"costs"`  // 佣金、交易税
+	FX           float64 `json:"fx"`     // 汇率变动对持仓和现金的影响，以及换汇损益
+	Cash         float64 `json:"cash"`   // 现金的利息收支和其他费用
+	Total        float64 `json:"total"`
+	Contribution float64 `json:"contribution"`
+}
+
+func (l *AttributionLine) add(o AttributionLine) {
+	l.Price += o.Price
+	l.Income += o.Income
+	l.Costs += o.Costs
+	l.FX += o.FX
+	l.Cash += o.Cash
+}
+
+// AttributionReport 期间净资产变化的分解：净资产变化 = 出入金 + 价格 + 收入 + 交易成本 + 汇率 + 现金 + 残差。
+// 出入金包括现金转账和按市值计入的持仓转入转出；残差包括应计利息和股息的变化、没有价格的持仓以及数据缺失
+type AttributionReport struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	StartingNAV float64 `json:"starting_nav"`
+	EndingNAV   float64 `json:"ending_nav"`
+	NAVChange   float64 `json:"nav_change"`
+	NetDeposits float64 `json:"net_deposits"`
+	Return      float64 `json:"return"` // Modified Dietz 收益率（%）
+
+	Price    float64 `json:"price"`
+	Income   float64 `json:"income"`
+	Costs    float64 `json:"costs"`
+	FX       float64 `json:"fx"`
+	Cash     float64 `json:"cash"`
+	Residual float64 `json:"residual"`
+
+	BySymbol   []AttributionLine `json:"by_symbol"`
+	ByCategory []AttributionLine `json:"by_category"`
+	ByCurrency []AttributionLine `json:"by_currency"`
+	Unpriced   []string          `json:"unpriced"` // 期初或期末有持仓但没有价格的 账户:代码，其价格和汇率贡献计入残差
+	NoFX       []string          `json:"no_fx"`    // 数据中没有汇率、按 1:1 折算为基础货币的币种
+}
+
+// priceObservation 持仓某日的价格
+type priceObservation struct {
+	date       string
+	price      float64
+	multiplier float64
+	trade      bool // 来自成交价或转账价格，同一天有市价时以市价为准
+}
+
+// attributionPosition 一个账户一个标的在期间内的数据
+type attributionPosition struct {
+	account    string
+	symbol     string
+	category   string
+	currency   string
+	anchor     string  // 已知数量的日期（最近的 OpenPositions 报告日）
+	quantity   float64 // anchor 日的数量
+	events     []holdingEvent
+	trades     []cashFlow // 交易金额（proceeds，买入为负），持仓转入按市值视为买入
+	transfers  []cashFlow // 持仓转入转出的市值（交易币种，转入为正），计入出入金
+	prices     []priceObservation
+	multiplier float64
+}
+
+// quantityOn 某日收盘后的数量：从 anchor 日的数量按期间内的交易、公司行动和转仓倒推或顺推
+func (p *attributionPosition) quantityOn(date string) float64 {
+	q := p.quantity
+	for _, e := range p.events {
+		switch {
+		case e.date > date && e.date <= p.anchor:
+			q -= e.quantity
+		case e.date > p.anchor && e.date <= date:
+			q += e.quantity
+		}
+	}
+	return cleanFloat(q)
+}
+
+// unitValueOn 某日每单位的市值（价格 × 乘数），用当日或之前最近的价格，同一天市价优先于成交价
+func (p *attributionPosition) unitValueOn(date string) (float64, bool) {
+	var last *priceObservation
+	for i := range p.prices {
+		o := &p.prices[i]
+		if o.date > date {
+			continue
+		}
+		if last == nil || o.date > last.date || (o.date == last.date && (!o.trade || last.trade)) {
+			last = o
+		}
+	}
+	if last == nil {
+		return 0, false
+	}
+	mult := last.multiplier
+	if mult == 0 {
+		mult = p.multiplier
+	}
+	if mult == 0 {
+		mult = 1
+	}
+	return last.price * mult, true
+}
+
+// valueOn 某日的市值（交易币种）；有持仓但没有价格时返回 false
+func (p *attributionPosition) valueOn(date string) (float64, bool) {
+	q := p.quantityOn(date)
+	if math.Abs(q) < 1e-9 {
+		return 0, true
+	}
+	unit, ok := p.unitValueOn(date)
+	return q * unit, ok
+}
+
+// attributionPositions 按 账户|代码 收集持仓的期末数量、价格（OpenPositions 和 Prior Period Positions，
+// 缺少时用成交价和转账价格）、交易金额、持仓转账的市值和数量变动，多个 query 中重复的交易和转账只计一次
+func attributionPositions(statements []flex.FlexStatement) map[string]*attributionPosition {
+	positions := make(map[string]*attributionPosition)
+	seen := make(map[string]bool)
+	anchors := make(map[string]string) // 账户 → 最近的 OpenPositions 报告日
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			anchors[stmt.AccountID] = max(anchors[stmt.AccountID], normalizeDate(op.ReportDate))
+		}
+	}
+	get := func(account, symbol, category, currency string) *attributionPosition {
+		key := account + "|" + symbol
+		p := positions[key]
+		if p == nil {
+			p = &attributionPosition{account: account, symbol: symbol, anchor: anchors[account]}
+			positions[key] = p
+		}
+		if p.category == "" {
+			p.category = category
+		}
+		if p.currency == "" {
+			p.currency = currency
+		}
+		return p
+	}
+
+	for key, op := range latestPositions(statements) {
+		account, _, _ := strings.Cut(key, "|")
+		get(account, op.Symbol, op.AssetCategory, op.Currency).quantity = op.Position
+	}
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			if op.LevelOfDetail == "LOT" || op.MarkPrice == 0 {
+				continue
+			}
+			// OpenPositions 常没有 multiplier，由市值反推
+			mult := op.Multiplier
+			if mult == 0 && op.Position != 0 {
+				mult = op.PositionValue / (op.Position * op.MarkPrice)
+			}
+			p := get(stmt.AccountID, op.Symbol, op.AssetCategory, op.Currency)
+			p.prices = append(p.prices, priceObservation{normalizeDate(op.ReportDate), op.MarkPrice, mult, false})
+		}
+		for _, pp := range stmt.PriorPeriodPositions {
+			if pp.Price == 0 {
+				continue
+			}
+			p := get(accountOr(pp.AccountID, stmt.AccountID), pp.Symbol, pp.AssetCategory, pp.Currency)
+			p.prices = append(p.prices, priceObservation{normalizeDate(pp.Date), pp.Price, pp.Multiplier, false})
+		}
+		for _, t := range stmt.Trades {
+			id := stmt.AccountID + "|" + tradeEntryID(t)
+			if isFXTrade(t) || seen[id] {
+				continue
+			}
+			seen[id] = true
+			p := get(stmt.AccountID, t.Symbol, t.AssetCategory, t.Currency)
+			date := normalizeDate(t.TradeDate)
+			p.trades = append(p.trades, cashFlow{date: date, currency: t.Currency, amount: t.Proceeds})
+			if t.TradePrice > 0 {
+				p.prices = append(p.prices, priceObservation{date, t.TradePrice, t.Multiplier, true})
+			}
+			if t.Multiplier != 0 {
+				p.multiplier = t.Multiplier
+			}
+		}
+	}
+
+	// 持仓转入转出：按转账市值计入出入金，并作为一笔买入（转出为卖出），使其不计入价格贡献
+	type pendingTransfer struct {
+		p  *attributionPosition
+		tr flex.Transfer
+	}
+	var pending []pendingTransfer
+	seenTransfers := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, tr := range stmt.Transfers {
+			id := stmt.AccountID + "|" + tr.TransactionID
+			if isCashTransfer(tr) || (tr.TransactionID != "" && seenTransfers[id]) {
+				continue
+			}
+			seenTransfers[id] = true
+			p := get(accountOr(tr.AccountID, stmt.AccountID), tr.Symbol, tr.AssetCategory, tr.Currency)
+			if tr.TransferPrice > 0 {
+				p.prices = append(p.prices, priceObservation{datePart(tr.DateTime), tr.TransferPrice, 0, true})
+			}
+			pending = append(pending, pendingTransfer{p, tr})
+		}
+	}
+	for _, pt := range pending {
+		date := datePart(pt.tr.DateTime)
+		value := transferValue(pt.tr)
+		if value == 0 {
+			// 转账没有市值时按当日价格估算
+			unit, _ := pt.p.unitValueOn(date)
+			value = transferSign(pt.tr) * math.Abs(pt.tr.Quantity) * unit
+		}
+		pt.p.transfers = append(pt.p.transfers, cashFlow{date: date, currency: pt.p.currency, amount: value})
+		pt.p.trades = append(pt.p.trades, cashFlow{date: date, currency: pt.p.currency, amount: -value})
+	}
+	for _, e := range holdingEvents(statements) {
+		p := get(e.accountID, e.symbol, e.assetCategory, e.currency)
+		p.events = append(p.events, e)
+	}
+	return positions
+}
+
+// AnalyzeAttribution 把 from/to 范围内第一个到最后一个净资产日期之间的净资产变化分解为出入金、
+// 各持仓的价格贡献、收入、交易成本、汇率影响和现金收支，按资产类别和币种汇总，差额列为残差。
+// 期初、期末的持仓市值用 OpenPositions 和 Prior Period Positions 的价格，数量由交易、公司行动和转仓推算；
+// 各币种现金由 CashReport 期末现金按明细记录倒推。没有每日净资产时返回 nil
+func AnalyzeAttribution(statements []flex.FlexStatement, from, to string) *AttributionReport {
+	points := navSeries(statements, from, to)
+	if len(points) < 2 {
+		return nil
+	}
+	start, end := points[0], points[len(points)-1]
+	s, e := start.date, end.date
+	report := &AttributionReport{
+		From: isoDate(s), To: isoDate(e),
+		StartingNAV: start.nav, EndingNAV: end.nav, NAVChange: end.nav - start.nav,
+	}
+	fx := fxRateHistory(statements)
+	noFX := make(map[string]bool)
+	// rate 某币种某日的汇率，数据中没有该币种的汇率时按 1 计，并在报告中列出
+	rate := func(currency, date string) float64 {
+		r, ok := fx.rateOn(currency, date)
+		if !ok {
+			noFX[currency] = true
+			return 1
+		}
+		return r
+	}
+	inPeriod := func(date string) bool { return date > s && date <= e }
+
+	lines := make(map[string]*AttributionLine) // 标的|币种，现金为 |币种
+	line := func(symbol, category, currency string) *AttributionLine {
+		if symbol == "" {
+			category = "CASH"
+		}
+		key := symbol + "|" + currency
+		l := lines[key]
+		if l == nil {
+			l = &AttributionLine{Name: symbol, Category: category, Currency: currency}
+			lines[key] = l
+		}
+		if l.Category == "" {
+			l.Category = category
+		}
+		return l
+	}
+
+	// 持仓：价格贡献 = (期末市值 - 期初市值 + 交易金额) × 期末汇率，其余为汇率影响
+	positions := attributionPositions(statements)
+	categories := make(map[string]string) // 账户|代码 → 资产类别，用于收入
+	unpriced := make(map[string]bool)     // 账户:代码
+	for key, p := range positions {
+		categories[key] = p.category
+		for _, f := range p.transfers {
+			if inPeriod(f.date) {
+				report.NetDeposits += f.amount * rate(p.currency, f.date)
+			}
+		}
+		v0, ok0 := p.valueOn(s)
+		v1, ok1 := p.valueOn(e)
+		if !ok0 || !ok1 {
+			unpriced[p.account+":"+p.symbol] = true
+			continue
+		}
+		r0, r1 := rate(p.currency, s), rate(p.currency, e)
+		local := v1 - v0
+		fxEffect := v0 * (r1 - r0)
+		for _, f := range p.trades {
+			if inPeriod(f.date) && f.currency == p.currency {
+				local += f.amount
+				fxEffect -= f.amount * (r1 - rate(f.currency, f.date))
+			}
+		}
+		l := line(p.symbol, p.category, p.currency)
+		l.Price += local * r1
+		l.FX += fxEffect
+	}
+	for name := range unpriced {
+		report.Unpriced = append(report.Unpriced, name)
+	}
+	sort.Strings(report.Unpriced)
+
+	// 现金：期初、期末余额由 CashReport 期末现金减去之后的明细记录得到，
+	// 汇率影响 = 期末余额 × 期末汇率 - 期初余额 × 期初汇率 - 各笔记录按当日汇率折算的合计
+	anchors := make(map[string]string) // 账户 → 最近的 CashReport 截止日
+	for _, stmt := range statements {
+		if len(stmt.CashReport) > 0 {
+			anchors[stmt.AccountID] = max(anchors[stmt.AccountID], normalizeDate(stmt.ToDate))
+		}
+	}
+	flows := accountCashFlows(statements)
+	for key, ending := range latestCashReport(statements) {
+		account, currency, _ := strings.Cut(key, "|")
+		if currency == "BASE_SUMMARY" {
+			continue
+		}
+		c0, c1 := ending, ending
+		var flowsBase float64
+		for _, f := range flows[account] {
+			if f.currency != currency {
+				continue
+			}
+			if f.date > s && f.date <= anchors[account] {
+				c0 -= f.amount
+			}
+			if f.date > e && f.date <= anchors[account] {
+				c1 -= f.amount
+			}
+			if inPeriod(f.date) {
+				flowsBase += f.amount * rate(currency, f.date)
+			}
+		}
+		line("", "CASH", currency).FX += c1*rate(currency, e) - c0*rate(currency, s) - flowsBase
+	}
+
+	// 各笔现金记录按当日汇率折算，归入对应的分项；换汇交易两边的折算差额为换汇损益
+	for _, t := range uniqueTrades(statements) {
+		date := normalizeDate(t.TradeDate)
+		if !inPeriod(date) {
+			continue
+		}
+		commCurr := t.CommissionCurr
+		if commCurr == "" {
+			commCurr = t.Currency
+		}
+		if isFXTrade(t) {
+			base, quote := splitPair(t.Symbol)
+			if quote == "" {
+				quote = t.Currency
+			}
+			line("", "CASH", quote).FX += t.Quantity*rate(base, date) + t.Proceeds*rate(quote, date)
+			line("", "CASH", commCurr).Costs += t.Commission * rate(commCurr, date)
+			continue
+		}
+		l := line(t.Symbol, t.AssetCategory, t.Currency)
+		l.Costs += t.Commission*rate(commCurr, date) + t.Taxes*rate(t.Currency, date)
+	}
+	// 现金流水没有账户字段，按账单的账户查资产类别；重复的记录同 uniqueCashTransactions 只计一次
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, ct := range stmt.CashTransactions {
+			date := cashDate(ct)
+			id := cashEntryID(ct)
+			if !inPeriod(date) || seen[id] {
+				continue
+			}
+			seen[id] = true
+			amount := ct.Amount * rate(ct.Currency, date)
+			category := categories[stmt.AccountID+"|"+ct.Symbol]
+			switch component := cashComponent(ct); {
+			case component == "deposits":
+				report.NetDeposits += amount
+			case component == "dividends" || component == "withholding_tax" || (component == "interest" && ct.Symbol != ""):
+				line(ct.Symbol, category, ct.Currency).Income += amount
+			case component == "trades":
+				line(ct.Symbol, category, ct.Currency).Costs += amount
+			default:
+				line("", "CASH", ct.Currency).Cash += amount
+			}
+		}
+	}
+	seen = make(map[string]bool)
+	for _, stmt := range statements {
+		for _, tr := range stmt.Transfers {
+			id := stmt.AccountID + "|" + tr.TransactionID
+			date := datePart(tr.DateTime)
+			if tr.Amount == 0 || !isCashTransfer(tr) || !inPeriod(date) || (tr.TransactionID != "" && seen[id]) {
+				continue
+			}
+			seen[id] = true
+			report.NetDeposits += tr.Amount * rate(tr.Currency, date)
+		}
+	}
+
+	for currency := range noFX {
+		report.NoFX = append(report.NoFX, currency)
+	}
+	sort.Strings(report.NoFX)
+
+	// 汇总：按标的、资产类别、币种；Modified Dietz 分母 = 期初净资产 + 出入金按剩余天数加权
+	span := daysBetween(s, e)
+	denominator := report.StartingNAV
+	for _, p := range points[1:] {
+		if p.flow != 0 && span > 0 {
+			denominator += p.flow * float64(daysBetween(p.date, e)) / float64(span)
+		}
+	}
+	contribution := func(v float64) float64 {
+		if denominator <= 0 {
+			return 0
+		}
+		return v / denominator * 100
+	}
+	finish := func(l *AttributionLine) {
+		l.Price, l.Income, l.Costs, l.FX, l.Cash = roundAmount(l.Price), roundAmount(l.Income), roundAmount(l.Costs), roundAmount(l.FX), roundAmount(l.Cash)
+		l.Total = roundAmount(l.Price + l.Income + l.Costs + l.FX + l.Cash)
+		l.Contribution = contribution(l.Total)
+	}
+	byCategory := make(map[string]*AttributionLine)
+	byCurrency := make(map[string]*AttributionLine)
+	for _, l := range lines {
+		finish(l)
+		if l.Price == 0 && l.Income == 0 && l.Costs == 0 && l.FX == 0 && l.Cash == 0 {
+			continue
+		}
+		report.BySymbol = append(report.BySymbol, *l)
+		for _, g := range []struct {
+			m    map[string]*AttributionLine
+			name string
+		}{{byCategory, l.Category}, {byCurrency, l.Currency}} {
+			if g.m[g.name] == nil {
+				g.m[g.name] = &AttributionLine{Name: g.name}
+			}
+			g.m[g.name].add(*l)
+		}
+		report.Price += l.Price
+		report.Income += l.Income
+		report.Costs += l.Costs
+		report.FX += l.FX
+		report.Cash += l.Cash
+	}
+	for _, g := range []struct {
+		m    map[string]*AttributionLine
+		list *[]AttributionLine
+	}{{byCategory, &report.ByCategory}, {byCurrency, &report.ByCurrency}} {
+		for _, l := range g.m {
+			finish(l)
+			*g.list = append(*g.list, *l)
+		}
+	}
+	for _, list := range [][]AttributionLine{report.BySymbol, report.ByCategory, report.ByCurrency} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Total != list[j].Total {
+				return list[i].Total > list[j].Total
+			}
+			return list[i].Name+list[i].Currency < list[j].Name+list[j].Currency
+		})
+	}
+
+	report.NetDeposits = roundAmount(report.NetDeposits)
+	report.Price, report.Income, report.Costs = roundAmount(report.Price), roundAmount(report.Income), roundAmount(report.Costs)
+	report.FX, report.Cash = roundAmount(report.FX), roundAmount(report.Cash)
+	report.Residual = roundAmount(report.NAVChange - report.NetDeposits - report.Price - report.Income - report.Costs - report.FX - report.Cash)
+	report.Return = contribution(report.NAVChange - report.NetDeposits)
+	return report
+}
+
+// attributionTolerance 残差超过此金额（基础货币）时提示
+const attributionTolerance = 0.01
+
+// roundAmount 同 cleanFloat，并把 -0 变为 0，避免显示 -0.00
+func roundAmount(v float64) float64 {
+	if v = cleanFloat(v); v == 0 {
+		return 0
+	}
+	return v
+}
+
+// daysBetween 两个 YYYYMMDD 日期相差的天数
+func daysBetween(from, to string) int {
+	a, err1 := time.Parse("20060102", from)
+	b, err2 := time.Parse("20060102", to)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// attributionName 现金行显示为“现金 币种”
+func attributionName(l AttributionLine) string {
+	if l.Name == "" {
+		return i18n.T("现金") + " " + l.Currency
+	}
+	return l.Name
+}
+
+func PrintAttributionReport(r *AttributionReport) {
+	printTitle("业绩归因")
+	i18n.Printf("期间: %s ~ %s\n", formatDate(normalizeDate(r.From)), formatDate(normalizeDate(r.To)))
+	i18n.Printf("净资产: %s → %s\n", fmtMoney(r.StartingNAV), fmtMoney(r.EndingNAV))
+	i18n.Printf("收益率: %s（Modified Dietz）\n", signedPercent(r.Return))
+	fmt.Println()
+
+	contribution := func(v float64) string {
+		if r.NAVChange-r.NetDeposits == 0 {
+			return "-"
+		}
+		return signedPercent(v / (r.NAVChange - r.NetDeposits) * r.Return)
+	}
+	printSection("净资产变化分解")
+	rows := [][]string{{i18n.T("出入金"), fmtMoney(r.NetDeposits), ""}}
+	for _, item := range []struct {
+		name  string
+		value float64
+	}{
+		{"价格", r.Price}, {"收入", r.Income}, {"交易成本", r.Costs}, {"汇率影响", r.FX}, {"现金", r.Cash}, {"残差", r.Residual},
+	} {
+		rows = append(rows, []string{i18n.T(item.name), fmtMoney(item.value), contribution(item.value)})
+	}
+	rows = append(rows, []string{i18n.T("净资产变化"), fmtMoney(r.NAVChange), ""})
+	printTable([]string{"项目", "金额", "贡献"}, rows)
+
+	for _, g := range []struct {
+		title string
+		first string
+		lines []AttributionLine
+	}{
+		{"按资产类别", "类别", r.ByCategory},
+		{"按币种", "币种", r.ByCurrency},
+	} {
+		if len(g.lines) == 0 {
+			continue
+		}
+		printSection(g.title)
+		var rows [][]string
+		for _, l := range g.lines {
+			rows = append(rows, []string{
+				l.Name, fmtMoney(l.Price), fmtMoney(l.Income), fmtMoney(l.Costs), fmtMoney(l.FX), fmtMoney(l.Cash),
+				fmtMoney(l.Total), signedPercent(l.Contribution),
+			})
+		}
+		printTable([]string{g.first, "价格", "收入", "交易成本", "汇率影响", "现金", "合计", "贡献"}, rows)
+	}
+
+	if len(r.BySymbol) > 0 {
+		printSection("按标的")
+		var rows [][]string
+		for _, l := range r.BySymbol {
+			rows = append(rows, []string{
+				attributionName(l), l.Category, l.Currency, fmtMoney(l.Price), fmtMoney(l.Income), fmtMoney(l.Costs),
+				fmtMoney(l.FX), fmtMoney(l.Cash), fmtMoney(l.Total), signedPercent(l.Contribution),
+			})
+		}
+		printTable([]string{"标的", "类别", "币种", "价格", "收入", "交易成本", "汇率影响", "现金", "合计", "贡献"}, rows)
+	}
+}
+
+// PrintWarnings 有没有价格的持仓或残差不为 0 时提示，各分项相加与净资产变化不符的部分没有归因
+func (r *AttributionReport) PrintWarnings(w io.Writer) {
+	if len(r.Unpriced) > 0 {
+		i18n.Fprintf(w, "⚠ 期初或期末没有价格，价格和汇率贡献计入残差: %s\n", strings.Join(r.Unpriced, ", "))
+		i18n.Fprintf(w, "  期初已持有的标的需要 Flex Query 勾选 Prior Period Positions\n")
+	}
+	if len(r.NoFX) > 0 {
+		i18n.Fprintf(w, "⚠ 数据中没有这些币种的汇率，按 1:1 折算为基础货币: %s\n", strings.Join(r.NoFX, ", "))
+	}
+	if math.Abs(r.Residual) >= attributionTolerance {
+		i18n.Fprintf(w, "⚠ 各分项合计与净资产变化相差 %s（残差），这部分没有归因\n", fmtMoney(r.Residual))
+		i18n.Fprintf(w, "  常见原因：应计股息和利息的变化、没有价格的持仓、Flex Query 缺少交易、现金流水或转账段\n")
+	}
+}
+
+// Tables 导出用的表：汇总、按资产类别、按币种、按标的
+func (r *AttributionReport) Tables() []Table {
+	groups := func(name, title, first string, list []AttributionLine) Table {
+		t := newTable(name, title, first, "价格", "收入", "交易成本", "汇率影响", "现金", "合计", "贡献 (%)")
+		for _, l := range list {
+			t.Rows = append(t.Rows, []any{l.Name, l.Price, l.Income, l.Costs, l.FX, l.Cash, l.Total, l.Contribution})
+		}
+		return t
+	}
+	symbols := newTable("by_symbol", "按标的", "标的", "类别", "币种", "价格", "收入", "交易成本", "汇率影响", "现金", "合计", "贡献 (%)")
+	for _, l := range r.BySymbol {
+		symbols.Rows = append(symbols.Rows, []any{l.Name, l.Category, l.Currency, l.Price, l.Income, l.Costs, l.FX, l.Cash, l.Total, l.Contribution})
+	}
+	return []Table{
+		totalsTable(
+			[]any{"起始日期", r.From},
+			[]any{"结束日期", r.To},
+			[]any{"期初净资产", r.StartingNAV},
+			[]any{"期末净资产", r.EndingNAV},
+			[]any{"出入金", r.NetDeposits},
+			[]any{"价格", r.Price},
+			[]any{"收入", r.Income},
+			[]any{"交易成本", r.Costs},
+			[]any{"汇率影响", r.FX},
+			[]any{"现金", r.Cash},
+			[]any{"残差", r.Residual},
+			[]any{"净资产变化", r.NAVChange},
+			[]any{"收益率 (%)", r.Return},
+		),
+		groups("by_category", "按资产类别", "类别", r.ByCategory),
+		groups("by_currency", "按币种", "币种", r.ByCurrency),
+		symbols,
+	}
+}
@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeAttributionSumsToNAVChange(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		netDeposits  float64
+		residual     float64
+		wantUnpriced []string
+	}{
+		{
+			name:        "priced positions and FX trade",
+			file:        "attribution.xml",
+			netDeposits: 1000,
+		},
+		{
+			// 转入的 MSFT 按当日价格计入出入金；0700 期初没有价格，贡献计入残差
+			name:         "in-kind transfer and unpriced position",
+			file:         "attribution_transfer.xml",
+			netDeposits:  3000,
+			residual:     290.5,
+			wantUnpriced: []string{"U1:0700"},
+		},
+		{
+			// 两个账户都持有 0700，只有 U2 没有期初价格
+			name:         "unpriced position in one of two accounts",
+			file:         "attribution_accounts.xml",
+			netDeposits:  1000,
+			residual:     288,
+			wantUnpriced: []string{"U2:0700"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := AnalyzeAttribution(readTestStatements(t, tt.file), "", "")
+			if r == nil {
+				t.Fatal("report is nil")
+			}
+			sum := r.NetDeposits + r.Price + r.Income + r.Costs + r.FX + r.Cash + r.Residual
+			if !approxEqual(r.NAVChange, sum) {
+				t.Errorf("NAVChange = %g, components sum to %g", r.NAVChange, sum)
+			}
+			if !approxEqual(r.NetDeposits, tt.netDeposits) {
+				t.Errorf("NetDeposits = %g, want %g", r.NetDeposits, tt.netDeposits)
+			}
+			if !approxEqual(r.Residual, tt.residual) {
+				t.Errorf("Residual = %g, want %g", r.Residual, tt.residual)
+			}
+			if !slices.Equal(r.Unpriced, tt.wantUnpriced) {
+				t.Errorf("Unpriced = %v, want %v", r.Unpriced, tt.wantUnpriced)
+			}
+
+			var lines float64
+			for _, l := range r.BySymbol {
+				lines += l.Price + l.Income + l.Costs + l.FX + l.Cash
+			}
+			if !approxEqual(lines, r.Price+r.Income+r.Costs+r.FX+r.Cash) {
+				t.Errorf("by-symbol lines sum to %g, want %g", lines, r.Price+r.Income+r.Costs+r.FX+r.Cash)
+			}
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// 基准对比的状态
+const (
+	BenchmarkOK      = "ok"
+	BenchmarkNoNAV   = "no_nav"   // Flex Query 缺少 Net Asset Value (NAV) in Base 段
+	BenchmarkNoPrice = "no_price" // 价格文件不覆盖组合的第一个净资产日期
+	BenchmarkNoFX    = "no_fx"    // 价格币种不是基础货币，且数据中没有该币种的汇率
+)
+
+// PriceSeries 基准的本地收盘价序列，按日期升序
+type PriceSeries struct {
+	Name     string
+	Currency string   // 价格币种，为空时视为基础货币
+	dates    []string // YYYYMMDD
+	closes   []float64
+}
+
+// ReadPriceCSV 读取基准价格 CSV：需要 date 列，价格优先取 adj close（复权价，含分红），否则取 close。
+// Yahoo Finance 等导出的历史数据可直接使用
+func ReadPriceCSV(name string, r io.Reader) (*PriceSeries, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, i18n.Errorf("读取基准价格失败: %s: %w", name, err)
+	}
+	col := csvColumns(header)
+	priceCol := ""
+	for _, c := range []string{"adj close", "adj_close", "adjclose", "close"} {
+		if _, ok := col[c]; ok {
+			priceCol = c
+			break
+		}
+	}
+	if _, ok := col["date"]; !ok || priceCol == "" {
+		return nil, i18n.Errorf("基准价格 %s 需要 date 和 close 列", name)
+	}
+
+	prices := make(map[string]float64)
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, i18n.Errorf("读取基准价格失败: %s: %w", name, err)
+		}
+		line, _ := cr.FieldPos(0)
+		date := normalizeDate(csvField(col, rec, "date"))
+		if _, err := time.Parse("20060102", date); err != nil {
+			return nil, i18n.Errorf("基准价格 %s 第 %d 行: 日期无效: %s", name, line, csvField(col, rec, "date"))
+		}
+		price, err := strconv.ParseFloat(csvField(col, rec, priceCol), 64)
+		if err != nil || price <= 0 {
+			continue // 停牌、非交易日等缺失的价格（如 "null"）
+		}
+		prices[date] = price
+	}
+	if len(prices) == 0 {
+		return nil, i18n.Errorf("基准价格 %s 没有有效的价格", name)
+	}
+
+	p := &PriceSeries{Name: name}
+	for date := range prices {
+		p.dates = append(p.dates, date)
+	}
+	sort.Strings(p.dates)
+	for _, date := range p.dates {
+		p.closes = append(p.closes, prices[date])
+	}
+	return p, nil
+}
+
+// priceOn 某日的收盘价，非交易日取之前最近的一个
+func (p *PriceSeries) priceOn(date string) (float64, bool) {
+	i := sort.SearchStrings(p.dates, date)
+	if i < len(p.dates) && p.dates[i] == date {
+		return p.closes[i], true
+	}
+	if i == 0 {
+		return 0, false
+	}
+	return p.closes[i-1], true
+}
+
+// BenchmarkMonth 一个月的时间加权收益率（%），首月从第一个净资产日期开始
+type BenchmarkMonth struct {
+	Month     string  `json:"month"` // YYYY-MM
+	Portfolio float64 `json:"portfolio"`
+	Benchmark float64 `json:"benchmark"`
+	Relative  float64 `json:"relative"` // Portfolio - Benchmark
+}
+
+// BenchmarkComparison 组合与一个基准的对比。收益率为百分比；
+// Alpha、Beta、TrackingError 由月度收益率计算（Alpha、TrackingError 年化，无风险利率按 0），少于 2 个月时为 0
+type BenchmarkComparison struct {
+	Name            string           `json:"name"`
+	Status          string           `json:"status"` // ok、no_nav、no_price、no_fx
+	From            string           `json:"from"`
+	To              string           `json:"to"`
+	PortfolioReturn float64          `json:"portfolio_return"`
+	BenchmarkReturn float64          `json:"benchmark_return"`
+	EndingValue     float64          `json:"ending_value"`    // 期末净资产
+	BenchmarkValue  float64          `json:"benchmark_value"` // 期初净资产和每笔出入金都按当日价格买卖基准时的期末市值
+	Alpha           float64          `json:"alpha"`
+	Beta            float64          `json:"beta"`
+	TrackingError   float64          `json:"tracking_error"`
+	Months          []BenchmarkMonth `json:"months"`
+}
+
+// navPoint 一个报告日的净资产（各账户合计）和当天的外部出入金
+type navPoint struct {
+	date string
+	nav  float64
+	flow float64
+}
+
+// navSeries 按 EquitySummary 汇总各账户每日净资产，只保留所有账户都有数据的日期，
+// 并把出入金归入其后第一个净资产日期
+func navSeries(statements []flex.FlexStatement, from, to string) []navPoint {
+	byDate := make(map[string]map[string]float64) // 日期 → 账户 → 净资产
+	accounts := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, es := range stmt.EquitySummary {
+			date := normalizeDate(es.ReportDate)
+			if !inDateRange(date, from, to) {
+				continue
+			}
+			account := es.AccountID
+			if account == "" {
+				account = stmt.AccountID
+			}
+			if byDate[date] == nil {
+				byDate[date] = make(map[string]float64)
+			}
+			byDate[date][account] = es.Total
+			accounts[account] = true
+		}
+	}
+	var points []navPoint
+	for date, navs := range byDate {
+		if len(navs) < len(accounts) {
+			continue
+		}
+		p := navPoint{date: date}
+		for _, v := range navs {
+			p.nav += v
+		}
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].date < points[j].date })
+
+	for _, f := range externalFlows(statements) {
+		i := sort.Search(len(points), func(i int) bool { return points[i].date >= f.date })
+		if i > 0 && i < len(points) { // 第一个日期之前的出入金已反映在期初净资产中
+			points[i].flow += f.amount
+		}
+	}
+	return points
+}
+
+// externalFlow 一笔外部出入金（基础货币，入金为正）
+type externalFlow struct {
+	date   string
+	amount float64
+}
+
+// externalFlows 现金流水中的出入金和 Transfers 中的转账，多个 query 中重复的只计一次。
+// 持仓转入转出按转账时的市值计入，否则转入的持仓会被当作收益
+func externalFlows(statements []flex.FlexStatement) []externalFlow {
+	var flows []externalFlow
+	for _, ct := range uniqueCashTransactions(statements) {
+		if ct.Type == "Deposits/Withdrawals" || ct.Type == "Deposits & Withdrawals" {
+			flows = append(flows, externalFlow{cashDate(ct), toBase(ct.Amount, ct.FxRateToBase)})
+		}
+	}
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, tr := range stmt.Transfers {
+			id := stmt.AccountID + "|" + tr.TransactionID
+			amount := tr.Amount
+			if !isCashTransfer(tr) {
+				amount = transferValue(tr)
+			}
+			if amount == 0 || (tr.TransactionID != "" && seen[id]) {
+				continue
+			}
+			seen[id] = true
+			flows = append(flows, externalFlow{datePart(tr.DateTime), toBase(amount, tr.FxRateToBase)})
+		}
+	}
+	return flows
+}
+
+// CompareBenchmarks 用每日净资产计算组合的时间加权收益率，与各基准比较
+func CompareBenchmarks(statements []flex.FlexStatement, from, to string, benchmarks []*PriceSeries) []BenchmarkComparison {
+	if len(benchmarks) == 0 {
+		return nil
+	}
+	points := navSeries(statements, from, to)
+	fx := fxRateHistory(statements)
+
+	var results []BenchmarkComparison
+	for _, b := range benchmarks {
+		c := BenchmarkComparison{Name: b.Name, Status: BenchmarkNoNAV}
+		switch {
+		case len(points) < 2:
+		case b.Currency != "" && len(fx[b.Currency]) == 0:
+			c.Status = BenchmarkNoFX
+		default:
+			compareBenchmark(&c, points, b, fx)
+		}
+		results = append(results, c)
+	}
+	return results
+}
+
+// compareBenchmark 基准价格按当日汇率折算为基础货币后与组合比较
+func compareBenchmark(c *BenchmarkComparison, points []navPoint, b *PriceSeries, fx fxHistory) {
+	first, last := points[0], points[len(points)-1]
+	prices := make([]float64, len(points))
+	for i, p := range points {
+		price, ok := b.priceOn(p.date)
+		if !ok {
+			c.Status = BenchmarkNoPrice
+			return
+		}
+		if b.Currency != "" {
+			rate, _ := fx.rateOn(b.Currency, p.date) // 没有汇率的币种已在 CompareBenchmarks 中排除
+			price *= rate
+		}
+		prices[i] = price
+	}
+	c.Status = BenchmarkOK
+	c.From, c.To = isoDate(first.date), isoDate(last.date)
+	c.EndingValue = last.nav
+
+	// 期初净资产全部买入基准，之后每笔出入金按当日价格买入或卖出
+	units := first.nav / prices[0]
+	for i := 1; i < len(points); i++ {
+		units += points[i].flow / prices[i]
+	}
+	c.BenchmarkValue = units * prices[len(prices)-1]
+	c.BenchmarkReturn = (prices[len(prices)-1]/prices[0] - 1) * 100
+
+	// 组合每日收益：(当日净资产 - 当日出入金) / 前一日净资产，按月连乘
+	total := 1.0
+	var port, bench []float64
+	monthStart := 0
+	growth := 1.0
+	for i := 1; i < len(points); i++ {
+		if points[i-1].nav > 0 {
+			g := (points[i].nav - points[i].flow) / points[i-1].nav
+			growth *= g
+			total *= g
+		}
+		if i == len(points)-1 || monthOf(points[i+1].date) != monthOf(points[i].date) {
+			m := BenchmarkMonth{
+				Month:     monthOf(points[i].date),
+				Portfolio: (growth - 1) * 100,
+				Benchmark: (prices[i]/prices[monthStart] - 1) * 100,
+			}
+			m.Relative = m.Portfolio - m.Benchmark
+			c.Months = append(c.Months, m)
+			port = append(port, m.Portfolio/100)
+			bench = append(bench, m.Benchmark/100)
+			monthStart, growth = i, 1.0
+		}
+	}
+	c.PortfolioReturn = (total - 1) * 100
+
+	if len(c.Months) < 2 {
+		return
+	}
+	meanP, meanB := mean(port), mean(bench)
+	var cov, varB, varD float64
+	diffs := make([]float64, len(port))
+	for i := range port {
+		diffs[i] = port[i] - bench[i]
+	}
+	meanD := mean(diffs)
+	for i := range port {
+		cov += (port[i] - meanP) * (bench[i] - meanB)
+		varB += (bench[i] - meanB) * (bench[i] - meanB)
+		varD += (diffs[i] - meanD) * (diffs[i] - meanD)
+	}
+	n := float64(len(port) - 1)
+	if varB > 0 {
+		c.Beta = cov / varB
+	}
+	c.Alpha = (meanP - c.Beta*meanB) * 12 * 100
+	c.TrackingError = math.Sqrt(varD/n) * math.Sqrt(12) * 100
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// signedPercent 带符号的百分比，舍入误差（如 -0.00%）显示为 +0.00%
+func signedPercent(v float64) string {
+	if math.Abs(v) < 0.005 {
+		v = 0
+	}
+	return fmt.Sprintf("%+.2f%%", v)
+}
+
+// benchmarkStatusName 基准对比状态的说明
+func benchmarkStatusName(status string) string {
+	return i18n.T(map[string]string{
+		BenchmarkNoNAV:   "缺少每日净资产，请在 Flex Query 中勾选 Net Asset Value (NAV) in Base",
+		BenchmarkNoPrice: "价格数据不覆盖组合的起始日期",
+		BenchmarkNoFX:    "数据中没有价格币种的汇率",
+	}[status])
+}
+
+// printBenchmarks 打印基准对比（汇总报告的一部分）
+func printBenchmarks(comparisons []BenchmarkComparison) {
+	printSection("基准对比")
+	var rows [][]string
+	var notes []string
+	for _, c := range comparisons {
+		if c.Status != BenchmarkOK {
+			notes = append(notes, fmt.Sprintf("%s: %s", c.Name, benchmarkStatusName(c.Status)))
+			continue
+		}
+		stats := []string{"-", "-", "-"}
+		if len(c.Months) >= 2 {
+			stats = []string{signedPercent(c.Alpha), fmt.Sprintf("%.2f", c.Beta), fmt.Sprintf("%.2f%%", c.TrackingError)}
+		}
+		rows = append(rows, append([]string{
+			c.Name,
+			formatDate(normalizeDate(c.From)) + " ~ " + formatDate(normalizeDate(c.To)),
+			signedPercent(c.PortfolioReturn),
+			signedPercent(c.BenchmarkReturn),
+			signedPercent(c.PortfolioReturn - c.BenchmarkReturn),
+			fmtMoney(c.EndingValue),
+			fmtMoney(c.BenchmarkValue),
+		}, stats...))
+	}
+	if len(rows) > 0 {
+		printTable([]string{"基准", "期间", "组合收益", "基准收益", "超额收益", "期末净资产", "出入金都买基准", "Alpha (年化)", "Beta", "跟踪误差"}, rows)
+	}
+	for _, n := range notes {
+		fmt.Println(n)
+	}
+	if len(notes) > 0 {
+		fmt.Println()
+	}
+
+	for _, c := range comparisons {
+		if c.Status != BenchmarkOK || len(c.Months) == 0 {
+			continue
+		}
+		printSection(i18n.Sprintf("按月份对比 %s", c.Name))
+		var rows [][]string
+		for _, m := range c.Months {
+			rows = append(rows, []string{
+				formatMonth(m.Month),
+				signedPercent(m.Portfolio),
+				signedPercent(m.Benchmark),
+				signedPercent(m.Relative),
+			})
+		}
+		printTable([]string{"月份", "组合", "基准", "超额"}, rows)
+	}
+}
+
+// benchmarkTables 导出用的表：基准对比、按月份对比
+func benchmarkTables(comparisons []BenchmarkComparison) []Table {
+	summary := newTable("benchmarks", "基准对比", "基准", "状态", "起始日期", "结束日期", "组合收益 (%)", "基准收益 (%)",
+		"期末净资产", "出入金都买基准", "Alpha (年化 %)", "Beta", "跟踪误差 (%)")
+	months := newTable("benchmark_months", "按月份对比", "基准", "月份", "组合 (%)", "基准 (%)", "超额 (%)")
+	for _, c := range comparisons {
+		summary.Rows = append(summary.Rows, []any{c.Name, c.Status, c.From, c.To, c.PortfolioReturn, c.BenchmarkReturn,
+			c.EndingValue, c.BenchmarkValue, c.Alpha, c.Beta, c.TrackingError})
+		for _, m := range c.Months {
+			months.Rows = append(months.Rows, []any{c.Name, m.Month, m.Portfolio, m.Benchmark, m.Relative})
+		}
+	}
+	return []Table{summary, months}
+}
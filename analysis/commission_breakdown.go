@@ -0,0 +1,160 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// CommissionBreakdown 佣金构成（来自 UnbundledCommissionDetails，均为负数，已折算为基础货币）
+type CommissionBreakdown struct {
+	Symbol          string  `json:"symbol"`
+	Category        string  `json:"category"`
+	BrokerExecution float64 `json:"broker_execution"` // IBKR 执行佣金
+	BrokerClearing  float64 `json:"broker_clearing"`  // IBKR 清算费
+	Exchange        float64 `json:"exchange"`         // 交易所费（第三方执行）
+	Clearing        float64 `json:"clearing"`         // 第三方清算费
+	Regulatory      float64 `json:"regulatory"`       // 监管规费（FINRA TAF、Section 31 等）
+	Other           float64 `json:"other"`
+	Total           float64 `json:"total"`
+	Trades          int     `json:"trades"`
+}
+
+type CommissionBreakdownReport struct {
+	Total     CommissionBreakdown   `json:"total"`
+	BySymbol  []CommissionBreakdown `json:"by_symbol"`
+	Matched   int                   `json:"matched"`   // 关联到交易记录的明细数
+	Unmatched int                   `json:"unmatched"` // 找不到对应交易的明细数
+}
+
+// add 累加一条明细，金额按明细的 fxRateToBase 折算为基础货币。
+// thirdPartyRegulatoryCharge 已包含 FINRA TAF、Section 31 和其他规费，只有它缺失时才用各分项相加
+func (b *CommissionBreakdown) add(d flex.UnbundledCommissionDetail) {
+	regulatory := d.ThirdPartyRegulatoryCharge
+	if regulatory == 0 {
+		regulatory = d.RegFINRATradingActivityFee + d.RegSection31TransactionFee + d.RegOther
+	}
+	b.BrokerExecution += toBase(d.BrokerExecutionCharge, d.FxRateToBase)
+	b.BrokerClearing += toBase(d.BrokerClearingCharge, d.FxRateToBase)
+	b.Exchange += toBase(d.ThirdPartyExecutionCharge, d.FxRateToBase)
+	b.Clearing += toBase(d.ThirdPartyClearingCharge, d.FxRateToBase)
+	b.Regulatory += toBase(regulatory, d.FxRateToBase)
+	b.Other += toBase(d.Other, d.FxRateToBase)
+	b.Total += toBase(d.TotalCommission, d.FxRateToBase)
+	b.Trades++
+}
+
+// analyzeCommissionBreakdown 按 TransactionID / TradeID / OrderID 将佣金明细关联到交易
+func analyzeCommissionBreakdown(statements []flex.FlexStatement, trades []flex.Trade, from, to string) *CommissionBreakdownReport {
+	byTxn := make(map[string]flex.Trade)
+	byTradeID := make(map[string]flex.Trade)
+	byOrder := make(map[string]flex.Trade)
+	for _, t := range trades {
+		if t.TransactionID != "" {
+			byTxn[t.TransactionID] = t
+		}
+		if t.TradeID != "" {
+			byTradeID[t.TradeID] = t
+		}
+		if t.OrderID != "" {
+			byOrder[t.OrderID] = t
+		}
+	}
+
+	match := func(d flex.UnbundledCommissionDetail) (flex.Trade, bool) {
+		if t, ok := byTxn[d.TransactionID]; ok && d.TransactionID != "" {
+			return t, true
+		}
+		if t, ok := byTradeID[d.TradeID]; ok && d.TradeID != "" {
+			return t, true
+		}
+		if t, ok := byOrder[d.OrderID]; ok && d.OrderID != "" {
+			return t, true
+		}
+		return flex.Trade{}, false
+	}
+
+	report := &CommissionBreakdownReport{}
+	symbolMap := make(map[string]*CommissionBreakdown)
+	found := false
+
+	seen := make(map[string]bool) // 多个 query 中重复的明细只计一次
+	for _, stmt := range statements {
+		for _, d := range stmt.UnbundledCommissions {
+			if !inDateRange(datePart(d.DateTime), from, to) {
+				continue
+			}
+			if d.TransactionID != "" {
+				if seen[d.TransactionID] {
+					continue
+				}
+				seen[d.TransactionID] = true
+			}
+			found = true
+
+			symbol, category := d.Symbol, d.AssetCategory
+			if t, ok := match(d); ok {
+				report.Matched++
+				if symbol == "" {
+					symbol = t.Symbol
+				}
+				if category == "" {
+					category = t.AssetCategory
+				}
+			} else {
+				report.Unmatched++
+			}
+
+			report.Total.add(d)
+			b, ok := symbolMap[symbol]
+			if !ok {
+				b = &CommissionBreakdown{Symbol: symbol, Category: category}
+				symbolMap[symbol] = b
+			}
+			b.add(d)
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	for _, b := range symbolMap {
+		report.BySymbol = append(report.BySymbol, *b)
+	}
+	sort.Slice(report.BySymbol, func(i, j int) bool {
+		return report.BySymbol[i].Total < report.BySymbol[j].Total
+	})
+	return report
+}
+
+func printCommissionBreakdown(r *CommissionBreakdownReport) {
+	printSection("佣金构成")
+	row := func(b CommissionBreakdown, label string) []string {
+		return []string{
+			label,
+			fmtMoney(b.BrokerExecution),
+			fmtMoney(b.BrokerClearing),
+			fmtMoney(b.Exchange),
+			fmtMoney(b.Clearing),
+			fmtMoney(b.Regulatory),
+			fmtMoney(b.Other),
+			fmtMoney(b.Total),
+		}
+	}
+	printTable(
+		[]string{"标的", "IBKR 佣金", "IBKR 清算", "交易所费", "清算费", "监管费", "其他", "合计"},
+		func() [][]string {
+			var rows [][]string
+			for _, b := range r.BySymbol {
+				rows = append(rows, row(b, b.Symbol))
+			}
+			rows = append(rows, row(r.Total, i18n.T("合计")))
+			return rows
+		}(),
+	)
+	if r.Unmatched > 0 {
+		i18n.Printf("有 %d 条佣金明细未找到对应交易\n\n", r.Unmatched)
+	}
+}
@@ -0,0 +1,158 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+// IBKR Pro 美股/美股期权的两种计费方案（USD）。
+// 阶梯方案的交易所费、清算费按典型值估算，实际随交易所和流动性增减而变化，
+// 监管规费两种方案都会另外收取，这里不计入。
+const (
+	PlanFixed  = "fixed"
+	PlanTiered = "tiered"
+)
+
+var planNames = map[string]string{
+	PlanFixed:  "固定 (Fixed)",
+	PlanTiered: "阶梯 (Tiered)",
+}
+
+const (
+	fixedStockPerShare = 0.005
+	fixedStockMin      = 1.00
+	stockMaxPct        = 0.01 // 单笔佣金上限为成交额的 1%
+
+	tieredStockMin          = 0.35
+	tieredStockClearing     = 0.0002 // NSCC/DTC 清算费
+	tieredStockExchangeEst  = 0.0015 // 交易所费估算
+	optionMinPerOrder       = 1.00
+	tieredOptionExchangeEst = 0.20 // 每张合约交易所费估算
+	tieredOptionClearing    = 0.02 // OCC 清算费
+)
+
+// 阶梯方案：按当月累计股数/合约数确定费率
+type volumeTier struct {
+	upTo float64
+	rate float64
+}
+
+var tieredStockTiers = []volumeTier{
+	{300_000, 0.0035},
+	{3_000_000, 0.0020},
+	{20_000_000, 0.0015},
+	{100_000_000, 0.0010},
+	{math.Inf(1), 0.0005},
+}
+
+var tieredOptionTiers = []volumeTier{
+	{10_000, 0.65},
+	{50_000, 0.50},
+	{100_000, 0.25},
+	{math.Inf(1), 0.15},
+}
+
+type PlanSimulation struct {
+	Plan       string  `json:"plan"`
+	Commission float64 `json:"commission"` // 模拟佣金（负数，与 ibCommission 口径一致）
+	Actual     float64 `json:"actual"`     // 同一批交易的实际佣金
+	Diff       float64 `json:"difference"` // 模拟 - 实际，正数表示该方案更便宜
+	Trades     int     `json:"trades"`
+}
+
+func tierRate(tiers []volumeTier, monthVolume float64) float64 {
+	for _, t := range tiers {
+		if monthVolume <= t.upTo {
+			return t.rate
+		}
+	}
+	return tiers[len(tiers)-1].rate
+}
+
+// optionPremiumRate 固定方案下期权按权利金高低收费
+func optionPremiumRate(premium float64) float64 {
+	switch {
+	case premium >= 0.10:
+		return 0.65
+	case premium >= 0.05:
+		return 0.50
+	}
+	return 0.25
+}
+
+// simulatable 只模拟美元计价的股票/ETF 和期权
+func simulatable(t flex.Trade) bool {
+	if t.Currency != "USD" || t.TransactionType == "BookTrade" || t.Quantity == 0 {
+		return false
+	}
+	return t.AssetCategory == "STK" || t.AssetCategory == "OPT"
+}
+
+func fixedCommission(t flex.Trade) float64 {
+	qty := math.Abs(t.Quantity)
+	switch t.AssetCategory {
+	case "STK":
+		value := qty * t.TradePrice
+		c := math.Max(qty*fixedStockPerShare, fixedStockMin)
+		return math.Min(c, value*stockMaxPct)
+	case "OPT":
+		return math.Max(qty*optionPremiumRate(t.TradePrice), optionMinPerOrder)
+	}
+	return 0
+}
+
+func tieredCommission(t flex.Trade, monthVolume float64) float64 {
+	qty := math.Abs(t.Quantity)
+	switch t.AssetCategory {
+	case "STK":
+		value := qty * t.TradePrice
+		c := math.Max(qty*tierRate(tieredStockTiers, monthVolume), tieredStockMin)
+		c = math.Min(c, value*stockMaxPct)
+		return c + qty*(tieredStockClearing+tieredStockExchangeEst)
+	case "OPT":
+		c := math.Max(qty*tierRate(tieredOptionTiers, monthVolume), optionMinPerOrder)
+		return c + qty*(tieredOptionExchangeEst+tieredOptionClearing)
+	}
+	return 0
+}
+
+// simulatePlans 用固定和阶梯两种方案重算同一批交易的佣金。
+// 最低收费按每行成交计算；若需要按订单计算，先用按订单合并后的数据。
+func simulatePlans(trades []flex.Trade) []PlanSimulation {
+	sorted := make([]flex.Trade, 0, len(trades))
+	for _, t := range trades {
+		if simulatable(t) {
+			sorted = append(sorted, t)
+		}
+	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].DateTime < sorted[j].DateTime
+	})
+
+	fixed := PlanSimulation{Plan: PlanFixed}
+	tiered := PlanSimulation{Plan: PlanTiered}
+	monthVolume := make(map[string]float64) // 月份+类别 → 累计股数/合约数
+
+	for _, t := range sorted {
+		key := monthOf(t.TradeDate) + t.AssetCategory
+		monthVolume[key] += math.Abs(t.Quantity)
+
+		fixed.Commission -= fixedCommission(t)
+		tiered.Commission -= tieredCommission(t, monthVolume[key])
+		fixed.Actual += t.Commission
+		tiered.Actual += t.Commission
+		fixed.Trades++
+		tiered.Trades++
+	}
+
+	sims := []PlanSimulation{fixed, tiered}
+	for i := range sims {
+		sims[i].Diff = sims[i].Commission - sims[i].Actual
+	}
+	return sims
+}
@@ -2,23 +2,86 @@ package analysis
 
 import (
 	"fmt"
+	"math"
 	"sort"
 
 	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 )
 
 type SymbolCommission struct {
-	Symbol     string
-	Category   string
-	Commission float64
-	Trades     int
+	Symbol     string  `json:"symbol"`
+	Category   string  `json:"category"`
+	Commission float64 `json:"commission"`
+	Trades     int     `json:"trades"`
+}
+
+type CategoryCommission struct {
+	Category   string  `json:"category"`
+	Commission float64 `json:"commission"`
+}
+
+// CommissionBucket 某一维度下的佣金效率（金额均折算为基础货币）
+type CommissionBucket struct {
+	Key        string  `json:"key"`
+	Commission float64 `json:"commission"`
+	Notional   float64 `json:"notional"`
+	Quantity   float64 `json:"quantity"` // 股数或合约数，混合多种资产类别时为 0
+	Trades     int     `json:"trades"`
+	Bps        float64 `json:"bps"`      // 佣金占成交额的基点
+	PerUnit    float64 `json:"per_unit"` // 每股/每张合约佣金，只在同一资产类别内计算
+
+	category string // 桶内交易的资产类别，多种类别混合时为空
+	mixed    bool
+}
+
+type CommissionEfficiency struct {
+	TotalNotional float64            `json:"total_notional"`
+	AvgBps        float64            `json:"avg_bps"`
+	ByExchange    []CommissionBucket `json:"by_exchange"`
+	ByCategory    []CommissionBucket `json:"by_category"`
+	BySize        []CommissionBucket `json:"by_size"`
+	ByMonth       []CommissionBucket `json:"by_month"`
+	Plans         []PlanSimulation   `json:"plans"`
 }
 
 type CommissionReport struct {
-	BySymbol       []SymbolCommission
-	ByCategory     map[string]float64
-	TotalComm      float64
-	TotalTrades    int
+	BySymbol    []SymbolCommission         `json:"by_symbol"`
+	ByCategory  []CategoryCommission       `json:"by_category"`
+	TotalComm   float64                    `json:"total_commission"`
+	TotalTrades int                        `json:"total_trades"`
+	Efficiency  *CommissionEfficiency      `json:"efficiency,omitempty"`
+	Breakdown   *CommissionBreakdownReport `json:"breakdown,omitempty"` // 无 UnbundledCommissionDetails 段时为 nil
+}
+
+// 订单规模分档（按基础货币成交额）
+var sizeBuckets = []struct {
+	upTo  float64
+	label string
+}{
+	{1_000, "<1K"},
+	{10_000, "1K-10K"},
+	{50_000, "10K-50K"},
+	{100_000, "50K-100K"},
+	{math.Inf(1), ">=100K"},
+}
+
+func sizeBucket(notional float64) string {
+	for _, b := range sizeBuckets {
+		if notional < b.upTo {
+			return b.label
+		}
+	}
+	return sizeBuckets[len(sizeBuckets)-1].label
+}
+
+// tradeNotional 成交额 |数量 × 成交价 × 乘数|
+func tradeNotional(t flex.Trade) float64 {
+	mult := t.Multiplier
+	if mult == 0 {
+		mult = 1
+	}
+	return math.Abs(t.Quantity * t.TradePrice * mult)
 }
 
 func AnalyzeCommissions(statements []flex.FlexStatement, from, to string) *CommissionReport {
@@ -27,31 +90,34 @@ func AnalyzeCommissions(statements []flex.FlexStatement, from, to string) *Commi
 
 	var totalComm float64
 	var totalTrades int
+	var trades []flex.Trade
+	fx := fxRateHistory(statements)
 
-	for _, stmt := range statements {
-		for _, t := range stmt.Trades {
-			if !inDateRange(t.TradeDate, from, to) {
-				continue
-			}
+	for _, t := range uniqueTrades(statements) {
+		if !inDateRange(t.TradeDate, from, to) {
+			continue
+		}
+		trades = append(trades, t)
 
-			totalComm += t.Commission
-			totalTrades++
-			catMap[t.AssetCategory] += t.Commission
+		comm := commissionInBase(t, fx)
+		totalComm += comm
+		totalTrades++
+		catMap[t.AssetCategory] += comm
 
-			sc, ok := symbolMap[t.Symbol]
-			if !ok {
-				sc = &SymbolCommission{Symbol: t.Symbol, Category: t.AssetCategory}
-				symbolMap[t.Symbol] = sc
-			}
-			sc.Commission += t.Commission
-			sc.Trades++
+		sc, ok := symbolMap[t.Symbol]
+		if !ok {
+			sc = &SymbolCommission{Symbol: t.Symbol, Category: t.AssetCategory}
+			symbolMap[t.Symbol] = sc
 		}
+		sc.Commission += comm
+		sc.Trades++
 	}
 
 	report := &CommissionReport{
-		ByCategory:  catMap,
 		TotalComm:   totalComm,
 		TotalTrades: totalTrades,
+		Efficiency:  analyzeCommissionEfficiency(trades, fx),
+		Breakdown:   analyzeCommissionBreakdown(statements, trades, from, to),
 	}
 
 	for _, sc := range symbolMap {
@@ -61,34 +127,203 @@ func AnalyzeCommissions(statements []flex.FlexStatement, from, to string) *Commi
 		return report.BySymbol[i].Commission < report.BySymbol[j].Commission // 佣金为负数，按绝对值排序
 	})
 
+	for cat, comm := range catMap {
+		report.ByCategory = append(report.ByCategory, CategoryCommission{Category: cat, Commission: comm})
+	}
+	sort.Slice(report.ByCategory, func(i, j int) bool {
+		if report.ByCategory[i].Commission != report.ByCategory[j].Commission {
+			return report.ByCategory[i].Commission < report.ByCategory[j].Commission
+		}
+		return report.ByCategory[i].Category < report.ByCategory[j].Category
+	})
+
 	return report
 }
 
+func analyzeCommissionEfficiency(trades []flex.Trade, fx fxHistory) *CommissionEfficiency {
+	exchangeMap := make(map[string]*CommissionBucket)
+	catMap := make(map[string]*CommissionBucket)
+	sizeMap := make(map[string]*CommissionBucket)
+	monthMap := make(map[string]*CommissionBucket)
+
+	eff := &CommissionEfficiency{}
+	var totalComm float64
+
+	add := func(m map[string]*CommissionBucket, key, category string, comm, notional, qty float64) {
+		b, ok := m[key]
+		if !ok {
+			b = &CommissionBucket{Key: key, category: category}
+			m[key] = b
+		}
+		// 股数和合约数不能相加，桶内混有多种资产类别时不计算每单位佣金
+		if b.category != category {
+			b.mixed = true
+		}
+		b.Commission += comm
+		b.Notional += notional
+		b.Quantity += qty
+		b.Trades++
+	}
+
+	for _, t := range trades {
+		notional := toBase(tradeNotional(t), t.FxRateToBase)
+		if notional == 0 {
+			continue // 到期、行权等无成交额的记录
+		}
+		comm := commissionInBase(t, fx)
+		qty := math.Abs(t.Quantity)
+
+		exchange := t.Exchange
+		if exchange == "" {
+			exchange = "-"
+		}
+		add(exchangeMap, exchange, t.AssetCategory, comm, notional, qty)
+		add(catMap, t.AssetCategory, t.AssetCategory, comm, notional, qty)
+		add(sizeMap, sizeBucket(notional), t.AssetCategory, comm, notional, qty)
+		add(monthMap, monthOf(t.TradeDate), t.AssetCategory, comm, notional, qty)
+
+		eff.TotalNotional += notional
+		totalComm += comm
+	}
+
+	if eff.TotalNotional > 0 {
+		eff.AvgBps = math.Abs(totalComm) / eff.TotalNotional * 10000
+	}
+
+	collect := func(m map[string]*CommissionBucket) []CommissionBucket {
+		var buckets []CommissionBucket
+		for _, b := range m {
+			b.Bps = math.Abs(b.Commission) / b.Notional * 10000
+			if b.mixed {
+				b.Quantity = 0
+			} else if b.Quantity > 0 {
+				b.PerUnit = math.Abs(b.Commission) / b.Quantity
+			}
+			buckets = append(buckets, *b)
+		}
+		return buckets
+	}
+
+	eff.ByExchange = collect(exchangeMap)
+	sort.Slice(eff.ByExchange, func(i, j int) bool {
+		return eff.ByExchange[i].Notional > eff.ByExchange[j].Notional
+	})
+	eff.ByCategory = collect(catMap)
+	sort.Slice(eff.ByCategory, func(i, j int) bool {
+		return eff.ByCategory[i].Notional > eff.ByCategory[j].Notional
+	})
+
+	eff.BySize = collect(sizeMap)
+	sizeOrder := make(map[string]int)
+	for i, b := range sizeBuckets {
+		sizeOrder[b.label] = i
+	}
+	sort.Slice(eff.BySize, func(i, j int) bool {
+		return sizeOrder[eff.BySize[i].Key] < sizeOrder[eff.BySize[j].Key]
+	})
+
+	eff.ByMonth = collect(monthMap)
+	sort.Slice(eff.ByMonth, func(i, j int) bool {
+		return eff.ByMonth[i].Key < eff.ByMonth[j].Key
+	})
+
+	eff.Plans = simulatePlans(trades)
+	return eff
+}
+
+// fmtPerUnit 每股/张佣金，混合资产类别的桶显示为 "-"
+func fmtPerUnit(b CommissionBucket) string {
+	if b.mixed {
+		return "-"
+	}
+	return fmt.Sprintf("%.4f", b.PerUnit)
+}
+
+func printCommissionBuckets(title, keyHeader string, buckets []CommissionBucket, formatKey func(string) string) {
+	if len(buckets) == 0 {
+		return
+	}
+	printSection(title)
+	printTable(
+		[]string{keyHeader, "佣金", "成交额", "费率(bps)", "每股/张", "交易数"},
+		func() [][]string {
+			var rows [][]string
+			for _, b := range buckets {
+				rows = append(rows, []string{
+					formatKey(b.Key),
+					fmtMoney(b.Commission),
+					fmtMoney(b.Notional),
+					fmtMoney(b.Bps),
+					fmtPerUnit(b),
+					fmt.Sprintf("%d", b.Trades),
+				})
+			}
+			return rows
+		}(),
+	)
+}
+
 func PrintCommissionReport(r *CommissionReport) {
-	fmt.Println("═══ 佣金统计 ═══")
-	fmt.Printf("总佣金:     %.2f\n", r.TotalComm)
-	fmt.Printf("总交易数:   %d\n", r.TotalTrades)
+	printTitle("佣金统计")
+	i18n.Printf("总佣金:     %s\n", fmtMoney(r.TotalComm))
+	i18n.Printf("总交易数:   %d\n", r.TotalTrades)
 	if r.TotalTrades > 0 {
-		fmt.Printf("平均佣金:   %.2f\n", r.TotalComm/float64(r.TotalTrades))
+		i18n.Printf("平均佣金:   %s\n", fmtMoney(r.TotalComm/float64(r.TotalTrades)))
+	}
+	if eff := r.Efficiency; eff != nil && eff.TotalNotional > 0 {
+		i18n.Printf("总成交额:   %s\n", fmtMoney(eff.TotalNotional))
+		i18n.Printf("平均费率:   %s bps\n", fmtMoney(eff.AvgBps))
 	}
 	fmt.Println()
 
-	if len(r.ByCategory) > 0 {
-		fmt.Println("── 按资产类别 ──")
+	if eff := r.Efficiency; eff != nil && len(eff.ByCategory) > 0 {
+		noop := func(k string) string { return k }
+		printCommissionBuckets("按资产类别", "类别", eff.ByCategory, noop)
+		printCommissionBuckets("按交易所", "交易所", eff.ByExchange, noop)
+		printCommissionBuckets("按订单规模", "成交额", eff.BySize, noop)
+		printCommissionBuckets("按月份", "月份", eff.ByMonth, formatMonth)
+	} else if len(r.ByCategory) > 0 {
+		printSection("按资产类别")
 		printTable(
 			[]string{"类别", "佣金"},
 			func() [][]string {
 				var rows [][]string
-				for cat, comm := range r.ByCategory {
-					rows = append(rows, []string{cat, fmt.Sprintf("%.2f", comm)})
+				for _, c := range r.ByCategory {
+					rows = append(rows, []string{c.Category, fmtMoney(c.Commission)})
+				}
+				return rows
+			}(),
+		)
+	}
+
+	if eff := r.Efficiency; eff != nil && len(eff.Plans) > 0 {
+		i18n.Println("── 计费方案模拟（美股/美股期权）──")
+		printTable(
+			[]string{"方案", "模拟佣金", "实际佣金", "差额", "交易数"},
+			func() [][]string {
+				var rows [][]string
+				for _, p := range eff.Plans {
+					rows = append(rows, []string{
+						i18n.T(planNames[p.Plan]),
+						fmtMoney(p.Commission),
+						fmtMoney(p.Actual),
+						fmtPnL(p.Diff),
+						fmt.Sprintf("%d", p.Trades),
+					})
 				}
 				return rows
 			}(),
 		)
+		i18n.Println("差额为正表示该方案比实际更便宜；阶梯方案的交易所/清算费为估算值。")
+		fmt.Println()
+	}
+
+	if r.Breakdown != nil {
+		printCommissionBreakdown(r.Breakdown)
 	}
 
 	if len(r.BySymbol) > 0 {
-		fmt.Println("── 按标的 ──")
+		printSection("按标的")
 		printTable(
 			[]string{"标的", "类别", "佣金", "交易数"},
 			func() [][]string {
@@ -97,7 +332,7 @@ func PrintCommissionReport(r *CommissionReport) {
 					rows = append(rows, []string{
 						s.Symbol,
 						s.Category,
-						fmt.Sprintf("%.2f", s.Commission),
+						fmtMoney(s.Commission),
 						fmt.Sprintf("%d", s.Trades),
 					})
 				}
@@ -106,3 +341,68 @@ func PrintCommissionReport(r *CommissionReport) {
 		)
 	}
 }
+
+func bucketTable(name, title, keyHeader string, buckets []CommissionBucket) Table {
+	t := newTable(name, title, keyHeader, "佣金", "成交额", "费率(bps)", "每股/张", "交易数")
+	for _, b := range buckets {
+		var perUnit any = b.PerUnit
+		if b.mixed {
+			perUnit = nil
+		}
+		t.Rows = append(t.Rows, []any{b.Key, b.Commission, b.Notional, b.Bps, perUnit, b.Trades})
+	}
+	return t
+}
+
+// Tables 导出用的表：汇总、按标的、按资产类别，以及佣金效率、计费方案模拟和佣金构成（有数据时）
+func (r *CommissionReport) Tables() []Table {
+	totals := totalsTable(
+		[]any{"总佣金", r.TotalComm},
+		[]any{"总交易数", r.TotalTrades},
+	)
+
+	bySymbol := newTable("by_symbol", "按标的", "标的", "类别", "佣金", "交易数")
+	for _, s := range r.BySymbol {
+		bySymbol.Rows = append(bySymbol.Rows, []any{s.Symbol, s.Category, s.Commission, s.Trades})
+	}
+
+	byCategory := newTable("by_category", "按资产类别", "类别", "佣金")
+	for _, c := range r.ByCategory {
+		byCategory.Rows = append(byCategory.Rows, []any{c.Category, c.Commission})
+	}
+
+	tables := []Table{totals, bySymbol, byCategory}
+
+	if eff := r.Efficiency; eff != nil && eff.TotalNotional > 0 {
+		tables[0].Rows = append(tables[0].Rows,
+			[]any{i18n.T("总成交额"), eff.TotalNotional},
+			[]any{i18n.T("平均费率 (bps)"), eff.AvgBps},
+		)
+		tables = append(tables,
+			bucketTable("efficiency_by_category", "费率按资产类别", "类别", eff.ByCategory),
+			bucketTable("by_exchange", "按交易所", "交易所", eff.ByExchange),
+			bucketTable("by_size", "按订单规模", "成交额", eff.BySize),
+			bucketTable("by_month", "按月份", "月份", eff.ByMonth),
+		)
+		if len(eff.Plans) > 0 {
+			plans := newTable("plans", "计费方案模拟", "方案", "模拟佣金", "实际佣金", "差额", "交易数")
+			for _, p := range eff.Plans {
+				plans.Rows = append(plans.Rows, []any{i18n.T(planNames[p.Plan]), p.Commission, p.Actual, p.Diff, p.Trades})
+			}
+			tables = append(tables, plans)
+		}
+	}
+
+	if b := r.Breakdown; b != nil {
+		breakdown := newTable("breakdown", "佣金构成", "标的", "IBKR 佣金", "IBKR 清算", "交易所费", "清算费", "监管费", "其他", "合计")
+		row := func(c CommissionBreakdown, label string) []any {
+			return []any{label, c.BrokerExecution, c.BrokerClearing, c.Exchange, c.Clearing, c.Regulatory, c.Other, c.Total}
+		}
+		for _, c := range b.BySymbol {
+			breakdown.Rows = append(breakdown.Rows, row(c, c.Symbol))
+		}
+		breakdown.Rows = append(breakdown.Rows, row(b.Total, i18n.T("合计")))
+		tables = append(tables, breakdown)
+	}
+	return tables
+}
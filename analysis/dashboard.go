@@ -0,0 +1,202 @@
+package analysis
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// DashboardPage serve 的一个页面
+type DashboardPage struct {
+	Path    string // URL 路径
+	Title   string // 导航中的名称（中文原文，显示时翻译）
+	APIKind string // 页面数据对应的 JSON 接口 /api/<kind>
+}
+
+// DashboardPages serve 提供的页面，顺序即导航顺序
+var DashboardPages = []DashboardPage{
+	{"/", "账户概览", "summary"},
+	{"/positions", "持仓", "summary"},
+	{"/pnl", "盈亏", "pnl"},
+	{"/dividends", "股息", "dividends"},
+	{"/commissions", "佣金", "commissions"},
+}
+
+const dashboardStyle = `
+nav { display: flex; gap: 16px; border-bottom: 1px solid #ddd; padding-bottom: 10px; }
+nav a { color: #4e79a7; text-decoration: none; }
+nav a.active { color: #222; font-weight: 600; }
+form.range { display: flex; gap: 8px; align-items: center; margin-top: 14px; font-size: 13px; }
+form.range input { font: inherit; padding: 2px 4px; }
+`
+
+// RenderDashboardPage 渲染 serve 的页面，from/to 为页面上选择的日期范围（YYYY-MM-DD 或 YYYYMMDD，可为空）。
+// path 不是 DashboardPages 中的页面时返回 false
+func RenderDashboardPage(path string, statements []flex.FlexStatement, from, to string, opts Options) (string, bool) {
+	var page DashboardPage
+	for _, p := range DashboardPages {
+		if p.Path == path {
+			page = p
+		}
+	}
+	if page.Path == "" {
+		return "", false
+	}
+
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", isoDate(normalizeDate(from)))
+	}
+	if to != "" {
+		query.Set("to", isoDate(normalizeDate(to)))
+	}
+	withQuery := func(path string) string {
+		if len(query) == 0 {
+			return path
+		}
+		return path + "?" + query.Encode()
+	}
+
+	var b strings.Builder
+	htmlHead(&b, i18n.T("IBKR 账户报告")+" · "+i18n.T(page.Title), dashboardStyle)
+
+	b.WriteString("<nav>\n")
+	for _, p := range DashboardPages {
+		class := ""
+		if p.Path == page.Path {
+			class = ` class="active"`
+		}
+		fmt.Fprintf(&b, "<a href=\"%s\"%s>%s</a>\n", html.EscapeString(withQuery(p.Path)), class, html.EscapeString(i18n.T(p.Title)))
+	}
+	b.WriteString("</nav>\n")
+
+	// 日期范围：GET 表单，参数与命令行的 --from/--to 相同
+	fmt.Fprintf(&b, "<form class=\"range\" method=\"get\" action=\"%s\">\n", html.EscapeString(page.Path))
+	fmt.Fprintf(&b, "<label>%s <input type=\"date\" name=\"from\" value=\"%s\"></label>\n",
+		html.EscapeString(i18n.T("起始日期")), html.EscapeString(query.Get("from")))
+	fmt.Fprintf(&b, "<label>%s <input type=\"date\" name=\"to\" value=\"%s\"></label>\n",
+		html.EscapeString(i18n.T("结束日期")), html.EscapeString(query.Get("to")))
+	fmt.Fprintf(&b, "<button type=\"submit\">%s</button>\n", html.EscapeString(i18n.T("查询")))
+	fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(page.Path), html.EscapeString(i18n.T("全部")))
+	fmt.Fprintf(&b, "<a href=\"%s\">JSON</a>\n", html.EscapeString(withQuery("/api/"+page.APIKind)))
+	b.WriteString("</form>\n")
+
+	htmlHeading(&b, "h1", page.Title)
+	periodFrom, periodTo := reportPeriod(statements)
+	if from != "" {
+		periodFrom = normalizeDate(from)
+	}
+	if to != "" {
+		periodTo = normalizeDate(to)
+	}
+	htmlMeta(&b, i18n.Sprintf("报告期间：%s — %s", formatDate(periodFrom), formatDate(periodTo)))
+
+	switch page.Path {
+	case "/":
+		summary := AnalyzeSummary(statements, from, to, opts)
+		htmlSummaryCards(&b, summary)
+		htmlPnLCharts(&b, AnalyzePnL(statements, from, to, opts))
+		if len(summary.Positions) > 0 {
+			htmlHeading(&b, "h2", "持仓分布")
+			fmt.Fprintf(&b, "<div class=\"chart\">%s</div>\n", svgDonut(allocationPoints(summary.Positions)))
+		}
+
+	case "/positions":
+		summary := AnalyzeSummary(statements, from, to, opts)
+		if len(summary.Positions) == 0 {
+			htmlMeta(&b, i18n.T("无持仓"))
+		}
+		htmlPositions(&b, summary)
+
+	case "/pnl":
+		pnl := AnalyzePnL(statements, from, to, opts)
+		htmlPnLCharts(&b, pnl)
+		htmlPnLBySymbol(&b, pnl)
+		if len(pnl.ByMonth) > 0 {
+			htmlHeading(&b, "h2", "按月份")
+			var rows [][]string
+			for _, m := range pnl.ByMonth {
+				rows = append(rows, []string{formatMonth(m.Period), fmtPnL(m.RealizedPnL), fmt.Sprintf("%d", m.Trades), fmtMoney(m.Commission)})
+			}
+			htmlTable(&b, []string{"月份", "已实现P&L", "交易数", "佣金"}, rows, 1)
+		}
+
+	case "/dividends":
+		divs := AnalyzeDividends(statements, from, to)
+		if len(divs.ByMonth) == 0 {
+			htmlMeta(&b, i18n.T("无股息记录"))
+		}
+		htmlDividends(&b, divs)
+		if len(divs.ByMonth) > 0 {
+			htmlHeading(&b, "h2", "按月份")
+			var rows [][]string
+			for _, m := range divs.ByMonth {
+				rows = append(rows, []string{formatMonth(m.Period), fmtMoney(m.Gross), fmtMoney(m.Withholding), fmtMoney(m.Net)})
+			}
+			htmlTable(&b, []string{"月份", "税前股息", "预扣税", "净收入"}, rows)
+		}
+
+	case "/commissions":
+		htmlCommissions(&b, AnalyzeCommissions(statements, from, to))
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	return b.String(), true
+}
+
+// htmlCommissions 佣金汇总、按类别/交易所/月份的费率和按标的明细
+func htmlCommissions(b *strings.Builder, r *CommissionReport) {
+	b.WriteString("<div class=\"cards\">\n")
+	htmlCard(b, "总佣金", fmtMoney(r.TotalComm), 0)
+	htmlCard(b, "交易数", fmt.Sprintf("%d", r.TotalTrades), 0)
+	if eff := r.Efficiency; eff != nil && eff.TotalNotional > 0 {
+		htmlCard(b, "成交额", fmtMoney(eff.TotalNotional), 0)
+		htmlCard(b, "平均费率 (bps)", fmtMoney(eff.AvgBps), 0)
+	}
+	b.WriteString("</div>\n")
+
+	buckets := func(title, keyHeader string, list []CommissionBucket, formatKey func(string) string) {
+		if len(list) == 0 {
+			return
+		}
+		htmlHeading(b, "h2", title)
+		var rows [][]string
+		for _, c := range list {
+			rows = append(rows, []string{
+				formatKey(c.Key),
+				fmtMoney(c.Commission),
+				fmtMoney(c.Notional),
+				fmtMoney(c.Bps),
+				fmtPerUnit(c),
+				fmt.Sprintf("%d", c.Trades),
+			})
+		}
+		htmlTable(b, []string{keyHeader, "佣金", "成交额", "费率(bps)", "每股/张", "交易数"}, rows)
+	}
+	if eff := r.Efficiency; eff != nil && len(eff.ByCategory) > 0 {
+		noop := func(k string) string { return k }
+		buckets("按资产类别", "类别", eff.ByCategory, noop)
+		buckets("按交易所", "交易所", eff.ByExchange, noop)
+		buckets("按月份", "月份", eff.ByMonth, formatMonth)
+	} else if len(r.ByCategory) > 0 {
+		htmlHeading(b, "h2", "按资产类别")
+		var rows [][]string
+		for _, c := range r.ByCategory {
+			rows = append(rows, []string{c.Category, fmtMoney(c.Commission)})
+		}
+		htmlTable(b, []string{"类别", "佣金"}, rows)
+	}
+
+	if len(r.BySymbol) > 0 {
+		htmlHeading(b, "h2", "按标的")
+		var rows [][]string
+		for _, s := range r.BySymbol {
+			rows = append(rows, []string{s.Symbol, s.Category, fmtMoney(s.Commission), fmt.Sprintf("%d", s.Trades)})
+		}
+		htmlTable(b, []string{"标的", "类别", "佣金", "交易数"}, rows)
+	}
+}
@@ -0,0 +1,412 @@
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// FieldChange 修改过的记录中的一个字段，Field 为 Flex XML 中的属性名
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// RecordChange 按 TransactionID 比较出的一条交易或现金流水变化
+type RecordChange struct {
+	Change        string        `json:"change"` // added、removed、amended
+	TransactionID string        `json:"transaction_id"`
+	Date          string        `json:"date"`
+	Symbol        string        `json:"symbol"`
+	Description   string        `json:"description"`
+	Amount        float64       `json:"amount"` // 交易为 proceeds，现金流水为 amount（原币种）
+	Currency      string        `json:"currency"`
+	Restated      bool          `json:"restated"` // IBKR 更正了旧快照期间内的记录（修改、补录或撤销）
+	Fields        []FieldChange `json:"fields,omitempty"`
+}
+
+// PositionChange 持仓数量或市值的变化
+type PositionChange struct {
+	AccountID   string  `json:"account_id"`
+	Symbol      string  `json:"symbol"`
+	Currency    string  `json:"currency"`
+	OldQuantity float64 `json:"old_quantity"`
+	NewQuantity float64 `json:"new_quantity"`
+	OldValue    float64 `json:"old_value"`
+	NewValue    float64 `json:"new_value"`
+}
+
+// CashChange 某币种期末现金的变化，BASE_SUMMARY 为基础货币合计
+type CashChange struct {
+	AccountID string  `json:"account_id"`
+	Currency  string  `json:"currency"`
+	Old       float64 `json:"old"`
+	New       float64 `json:"new"`
+	Change    float64 `json:"change"`
+}
+
+// SnapshotDiff 两次拉取的快照之间的差异
+type SnapshotDiff struct {
+	OldPeriodFrom    string           `json:"old_period_from"`
+	OldPeriodTo      string           `json:"old_period_to"`
+	NewPeriodFrom    string           `json:"new_period_from"`
+	NewPeriodTo      string           `json:"new_period_to"`
+	Trades           []RecordChange   `json:"trades"`
+	CashTransactions []RecordChange   `json:"cash_transactions"`
+	Positions        []PositionChange `json:"positions"`
+	Cash             []CashChange     `json:"cash"`
+	Restated         int              `json:"restated"` // 被更正的记录数
+}
+
+// DiffSnapshots 比较两个快照。交易和现金流水按 TransactionID 对应：
+// 只在新快照中的为 added，只在旧快照中的为 removed，字段不同的为 amended。
+// 365 天窗口前移导致旧记录不再出现的不算 removed；记录日期落在旧快照期间内的 added/removed 和所有 amended
+// 标记为 restated（IBKR 的更正）
+func DiffSnapshots(oldStatements, newStatements []flex.FlexStatement) *SnapshotDiff {
+	diff := &SnapshotDiff{}
+	oldFrom, oldTo := reportPeriod(oldStatements)
+	newFrom, newTo := reportPeriod(newStatements)
+	oldFrom, oldTo, newFrom, newTo = normalizeDate(oldFrom), normalizeDate(oldTo), normalizeDate(newFrom), normalizeDate(newTo)
+	diff.OldPeriodFrom, diff.OldPeriodTo = isoDate(oldFrom), isoDate(oldTo)
+	diff.NewPeriodFrom, diff.NewPeriodTo = isoDate(newFrom), isoDate(newTo)
+
+	// 日期在两个快照共同覆盖的期间内，记录的出现或消失才有意义
+	inOld := func(date string) bool { return inDateRange(date, oldFrom, oldTo) }
+	inNew := func(date string) bool { return inDateRange(date, newFrom, newTo) }
+
+	trade := func(t flex.Trade, change string) RecordChange {
+		return RecordChange{
+			Change: change, TransactionID: t.TransactionID, Date: isoDate(normalizeDate(t.TradeDate)),
+			Symbol: t.Symbol, Description: t.Description, Amount: t.Proceeds, Currency: t.Currency,
+		}
+	}
+	oldTrades := make(map[string]flex.Trade)
+	for _, t := range uniqueTrades(oldStatements) {
+		oldTrades[tradeEntryID(t)] = t
+	}
+	for _, t := range uniqueTrades(newStatements) {
+		id := tradeEntryID(t)
+		old, ok := oldTrades[id]
+		delete(oldTrades, id)
+		switch {
+		case !ok:
+			c := trade(t, "added")
+			c.Restated = inOld(t.TradeDate)
+			diff.Trades = append(diff.Trades, c)
+		default:
+			if fields := diffFields(old, t); len(fields) > 0 {
+				c := trade(t, "amended")
+				c.Restated, c.Fields = true, fields
+				diff.Trades = append(diff.Trades, c)
+			}
+		}
+	}
+	for _, t := range oldTrades {
+		if inNew(t.TradeDate) {
+			c := trade(t, "removed")
+			c.Restated = true
+			diff.Trades = append(diff.Trades, c)
+		}
+	}
+
+	cash := func(ct flex.CashTransaction, change string) RecordChange {
+		return RecordChange{
+			Change: change, TransactionID: ct.TransactionID, Date: isoDate(cashDate(ct)),
+			Symbol: ct.Symbol, Description: ct.Description, Amount: ct.Amount, Currency: ct.Currency,
+		}
+	}
+	oldCash := make(map[string]flex.CashTransaction)
+	for _, ct := range uniqueCashTransactions(oldStatements) {
+		oldCash[cashEntryID(ct)] = ct
+	}
+	for _, ct := range uniqueCashTransactions(newStatements) {
+		id := cashEntryID(ct)
+		old, ok := oldCash[id]
+		delete(oldCash, id)
+		switch {
+		case !ok:
+			c := cash(ct, "added")
+			c.Restated = inOld(cashDate(ct))
+			diff.CashTransactions = append(diff.CashTransactions, c)
+		default:
+			if fields := diffFields(old, ct); len(fields) > 0 {
+				c := cash(ct, "amended")
+				c.Restated, c.Fields = true, fields
+				diff.CashTransactions = append(diff.CashTransactions, c)
+			}
+		}
+	}
+	for _, ct := range oldCash {
+		if inNew(cashDate(ct)) {
+			c := cash(ct, "removed")
+			c.Restated = true
+			diff.CashTransactions = append(diff.CashTransactions, c)
+		}
+	}
+
+	for _, list := range [][]RecordChange{diff.Trades, diff.CashTransactions} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Date != list[j].Date {
+				return list[i].Date < list[j].Date
+			}
+			return list[i].TransactionID < list[j].TransactionID
+		})
+		for _, c := range list {
+			if c.Restated {
+				diff.Restated++
+			}
+		}
+	}
+
+	diff.Positions = diffPositions(latestPositions(oldStatements), latestPositions(newStatements))
+	diff.Cash = diffCash(latestCashReport(oldStatements), latestCashReport(newStatements))
+	return diff
+}
+
+// diffFields 逐个比较 Flex 记录的属性，返回不同的字段
+func diffFields(old, new any) []FieldChange {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	var fields []FieldChange
+	for i := range ov.NumField() {
+		name, _, _ := strings.Cut(ov.Type().Field(i).Tag.Get("xml"), ",")
+		a, b := ov.Field(i), nv.Field(i)
+		if a.Kind() == reflect.Float64 {
+			if a.Float() != b.Float() {
+				fields = append(fields, FieldChange{name, strconv.FormatFloat(a.Float(), 'f', -1, 64), strconv.FormatFloat(b.Float(), 'f', -1, 64)})
+			}
+			continue
+		}
+		if a.Interface() != b.Interface() {
+			fields = append(fields, FieldChange{name, fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface())})
+		}
+	}
+	return fields
+}
+
+// latestPositions 最近报告日的 SUMMARY 持仓，按 账户|代码 索引
+func latestPositions(statements []flex.FlexStatement) map[string]flex.OpenPosition {
+	latest := make(map[string]string) // 账户 → 最近报告日
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			latest[stmt.AccountID] = max(latest[stmt.AccountID], normalizeDate(op.ReportDate))
+		}
+	}
+	positions := make(map[string]flex.OpenPosition)
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			if op.LevelOfDetail != "LOT" && normalizeDate(op.ReportDate) == latest[stmt.AccountID] {
+				positions[stmt.AccountID+"|"+op.Symbol] = op
+			}
+		}
+	}
+	return positions
+}
+
+func diffPositions(old, new map[string]flex.OpenPosition) []PositionChange {
+	keys := make(map[string]bool)
+	for k := range old {
+		keys[k] = true
+	}
+	for k := range new {
+		keys[k] = true
+	}
+
+	var changes []PositionChange
+	for k := range keys {
+		o, n := old[k], new[k]
+		if o.Position == n.Position && o.PositionValue == n.PositionValue {
+			continue
+		}
+		account, symbol, _ := strings.Cut(k, "|")
+		currency := n.Currency
+		if currency == "" {
+			currency = o.Currency
+		}
+		changes = append(changes, PositionChange{
+			AccountID: account, Symbol: symbol, Currency: currency,
+			OldQuantity: o.Position, NewQuantity: n.Position,
+			OldValue: o.PositionValue, NewValue: n.PositionValue,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].AccountID != changes[j].AccountID {
+			return changes[i].AccountID < changes[j].AccountID
+		}
+		return changes[i].Symbol < changes[j].Symbol
+	})
+	return changes
+}
+
+// latestCashReport 每个账户最近一期 CashReport 的期末现金，按 账户|币种 索引
+func latestCashReport(statements []flex.FlexStatement) map[string]float64 {
+	latest := make(map[string]*flex.FlexStatement)
+	for i := range statements {
+		stmt := &statements[i]
+		if prev := latest[stmt.AccountID]; len(stmt.CashReport) > 0 && (prev == nil || stmt.ToDate > prev.ToDate) {
+			latest[stmt.AccountID] = stmt
+		}
+	}
+	cash := make(map[string]float64)
+	for account, stmt := range latest {
+		for _, cr := range stmt.CashReport {
+			cash[account+"|"+cr.Currency] = cr.EndingCash
+		}
+	}
+	return cash
+}
+
+// earliestCashReport 每个账户最早一期 CashReport 的期初现金，按 账户|币种 索引
+func earliestCashReport(statements []flex.FlexStatement) map[string]float64 {
+	earliest := make(map[string]*flex.FlexStatement)
+	for i := range statements {
+		stmt := &statements[i]
+		if prev := earliest[stmt.AccountID]; len(stmt.CashReport) > 0 && (prev == nil || stmt.FromDate < prev.FromDate) {
+			earliest[stmt.AccountID] = stmt
+		}
+	}
+	cash := make(map[string]float64)
+	for account, stmt := range earliest {
+		for _, cr := range stmt.CashReport {
+			cash[account+"|"+cr.Currency] = cr.StartingCash
+		}
+	}
+	return cash
+}
+
+func diffCash(old, new map[string]float64) []CashChange {
+	keys := make(map[string]bool)
+	for k := range old {
+		keys[k] = true
+	}
+	for k := range new {
+		keys[k] = true
+	}
+
+	var changes []CashChange
+	for k := range keys {
+		change := cleanFloat(new[k] - old[k])
+		if change == 0 {
+			continue
+		}
+		account, currency, _ := strings.Cut(k, "|")
+		changes = append(changes, CashChange{AccountID: account, Currency: currency, Old: old[k], New: new[k], Change: change})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].AccountID != changes[j].AccountID {
+			return changes[i].AccountID < changes[j].AccountID
+		}
+		return changes[i].Currency < changes[j].Currency
+	})
+	return changes
+}
+
+// changeName added/removed/amended 的显示名称
+func changeName(change string, restated bool) string {
+	name := map[string]string{"added": "新增", "removed": "删除", "amended": "修改"}[change]
+	if restated {
+		return i18n.T(name) + " " + i18n.T("(更正)")
+	}
+	return i18n.T(name)
+}
+
+func PrintSnapshotDiff(d *SnapshotDiff) {
+	printTitle("快照对比")
+	i18n.Printf("旧快照期间: %s — %s\n", formatDate(normalizeDate(d.OldPeriodFrom)), formatDate(normalizeDate(d.OldPeriodTo)))
+	i18n.Printf("新快照期间: %s — %s\n", formatDate(normalizeDate(d.NewPeriodFrom)), formatDate(normalizeDate(d.NewPeriodTo)))
+	if d.Restated > 0 {
+		i18n.Printf("⚠ IBKR 更正了 %d 条旧快照期间内的记录\n", d.Restated)
+	}
+	fmt.Println()
+
+	if len(d.Trades)+len(d.CashTransactions)+len(d.Positions)+len(d.Cash) == 0 {
+		i18n.Println("两个快照没有差异")
+		return
+	}
+
+	records := func(title string, changes []RecordChange) {
+		if len(changes) == 0 {
+			return
+		}
+		printSection(title)
+		var rows [][]string
+		for _, c := range changes {
+			var fields []string
+			for _, f := range c.Fields {
+				fields = append(fields, fmt.Sprintf("%s: %s → %s", f.Field, f.Old, f.New))
+			}
+			rows = append(rows, []string{
+				changeName(c.Change, c.Restated),
+				formatDate(normalizeDate(c.Date)),
+				c.TransactionID,
+				c.Symbol,
+				fmtMoney(c.Amount) + " " + c.Currency,
+				strings.Join(fields, "; "),
+			})
+		}
+		printTable([]string{"变化", "日期", "TransactionID", "标的", "金额", "修改的字段"}, rows)
+		fmt.Println()
+	}
+	records("交易", d.Trades)
+	records("现金流水", d.CashTransactions)
+
+	if len(d.Positions) > 0 {
+		printSection("持仓变化")
+		var rows [][]string
+		for _, p := range d.Positions {
+			rows = append(rows, []string{
+				p.AccountID, p.Symbol, p.Currency,
+				fmt.Sprintf("%.4g → %.4g", p.OldQuantity, p.NewQuantity),
+				fmt.Sprintf("%s → %s", fmtMoney(p.OldValue), fmtMoney(p.NewValue)),
+				fmtPnL(p.NewValue - p.OldValue),
+			})
+		}
+		printTable([]string{"账户", "标的", "币种", "数量", "市值", "市值变化"}, rows)
+		fmt.Println()
+	}
+
+	if len(d.Cash) > 0 {
+		printSection("现金变化")
+		var rows [][]string
+		for _, c := range d.Cash {
+			rows = append(rows, []string{c.AccountID, c.Currency, fmtMoney(c.Old), fmtMoney(c.New), fmtPnL(c.Change)})
+		}
+		printTable([]string{"账户", "币种", "旧余额", "新余额", "变化"}, rows)
+	}
+}
+
+// Tables 导出用的表：交易、现金流水、持仓、现金的变化
+func (d *SnapshotDiff) Tables() []Table {
+	headers := []string{"变化", "更正", "日期", "TransactionID", "标的", "描述", "金额", "币种", "修改的字段"}
+	records := func(name, title string, changes []RecordChange) Table {
+		t := newTable(name, title, headers...)
+		for _, c := range changes {
+			var fields []string
+			for _, f := range c.Fields {
+				fields = append(fields, fmt.Sprintf("%s: %s → %s", f.Field, f.Old, f.New))
+			}
+			t.Rows = append(t.Rows, []any{c.Change, c.Restated, c.Date, c.TransactionID, c.Symbol, c.Description, c.Amount, c.Currency, strings.Join(fields, "; ")})
+		}
+		return t
+	}
+
+	positions := newTable("positions", "持仓变化", "账户", "标的", "币种", "旧数量", "新数量", "旧市值", "新市值")
+	for _, p := range d.Positions {
+		positions.Rows = append(positions.Rows, []any{p.AccountID, p.Symbol, p.Currency, p.OldQuantity, p.NewQuantity, p.OldValue, p.NewValue})
+	}
+	cash := newTable("cash", "现金变化", "账户", "币种", "旧余额", "新余额", "变化")
+	for _, c := range d.Cash {
+		cash.Rows = append(cash.Rows, []any{c.AccountID, c.Currency, c.Old, c.New, c.Change})
+	}
+	return []Table{
+		records("trades", "交易", d.Trades),
+		records("cash_transactions", "现金流水", d.CashTransactions),
+		positions,
+		cash,
+	}
+}
@@ -5,57 +5,77 @@ import (
 	"sort"
 
 	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 )
 
 type SymbolDividend struct {
-	Symbol       string
-	Gross        float64
-	Withholding  float64
-	Net          float64
-	Transactions int
+	Symbol       string  `json:"symbol"`
+	Gross        float64 `json:"gross"`
+	Withholding  float64 `json:"withholding"`
+	Net          float64 `json:"net"`
+	Transactions int     `json:"transactions"`
+}
+
+type PeriodDividend struct {
+	Period      string  `json:"period"`
+	Gross       float64 `json:"gross"`
+	Withholding float64 `json:"withholding"`
+	Net         float64 `json:"net"`
 }
 
 type DividendReport struct {
-	BySymbol       []SymbolDividend
-	TotalGross     float64
-	TotalWithhold  float64
-	TotalNet       float64
-	TotalCount     int
+	BySymbol      []SymbolDividend `json:"by_symbol"`
+	ByMonth       []PeriodDividend `json:"by_month"`
+	TotalGross    float64          `json:"total_gross"`
+	TotalWithhold float64          `json:"total_withholding"`
+	TotalNet      float64          `json:"total_net"`
+	TotalCount    int              `json:"total_count"`
 }
 
 func AnalyzeDividends(statements []flex.FlexStatement, from, to string) *DividendReport {
 	symbolMap := make(map[string]*SymbolDividend)
+	monthMap := make(map[string]*PeriodDividend)
+	month := func(date string) *PeriodDividend {
+		m := monthOf(date)
+		pd, ok := monthMap[m]
+		if !ok {
+			pd = &PeriodDividend{Period: m}
+			monthMap[m] = pd
+		}
+		return pd
+	}
 
 	var totalGross, totalWithhold float64
 	var totalCount int
 
-	for _, stmt := range statements {
-		for _, ct := range stmt.CashTransactions {
-			if !inDateRange(normalizeDate(ct.TradeDate), from, to) {
-				continue
+	for _, ct := range uniqueCashTransactions(statements) {
+		if !inDateRange(normalizeDate(ct.TradeDate), from, to) {
+			continue
+		}
+
+		amount := toBase(ct.Amount, ct.FxRateToBase)
+		switch ct.Type {
+		case "Dividends", "Payment In Lieu Of Dividends":
+			totalGross += amount
+			totalCount++
+			sd, ok := symbolMap[ct.Symbol]
+			if !ok {
+				sd = &SymbolDividend{Symbol: ct.Symbol}
+				symbolMap[ct.Symbol] = sd
 			}
+			sd.Gross += amount
+			sd.Transactions++
+			month(ct.TradeDate).Gross += amount
 
-			switch ct.Type {
-			case "Dividends", "Payment In Lieu Of Dividends":
-				totalGross += ct.Amount
-				totalCount++
-				sd, ok := symbolMap[ct.Symbol]
-				if !ok {
-					sd = &SymbolDividend{Symbol: ct.Symbol}
-					symbolMap[ct.Symbol] = sd
-				}
-				sd.Gross += ct.Amount
-				sd.Transactions++
-
-			case "Withholding Tax":
-				totalWithhold += ct.Amount // 通常为负数
-				sd, ok := symbolMap[ct.Symbol]
-				if !ok {
-					sd = &SymbolDividend{Symbol: ct.Symbol}
-					symbolMap[ct.Symbol] = sd
-				}
-				sd.Withholding += ct.Amount
+		case "Withholding Tax":
+			totalWithhold += amount // 通常为负数
+			sd, ok := symbolMap[ct.Symbol]
+			if !ok {
+				sd = &SymbolDividend{Symbol: ct.Symbol}
+				symbolMap[ct.Symbol] = sd
 			}
+			sd.Withholding += amount
+			month(ct.TradeDate).Withholding += amount
 		}
 	}
 
@@ -74,19 +94,27 @@ func AnalyzeDividends(statements []flex.FlexStatement, from, to string) *Dividen
 		return report.BySymbol[i].Net > report.BySymbol[j].Net
 	})
 
+	for _, pd := range monthMap {
+		pd.Net = pd.Gross + pd.Withholding
+		report.ByMonth = append(report.ByMonth, *pd)
+	}
+	sort.Slice(report.ByMonth, func(i, j int) bool {
+		return report.ByMonth[i].Period < report.ByMonth[j].Period
+	})
+
 	return report
 }
 
 func PrintDividendReport(r *DividendReport) {
-	fmt.Println("═══ 股息统计 ═══")
-	fmt.Printf("总股息收入:   %.2f\n", r.TotalGross)
-	fmt.Printf("预扣税:       %.2f\n", r.TotalWithhold)
-	fmt.Printf("净股息收入:   %.2f\n", r.TotalNet)
-	fmt.Printf("派息次数:     %d\n", r.TotalCount)
+	printTitle("股息统计")
+	i18n.Printf("总股息收入:   %s\n", fmtMoney(r.TotalGross))
+	i18n.Printf("预扣税:       %s\n", fmtMoney(r.TotalWithhold))
+	i18n.Printf("净股息收入:   %s\n", fmtMoney(r.TotalNet))
+	i18n.Printf("派息次数:     %d\n", r.TotalCount)
 	fmt.Println()
 
 	if len(r.BySymbol) > 0 {
-		fmt.Println("── 按标的 ──")
+		printSection("按标的")
 		printTable(
 			[]string{"标的", "总股息", "预扣税", "净收入", "次数"},
 			func() [][]string {
@@ -94,9 +122,9 @@ func PrintDividendReport(r *DividendReport) {
 				for _, s := range r.BySymbol {
 					rows = append(rows, []string{
 						s.Symbol,
-						fmt.Sprintf("%.2f", s.Gross),
-						fmt.Sprintf("%.2f", s.Withholding),
-						fmt.Sprintf("%.2f", s.Net),
+						fmtMoney(s.Gross),
+						fmtMoney(s.Withholding),
+						fmtMoney(s.Net),
 						fmt.Sprintf("%d", s.Transactions),
 					})
 				}
@@ -104,4 +132,47 @@ func PrintDividendReport(r *DividendReport) {
 			}(),
 		)
 	}
+
+	if len(r.ByMonth) > 0 {
+		printSection("按月份")
+		printTable(
+			[]string{"月份", "总股息", "预扣税", "净收入"},
+			func() [][]string {
+				var rows [][]string
+				for _, m := range r.ByMonth {
+					rows = append(rows, []string{
+						formatMonth(m.Period),
+						fmtMoney(m.Gross),
+						fmtMoney(m.Withholding),
+						fmtMoney(m.Net),
+					})
+				}
+				return rows
+			}(),
+		)
+	}
+}
+
+// Tables 导出用的表：汇总、按标的、按月份
+func (r *DividendReport) Tables() []Table {
+	bySymbol := newTable("by_symbol", "按标的", "标的", "总股息", "预扣税", "净收入", "次数")
+	for _, s := range r.BySymbol {
+		bySymbol.Rows = append(bySymbol.Rows, []any{s.Symbol, s.Gross, s.Withholding, s.Net, s.Transactions})
+	}
+
+	byMonth := newTable("by_month", "按月份", "月份", "总股息", "预扣税", "净收入")
+	for _, m := range r.ByMonth {
+		byMonth.Rows = append(byMonth.Rows, []any{m.Period, m.Gross, m.Withholding, m.Net})
+	}
+
+	return []Table{
+		totalsTable(
+			[]any{"总股息收入", r.TotalGross},
+			[]any{"预扣税", r.TotalWithhold},
+			[]any{"净股息收入", r.TotalNet},
+			[]any{"派息次数", r.TotalCount},
+		),
+		bySymbol,
+		byMonth,
+	}
 }
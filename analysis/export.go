@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+	"github.com/solarhell/ibkr-finance-analysis/xlsx"
+)
+
+// Table 导出用的二维表，单元格为 string、float64 或 int；
+// Title 和 Headers 已按当前语言翻译，Name 用于 CSV 文件名
+type Table struct {
+	Name    string
+	Title   string
+	Headers []string
+	Rows    [][]any
+}
+
+// Tabular 可导出为 CSV/XLSX 的分析结果
+type Tabular interface {
+	Tables() []Table
+}
+
+func newTable(name, title string, headers ...string) Table {
+	t := Table{Name: name, Title: i18n.T(title)}
+	for _, h := range headers {
+		t.Headers = append(t.Headers, i18n.T(h))
+	}
+	return t
+}
+
+// totalsTable 汇总数据，每行一个 项目/数值
+func totalsTable(rows ...[]any) Table {
+	t := newTable("totals", "汇总", "项目", "数值")
+	for _, r := range rows {
+		if label, ok := r[0].(string); ok {
+			r[0] = i18n.T(label)
+		}
+		t.Rows = append(t.Rows, r)
+	}
+	return t
+}
+
+// cleanFloat 去掉浮点运算误差（如 350.2999999999996）
+func cleanFloat(v float64) float64 {
+	return math.Round(v*1e8)/1e8 + 0 // + 0 把 -0 变为 0
+}
+
+func cellString(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(cleanFloat(n), 'f', -1, 64)
+	case int:
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprint(v)
+}
+
+// WriteCSV 每个表写一个 CSV 文件 <prefix>_<表名>.csv，返回写出的文件路径。
+// bom 为 true 时文件带 UTF-8 BOM，Excel 可直接打开中文内容；给其他程序导入的文件不带
+func WriteCSV(dir, prefix string, tables []Table, bom bool) ([]string, error) {
+	var paths []string
+	for _, t := range tables {
+		path := filepath.Join(dir, prefix+"_"+t.Name+".csv")
+		if err := writeCSVFile(path, t, bom); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func writeCSVFile(path string, t Table, bom bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if bom {
+		if _, err := f.WriteString("\ufeff"); err != nil {
+			return err
+		}
+	}
+	w := csv.NewWriter(f)
+	if err := w.Write(t.Headers); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = cellString(v)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WriteXLSX 写出工作簿，每个表一个工作表
+func WriteXLSX(w io.Writer, tables []Table) error {
+	sheets := make([]xlsx.Sheet, 0, len(tables))
+	for _, t := range tables {
+		rows := make([][]any, len(t.Rows))
+		for i, row := range t.Rows {
+			rows[i] = make([]any, len(row))
+			for j, v := range row {
+				if f, ok := v.(float64); ok {
+					v = cleanFloat(f)
+				}
+				rows[i][j] = v
+			}
+		}
+		sheets = append(sheets, xlsx.Sheet{Name: t.Title, Header: t.Headers, Rows: rows})
+	}
+	return xlsx.Write(w, sheets)
+}
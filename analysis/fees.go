@@ -0,0 +1,364 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// 费用类别
+const (
+	FeeCommission     = "commission"
+	FeeOther          = "other_fee"
+	FeeMarketData     = "market_data"
+	FeeADR            = "adr_fee"
+	FeeBorrow         = "borrow_fee"
+	FeeStampDuty      = "stamp_duty"
+	FeeFTT            = "ftt"
+	FeeSEC            = "sec_fee"
+	FeeFINRA          = "finra_taf"
+	FeeTransactionTax = "transaction_tax"
+)
+
+var feeCategoryNames = map[string]string{
+	FeeCommission:     "佣金",
+	FeeOther:          "其他费用",
+	FeeMarketData:     "行情订阅费",
+	FeeADR:            "ADR 托管费",
+	FeeBorrow:         "融券费",
+	FeeStampDuty:      "印花税",
+	FeeFTT:            "金融交易税",
+	FeeSEC:            "SEC 规费",
+	FeeFINRA:          "FINRA 规费",
+	FeeTransactionTax: "其他交易税",
+}
+
+type FeeCategory struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+	Count    int     `json:"count"`
+}
+
+type SymbolCost struct {
+	Symbol     string  `json:"symbol"`
+	Category   string  `json:"category"`
+	Commission float64 `json:"commission"`
+	Fees       float64 `json:"fees"`
+	Taxes      float64 `json:"taxes"`
+	Total      float64 `json:"total"`
+}
+
+type PeriodCost struct {
+	Period     string  `json:"period"`
+	Commission float64 `json:"commission"`
+	Fees       float64 `json:"fees"`
+	Taxes      float64 `json:"taxes"`
+	Total      float64 `json:"total"`
+}
+
+// FeeReport 交易成本（佣金 + 费用 + 交易税），金额均折算为基础货币
+type FeeReport struct {
+	ByType            []FeeCategory `json:"by_type"`
+	BySymbol          []SymbolCost  `json:"by_symbol"`
+	ByMonth           []PeriodCost  `json:"by_month"`
+	TotalCommission   float64       `json:"total_commission"`
+	TotalFees         float64       `json:"total_fees"`
+	TotalTaxes        float64       `json:"total_taxes"`
+	TotalCost         float64       `json:"total_cost"`
+	ReportedOtherFees float64       `json:"reported_other_fees"` // CashReport 汇总中的 otherFees，用于核对
+}
+
+// classifyCashFee 判断 CashTransaction 是否为费用类，并返回费用类别
+func classifyCashFee(ct flex.CashTransaction) (string, bool) {
+	desc := strings.ToUpper(ct.Description)
+	switch ct.Type {
+	case "Other Fees", "Broker Fees":
+		switch {
+		case strings.Contains(desc, "ADR"):
+			return FeeADR, true
+		case strings.Contains(desc, "BORROW"):
+			return FeeBorrow, true
+		case strings.Contains(desc, "MARKET DATA"), strings.Contains(desc, "SNAPSHOT"), strings.Contains(desc, "SUBSCRIPTION"):
+			return FeeMarketData, true
+		}
+		return FeeOther, true
+	case "Broker Interest Paid":
+		// 融资利息不算交易成本，只有融券费计入
+		if strings.Contains(desc, "BORROW") {
+			return FeeBorrow, true
+		}
+	case "Commission Adjustments":
+		return FeeCommission, true
+	}
+	return "", false
+}
+
+// classifyTransactionTax 按 taxDescription 区分交易税类型
+func classifyTransactionTax(tt flex.TransactionTax) string {
+	desc := strings.ToUpper(tt.TaxDescription)
+	switch {
+	case strings.Contains(desc, "STAMP"):
+		return FeeStampDuty
+	case strings.Contains(desc, "FTT"), strings.Contains(desc, "FINANCIAL TRANSACTION"):
+		return FeeFTT
+	case strings.Contains(desc, "FINRA"), strings.Contains(desc, "TAF"):
+		return FeeFINRA
+	case strings.Contains(desc, "SEC"):
+		return FeeSEC
+	}
+	return FeeTransactionTax
+}
+
+// commissionInBase 将佣金折算为基础货币；佣金币种与交易币种不同时（如换汇交易）按佣金币种当日的汇率折算，
+// 数据中没有佣金币种的汇率时退回交易本身的 fxRateToBase
+func commissionInBase(t flex.Trade, fx fxHistory) float64 {
+	if t.CommissionCurr != "" && t.CommissionCurr != t.Currency {
+		if rate, ok := fx.rateOn(t.CommissionCurr, normalizeDate(t.TradeDate)); ok {
+			return t.Commission * rate
+		}
+	}
+	return toBase(t.Commission, t.FxRateToBase)
+}
+
+func AnalyzeFees(statements []flex.FlexStatement, from, to string) *FeeReport {
+	typeMap := make(map[string]*FeeCategory)
+	symbolMap := make(map[string]*SymbolCost)
+	monthMap := make(map[string]*PeriodCost)
+
+	report := &FeeReport{}
+	fx := fxRateHistory(statements)
+
+	add := func(category, symbol, assetCategory, date string, amount float64) {
+		fc, ok := typeMap[category]
+		if !ok {
+			fc = &FeeCategory{Category: category}
+			typeMap[category] = fc
+		}
+		fc.Amount += amount
+		fc.Count++
+
+		sc, ok := symbolMap[symbol]
+		if !ok {
+			sc = &SymbolCost{Symbol: symbol, Category: assetCategory}
+			symbolMap[symbol] = sc
+		}
+		month := monthOf(date)
+		mp, ok := monthMap[month]
+		if !ok {
+			mp = &PeriodCost{Period: month}
+			monthMap[month] = mp
+		}
+
+		switch {
+		case category == FeeCommission:
+			report.TotalCommission += amount
+			sc.Commission += amount
+			mp.Commission += amount
+		case isTransactionTax(category):
+			report.TotalTaxes += amount
+			sc.Taxes += amount
+			mp.Taxes += amount
+		default:
+			report.TotalFees += amount
+			sc.Fees += amount
+			mp.Fees += amount
+		}
+		sc.Total += amount
+		mp.Total += amount
+		report.TotalCost += amount
+	}
+
+	for _, t := range uniqueTrades(statements) {
+		if !inDateRange(t.TradeDate, from, to) || t.Commission == 0 {
+			continue
+		}
+		add(FeeCommission, t.Symbol, t.AssetCategory, t.TradeDate, commissionInBase(t, fx))
+	}
+
+	for _, ct := range uniqueCashTransactions(statements) {
+		date := ct.TradeDate
+		if date == "" {
+			date = datePart(ct.DateTime)
+		}
+		if !inDateRange(normalizeDate(date), from, to) {
+			continue
+		}
+		category, ok := classifyCashFee(ct)
+		if !ok {
+			continue
+		}
+		add(category, ct.Symbol, "", date, toBase(ct.Amount, ct.FxRateToBase))
+	}
+
+	for _, tt := range uniqueTransactionTaxes(statements) {
+		if !inDateRange(tt.Date, from, to) {
+			continue
+		}
+		add(classifyTransactionTax(tt), tt.Symbol, tt.AssetCategory, tt.Date, toBase(tt.TaxAmount, tt.FxRateToBase))
+	}
+
+	reported := make(map[string]bool) // 同一账户、同一期间的账单只计一次
+	for _, stmt := range statements {
+		key := stmt.AccountID + "|" + stmt.FromDate + "|" + stmt.ToDate
+		if reported[key] {
+			continue
+		}
+		reported[key] = true
+		for _, cr := range stmt.CashReport {
+			if cr.Currency == "BASE_SUMMARY" {
+				report.ReportedOtherFees += cr.OtherFees
+			}
+		}
+	}
+
+	for _, fc := range typeMap {
+		report.ByType = append(report.ByType, *fc)
+	}
+	sort.Slice(report.ByType, func(i, j int) bool {
+		return report.ByType[i].Amount < report.ByType[j].Amount // 费用为负数，按绝对值排序
+	})
+
+	for _, sc := range symbolMap {
+		report.BySymbol = append(report.BySymbol, *sc)
+	}
+	sort.Slice(report.BySymbol, func(i, j int) bool {
+		return report.BySymbol[i].Total < report.BySymbol[j].Total
+	})
+
+	for _, mp := range monthMap {
+		report.ByMonth = append(report.ByMonth, *mp)
+	}
+	sort.Slice(report.ByMonth, func(i, j int) bool {
+		return report.ByMonth[i].Period < report.ByMonth[j].Period
+	})
+
+	return report
+}
+
+func isTransactionTax(category string) bool {
+	switch category {
+	case FeeStampDuty, FeeFTT, FeeSEC, FeeFINRA, FeeTransactionTax:
+		return true
+	}
+	return false
+}
+
+func feeCategoryName(category string) string {
+	if name, ok := feeCategoryNames[category]; ok {
+		return i18n.T(name)
+	}
+	return category
+}
+
+// symbolOrAccount 账户级费用（如行情订阅）没有标的
+func symbolOrAccount(symbol string) string {
+	if symbol == "" {
+		return i18n.T("(账户)")
+	}
+	return symbol
+}
+
+func PrintFeeReport(r *FeeReport) {
+	printTitle("交易成本")
+	i18n.Printf("佣金:       %s\n", fmtMoney(r.TotalCommission))
+	i18n.Printf("费用:       %s\n", fmtMoney(r.TotalFees))
+	i18n.Printf("交易税费:   %s\n", fmtMoney(r.TotalTaxes))
+	i18n.Printf("总成本:     %s\n", fmtMoney(r.TotalCost))
+	if r.ReportedOtherFees != 0 {
+		i18n.Printf("CashReport 其他费用: %s\n", fmtMoney(r.ReportedOtherFees))
+	}
+	fmt.Println()
+
+	if len(r.ByType) > 0 {
+		printSection("按费用类型")
+		printTable(
+			[]string{"类型", "金额", "笔数"},
+			func() [][]string {
+				var rows [][]string
+				for _, c := range r.ByType {
+					rows = append(rows, []string{
+						feeCategoryName(c.Category),
+						fmtMoney(c.Amount),
+						fmt.Sprintf("%d", c.Count),
+					})
+				}
+				return rows
+			}(),
+		)
+	}
+
+	if len(r.BySymbol) > 0 {
+		printSection("按标的")
+		printTable(
+			[]string{"标的", "类别", "佣金", "费用", "交易税费", "合计"},
+			func() [][]string {
+				var rows [][]string
+				for _, s := range r.BySymbol {
+					rows = append(rows, []string{
+						symbolOrAccount(s.Symbol),
+						s.Category,
+						fmtMoney(s.Commission),
+						fmtMoney(s.Fees),
+						fmtMoney(s.Taxes),
+						fmtMoney(s.Total),
+					})
+				}
+				return rows
+			}(),
+		)
+	}
+
+	if len(r.ByMonth) > 0 {
+		printSection("按月份")
+		printTable(
+			[]string{"月份", "佣金", "费用", "交易税费", "合计"},
+			func() [][]string {
+				var rows [][]string
+				for _, m := range r.ByMonth {
+					rows = append(rows, []string{
+						formatMonth(m.Period),
+						fmtMoney(m.Commission),
+						fmtMoney(m.Fees),
+						fmtMoney(m.Taxes),
+						fmtMoney(m.Total),
+					})
+				}
+				return rows
+			}(),
+		)
+	}
+}
+
+// Tables 导出用的表：汇总、按费用类型、按标的、按月份
+func (r *FeeReport) Tables() []Table {
+	byType := newTable("by_type", "按费用类型", "类型", "金额", "笔数")
+	for _, c := range r.ByType {
+		byType.Rows = append(byType.Rows, []any{feeCategoryName(c.Category), c.Amount, c.Count})
+	}
+
+	bySymbol := newTable("by_symbol", "按标的", "标的", "类别", "佣金", "费用", "交易税费", "合计")
+	for _, s := range r.BySymbol {
+		bySymbol.Rows = append(bySymbol.Rows, []any{symbolOrAccount(s.Symbol), s.Category, s.Commission, s.Fees, s.Taxes, s.Total})
+	}
+
+	byMonth := newTable("by_month", "按月份", "月份", "佣金", "费用", "交易税费", "合计")
+	for _, m := range r.ByMonth {
+		byMonth.Rows = append(byMonth.Rows, []any{m.Period, m.Commission, m.Fees, m.Taxes, m.Total})
+	}
+
+	return []Table{
+		totalsTable(
+			[]any{"佣金", r.TotalCommission},
+			[]any{"费用", r.TotalFees},
+			[]any{"交易税费", r.TotalTaxes},
+			[]any{"总成本", r.TotalCost},
+			[]any{"CashReport 其他费用", r.ReportedOtherFees},
+		),
+		byType,
+		bySymbol,
+		byMonth,
+	}
+}
@@ -0,0 +1,382 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// FXConversion 一笔换汇交易（如 USD.HKD）
+type FXConversion struct {
+	Date           string  `json:"date"`
+	Pair           string  `json:"pair"`
+	Sold           float64 `json:"sold"`
+	SoldCurrency   string  `json:"sold_currency"`
+	Bought         float64 `json:"bought"`
+	BoughtCurrency string  `json:"bought_currency"`
+	Rate           float64 `json:"rate"`
+	Commission     float64 `json:"commission"`
+	RealizedPnL    float64 `json:"realized_pnl"` // 基础货币
+}
+
+// CurrencyFX 某外币余额的汇兑损益，汇率均为 1 单位外币折合的基础货币
+type CurrencyFX struct {
+	Currency           string  `json:"currency"`
+	Balance            float64 `json:"balance"`          // 由现金流推算的余额
+	ReportedBalance    float64 `json:"reported_balance"` // CashReport 中的期末余额
+	AvgRate            float64 `json:"avg_rate"`         // 平均取得汇率
+	CurrentRate        float64 `json:"current_rate"`
+	RealizedConversion float64 `json:"realized_conversion"` // 换汇已实现汇兑损益
+	RealizedOther      float64 `json:"realized_other"`      // 用外币买入资产、支付费用等已实现的汇兑损益
+	Unrealized         float64 `json:"unrealized"`          // 持有外币的未实现折算损益
+}
+
+type FXReport struct {
+	BaseCurrency    string         `json:"base_currency"`
+	Conversions     []FXConversion `json:"conversions"`
+	ByCurrency      []CurrencyFX   `json:"by_currency"`
+	TotalRealized   float64        `json:"total_realized"`
+	TotalUnrealized float64        `json:"total_unrealized"`
+	TotalCommission float64        `json:"total_commission"`
+}
+
+// fxPool 平均成本法跟踪一种外币的余额（可为负，即借入外币）
+type fxPool struct {
+	balance float64
+	cost    float64 // 余额的基础货币成本
+}
+
+// apply 记入一笔外币现金流，返回减少余额部分的已实现汇兑损益
+func (p *fxPool) apply(amount, rate float64) float64 {
+	if math.Abs(p.balance) < 1e-9 || (amount > 0) == (p.balance > 0) {
+		p.balance += amount
+		p.cost += amount * rate
+		return 0
+	}
+
+	avg := p.cost / p.balance
+	closeAmt := amount
+	if math.Abs(amount) > math.Abs(p.balance) {
+		closeAmt = -p.balance
+	}
+	realized := -closeAmt * (rate - avg)
+	p.balance += closeAmt
+	p.cost += closeAmt * avg
+
+	if rest := amount - closeAmt; math.Abs(rest) > 1e-9 {
+		p.balance += rest
+		p.cost += rest * rate
+	}
+	return realized
+}
+
+func (p *fxPool) avgRate() float64 {
+	if math.Abs(p.balance) < 1e-9 {
+		return 0
+	}
+	return p.cost / p.balance
+}
+
+// fxEvent 外币现金流
+type fxEvent struct {
+	date       string
+	currency   string
+	amount     float64
+	rate       float64
+	conversion int // 所属换汇记录下标，-1 表示非换汇
+}
+
+func isFXTrade(t flex.Trade) bool {
+	return t.AssetCategory == "CASH"
+}
+
+// baseCurrency 优先取 AccountInformation，其次取 fxRateToBase 为 1 的币种
+func baseCurrency(statements []flex.FlexStatement) string {
+	for _, stmt := range statements {
+		if stmt.AccountInformation != nil && stmt.AccountInformation.Currency != "" {
+			return stmt.AccountInformation.Currency
+		}
+	}
+	for _, stmt := range statements {
+		for _, t := range stmt.Trades {
+			if t.FxRateToBase == 1 && !isFXTrade(t) {
+				return t.Currency
+			}
+		}
+		for _, ct := range stmt.CashTransactions {
+			if ct.FxRateToBase == 1 {
+				return ct.Currency
+			}
+		}
+	}
+	return "USD"
+}
+
+// splitPair "USD.HKD" → ("USD", "HKD")
+func splitPair(symbol string) (string, string) {
+	if i := strings.Index(symbol, "."); i > 0 {
+		return symbol[:i], symbol[i+1:]
+	}
+	return symbol, ""
+}
+
+func AnalyzeFX(statements []flex.FlexStatement, from, to string) *FXReport {
+	base := baseCurrency(statements)
+	report := &FXReport{BaseCurrency: base}
+	fx := fxRateHistory(statements)
+
+	var events []fxEvent
+	latestRate := make(map[string]string) // 币种 → 最新汇率对应的日期
+	currentRate := make(map[string]float64)
+	observe := func(date, currency string, rate float64) {
+		if currency == "" || currency == base || rate <= 0 {
+			return
+		}
+		if date >= latestRate[currency] {
+			latestRate[currency] = date
+			currentRate[currency] = rate
+		}
+	}
+	addEvent := func(date, currency string, amount, rate float64, conversion int) {
+		observe(date, currency, rate)
+		if currency == "" || currency == base || amount == 0 || rate <= 0 {
+			return
+		}
+		if to != "" && date > normalizeDate(to) {
+			return
+		}
+		events = append(events, fxEvent{date, currency, amount, rate, conversion})
+	}
+
+	reported := make(map[string]float64)
+	starting := make(map[string]float64)
+
+	for _, t := range uniqueTrades(statements) {
+		date := normalizeDate(t.TradeDate)
+		if !isFXTrade(t) {
+			addEvent(date, t.Currency, t.NetCash, t.FxRateToBase, -1)
+			continue
+		}
+
+		// 买入 BASE.QUOTE：收到 quantity 单位 BASE，付出 proceeds 单位 QUOTE
+		first, quote := splitPair(t.Symbol)
+		if quote == "" {
+			quote = t.Currency
+		}
+		firstRate := t.TradePrice * t.FxRateToBase
+		if first == base {
+			firstRate = 1
+		}
+
+		idx := -1
+		if inDateRange(date, from, to) {
+			conv := FXConversion{
+				Date:       isoDate(date),
+				Pair:       t.Symbol,
+				Rate:       t.TradePrice,
+				Commission: commissionInBase(t, fx),
+			}
+			if t.Quantity > 0 {
+				conv.Bought, conv.BoughtCurrency = t.Quantity, first
+				conv.Sold, conv.SoldCurrency = -t.Proceeds, quote
+			} else {
+				conv.Sold, conv.SoldCurrency = -t.Quantity, first
+				conv.Bought, conv.BoughtCurrency = t.Proceeds, quote
+			}
+			report.Conversions = append(report.Conversions, conv)
+			report.TotalCommission += conv.Commission
+			idx = len(report.Conversions) - 1
+		}
+		addEvent(date, first, t.Quantity, firstRate, idx)
+		addEvent(date, quote, t.Proceeds, t.FxRateToBase, idx)
+		switch t.CommissionCurr {
+		case quote:
+			addEvent(date, quote, t.Commission, t.FxRateToBase, -1)
+		case first:
+			addEvent(date, first, t.Commission, firstRate, -1)
+		}
+	}
+
+	for _, ct := range uniqueCashTransactions(statements) {
+		date := ct.TradeDate
+		if date == "" {
+			date = datePart(ct.DateTime)
+		}
+		addEvent(normalizeDate(date), ct.Currency, ct.Amount, ct.FxRateToBase, -1)
+	}
+
+	for _, tr := range uniqueTransfers(statements) {
+		if !isCashTransfer(tr) {
+			continue // 持仓转入转出不经过现金
+		}
+		addEvent(datePart(tr.DateTime), tr.Currency, tr.Amount, tr.FxRateToBase, -1)
+	}
+
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			observe(normalizeDate(op.ReportDate), op.Currency, op.FxRateToBase)
+		}
+	}
+
+	// 多个 query 覆盖同一账户时，期初余额取每个账户最早一期、期末余额取最近一期的 CashReport
+	for key, amount := range earliestCashReport(statements) {
+		if _, currency, _ := strings.Cut(key, "|"); currency != "BASE_SUMMARY" && currency != base {
+			starting[currency] += amount
+		}
+	}
+	for key, amount := range latestCashReport(statements) {
+		if _, currency, _ := strings.Cut(key, "|"); currency != "BASE_SUMMARY" && currency != base {
+			reported[currency] += amount
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].date < events[j].date
+	})
+
+	pools := make(map[string]*fxPool)
+	stats := make(map[string]*CurrencyFX)
+	pool := func(currency string) *fxPool {
+		p, ok := pools[currency]
+		if !ok {
+			p = &fxPool{}
+			pools[currency] = p
+			stats[currency] = &CurrencyFX{Currency: currency}
+		}
+		return p
+	}
+
+	// 期初余额按该币种第一笔现金流的汇率计入
+	for _, e := range events {
+		if _, ok := pools[e.currency]; !ok && starting[e.currency] != 0 {
+			pool(e.currency).apply(starting[e.currency], e.rate)
+		}
+		realized := pool(e.currency).apply(e.amount, e.rate)
+		if !inDateRange(e.date, from, to) {
+			continue
+		}
+		cs := stats[e.currency]
+		if e.conversion >= 0 {
+			cs.RealizedConversion += realized
+			report.Conversions[e.conversion].RealizedPnL += realized
+		} else {
+			cs.RealizedOther += realized
+		}
+	}
+	for currency, amount := range reported {
+		if _, ok := pools[currency]; !ok && (amount != 0 || starting[currency] != 0) {
+			pool(currency).apply(starting[currency], currentRate[currency])
+		}
+	}
+
+	for currency, p := range pools {
+		cs := stats[currency]
+		cs.Balance = p.balance
+		cs.AvgRate = p.avgRate()
+		cs.CurrentRate = currentRate[currency]
+
+		held := p.balance
+		if amount, ok := reported[currency]; ok {
+			cs.ReportedBalance = amount
+			held = amount
+		}
+		if cs.AvgRate > 0 && cs.CurrentRate > 0 {
+			cs.Unrealized = held * (cs.CurrentRate - cs.AvgRate)
+		}
+
+		report.TotalRealized += cs.RealizedConversion + cs.RealizedOther
+		report.TotalUnrealized += cs.Unrealized
+		report.ByCurrency = append(report.ByCurrency, *cs)
+	}
+	sort.Slice(report.ByCurrency, func(i, j int) bool {
+		return report.ByCurrency[i].Currency < report.ByCurrency[j].Currency
+	})
+
+	return report
+}
+
+func PrintFXReport(r *FXReport) {
+	printTitle("外汇分析")
+	i18n.Printf("基础货币:       %s\n", r.BaseCurrency)
+	i18n.Printf("已实现汇兑损益: %s\n", fmtMoney(r.TotalRealized))
+	i18n.Printf("未实现折算损益: %s\n", fmtMoney(r.TotalUnrealized))
+	i18n.Printf("换汇佣金:       %s\n", fmtMoney(r.TotalCommission))
+	fmt.Println()
+
+	if len(r.Conversions) > 0 {
+		printSection("换汇记录")
+		printTable(
+			[]string{"日期", "货币对", "卖出", "买入", "汇率", "佣金", "已实现损益"},
+			func() [][]string {
+				var rows [][]string
+				for _, c := range r.Conversions {
+					rows = append(rows, []string{
+						formatDate(c.Date),
+						c.Pair,
+						fmtMoney(c.Sold) + " " + c.SoldCurrency,
+						fmtMoney(c.Bought) + " " + c.BoughtCurrency,
+						fmt.Sprintf("%.5f", c.Rate),
+						fmtMoney(c.Commission),
+						fmtPnL(c.RealizedPnL),
+					})
+				}
+				return rows
+			}(),
+		)
+	}
+
+	if len(r.ByCurrency) > 0 {
+		printSection("按币种")
+		printTable(
+			[]string{"币种", "推算余额", "期末余额", "平均汇率", "当前汇率", "换汇已实现", "其他已实现", "未实现"},
+			func() [][]string {
+				var rows [][]string
+				for _, c := range r.ByCurrency {
+					rows = append(rows, []string{
+						c.Currency,
+						fmtMoney(c.Balance),
+						fmtMoney(c.ReportedBalance),
+						fmt.Sprintf("%.6f", c.AvgRate),
+						fmt.Sprintf("%.6f", c.CurrentRate),
+						fmtPnL(c.RealizedConversion),
+						fmtPnL(c.RealizedOther),
+						fmtPnL(c.Unrealized),
+					})
+				}
+				return rows
+			}(),
+		)
+	}
+}
+
+// Tables 导出用的表：汇总、换汇记录、按币种
+func (r *FXReport) Tables() []Table {
+	conversions := newTable("conversions", "换汇记录", "日期", "货币对", "卖出", "卖出币种", "买入", "买入币种", "汇率", "佣金", "已实现损益")
+	for _, c := range r.Conversions {
+		conversions.Rows = append(conversions.Rows, []any{
+			c.Date, c.Pair, c.Sold, c.SoldCurrency, c.Bought, c.BoughtCurrency, c.Rate, c.Commission, c.RealizedPnL,
+		})
+	}
+
+	byCurrency := newTable("by_currency", "按币种", "币种", "推算余额", "期末余额", "平均汇率", "当前汇率", "换汇已实现", "其他已实现", "未实现")
+	for _, c := range r.ByCurrency {
+		byCurrency.Rows = append(byCurrency.Rows, []any{
+			c.Currency, c.Balance, c.ReportedBalance, c.AvgRate, c.CurrentRate, c.RealizedConversion, c.RealizedOther, c.Unrealized,
+		})
+	}
+
+	return []Table{
+		totalsTable(
+			[]any{"基础货币", r.BaseCurrency},
+			[]any{"已实现汇兑损益", r.TotalRealized},
+			[]any{"未实现折算损益", r.TotalUnrealized},
+			[]any{"换汇佣金", r.TotalCommission},
+		),
+		conversions,
+		byCurrency,
+	}
+}
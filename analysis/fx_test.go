@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+func TestFXPoolApply(t *testing.T) {
+	type flow struct{ amount, rate float64 }
+	tests := []struct {
+		name     string
+		flows    []flow
+		realized []float64
+		balance  float64
+		avgRate  float64
+	}{
+		{
+			name:     "inflows average the rate",
+			flows:    []flow{{1000, 0.128}, {1000, 0.130}},
+			realized: []float64{0, 0},
+			balance:  2000,
+			avgRate:  0.129,
+		},
+		{
+			name:     "spending realizes against the average rate",
+			flows:    []flow{{1000, 0.128}, {-400, 0.130}},
+			realized: []float64{0, 400 * 0.002},
+			balance:  600,
+			avgRate:  0.128,
+		},
+		{
+			name:     "overspending flips to a borrowed balance at the new rate",
+			flows:    []flow{{100, 0.13}, {-300, 0.12}},
+			realized: []float64{0, -100 * 0.01},
+			balance:  -200,
+			avgRate:  0.12,
+		},
+		{
+			name:     "repaying a borrowed balance",
+			flows:    []flow{{-500, 0.13}, {500, 0.12}},
+			realized: []float64{0, 500 * 0.01},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p fxPool
+			for i, f := range tt.flows {
+				if got := p.apply(f.amount, f.rate); !approxEqual(got, tt.realized[i]) {
+					t.Errorf("flow %d realized = %g, want %g", i, got, tt.realized[i])
+				}
+			}
+			if !approxEqual(p.balance, tt.balance) || !approxEqual(p.avgRate(), tt.avgRate) {
+				t.Errorf("balance = %g @ %g, want %g @ %g", p.balance, p.avgRate(), tt.balance, tt.avgRate)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFX(t *testing.T) {
+	// 卖 1000 USD 换 7800 HKD，用 3900 HKD 买股票，再用 1950 HKD 买回 250 USD
+	sellUSD := flex.Trade{Symbol: "USD.HKD", AssetCategory: "CASH", Currency: "HKD", TradeDate: "20250110", DateTime: "20250110;100000",
+		Quantity: -1000, TradePrice: 7.8, Proceeds: 7800, Commission: -2, CommissionCurr: "USD", FxRateToBase: 0.1282, TransactionID: "t1"}
+	buyStock := flex.Trade{Symbol: "0700", AssetCategory: "STK", Currency: "HKD", TradeDate: "20250115", DateTime: "20250115;100000",
+		Quantity: 100, Proceeds: -3900, NetCash: -3900, FxRateToBase: 0.13, TransactionID: "t2"}
+	buyUSD := flex.Trade{Symbol: "USD.HKD", AssetCategory: "CASH", Currency: "HKD", TradeDate: "20250120", DateTime: "20250120;100000",
+		Quantity: 250, TradePrice: 7.8, Proceeds: -1950, Commission: -2, CommissionCurr: "USD", FxRateToBase: 0.1285, TransactionID: "t3"}
+	statement := func(from string, starting, ending float64, trades ...flex.Trade) flex.FlexStatement {
+		return flex.FlexStatement{
+			AccountID: "U1", FromDate: from, ToDate: "20250131",
+			AccountInformation: &flex.AccountInformation{AccountID: "U1", Currency: "USD"},
+			Trades:             trades,
+			CashReport: []flex.CashReportCurrency{
+				{AccountID: "U1", Currency: "HKD", FromDate: from, ToDate: "20250131", StartingCash: starting, EndingCash: ending},
+			},
+		}
+	}
+	single := statement("20250101", 0, 1950, sellUSD, buyStock, buyUSD)
+
+	tests := []struct {
+		name       string
+		statements []flex.FlexStatement
+		conversion float64
+		other      float64
+		unrealized float64
+		balance    float64
+	}{
+		{
+			name:       "conversions and a foreign-currency purchase",
+			statements: []flex.FlexStatement{single},
+			conversion: 1950 * (0.1285 - 0.1282),
+			other:      3900 * (0.13 - 0.1282),
+			unrealized: 1950 * (0.1285 - 0.1282),
+			balance:    1950,
+		},
+		{
+			name:       "overlapping queries count each record once",
+			statements: []flex.FlexStatement{single, single},
+			conversion: 1950 * (0.1285 - 0.1282),
+			other:      3900 * (0.13 - 0.1282),
+			unrealized: 1950 * (0.1285 - 0.1282),
+			balance:    1950,
+		},
+		{
+			// 较晚开始的 query 的期初余额已包含第一笔换汇，只取最早一期的期初余额
+			name: "starting balance from the earliest statement",
+			statements: []flex.FlexStatement{
+				statement("20250101", 1000, 2950, sellUSD, buyStock, buyUSD),
+				statement("20250111", 8800, 2950, buyStock, buyUSD),
+			},
+			conversion: 1950 * (0.1285 - 0.1282),
+			other:      3900 * (0.13 - 0.1282),
+			unrealized: 2950 * (0.1285 - 0.1282),
+			balance:    2950,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := AnalyzeFX(tt.statements, "", "")
+			if len(r.ByCurrency) != 1 || r.ByCurrency[0].Currency != "HKD" {
+				t.Fatalf("ByCurrency = %+v, want HKD only", r.ByCurrency)
+			}
+			hkd := r.ByCurrency[0]
+			if !approxEqual(hkd.RealizedConversion, tt.conversion) {
+				t.Errorf("RealizedConversion = %g, want %g", hkd.RealizedConversion, tt.conversion)
+			}
+			if !approxEqual(hkd.RealizedOther, tt.other) {
+				t.Errorf("RealizedOther = %g, want %g", hkd.RealizedOther, tt.other)
+			}
+			if !approxEqual(hkd.Unrealized, tt.unrealized) {
+				t.Errorf("Unrealized = %g, want %g", hkd.Unrealized, tt.unrealized)
+			}
+			if !approxEqual(hkd.Balance, tt.balance) {
+				t.Errorf("Balance = %g, want %g", hkd.Balance, tt.balance)
+			}
+			if len(r.Conversions) != 2 || !approxEqual(r.TotalCommission, -4) {
+				t.Errorf("conversions = %d, commission = %g, want 2 and -4", len(r.Conversions), r.TotalCommission)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+// GhostfolioActivity Ghostfolio 导入格式中的一条活动
+type GhostfolioActivity struct {
+	AccountID  string  `json:"accountId,omitempty"`
+	Comment    string  `json:"comment,omitempty"`
+	Currency   string  `json:"currency"`
+	DataSource string  `json:"dataSource"` // YAHOO（股票、ETF）或 MANUAL
+	Date       string  `json:"date"`       // ISO 8601，只精确到日
+	Fee        float64 `json:"fee"`
+	Quantity   float64 `json:"quantity"`
+	Symbol     string  `json:"symbol"`
+	Type       string  `json:"type"` // BUY、SELL、DIVIDEND、INTEREST、FEE
+	UnitPrice  float64 `json:"unitPrice"`
+}
+
+type GhostfolioMeta struct {
+	Date time.Time `json:"date"`
+}
+
+// GhostfolioExport Ghostfolio 的 activities 导入文件
+type GhostfolioExport struct {
+	Meta       GhostfolioMeta       `json:"meta"`
+	Activities []GhostfolioActivity `json:"activities"`
+}
+
+// 利息、费用在 Ghostfolio 中是手工资产，用固定名称归到一起
+const (
+	ghostfolioInterestSymbol = "IBKR Interest"
+	ghostfolioFeeSymbol      = "IBKR Fees"
+)
+
+// BuildGhostfolioExport 由交易和现金流水生成 Ghostfolio 活动。
+// Ghostfolio 没有税费字段，预扣税计入同日同标的股息的 fee；没有入金/出金活动，出入金不导出；
+// 换汇交易和费用退回也不导出。accountID 为 Ghostfolio 中的账户 ID，可为空（导入时选择）
+func BuildGhostfolioExport(statements []flex.FlexStatement, from, to, accountID string) *GhostfolioExport {
+	isins := isinBySymbol(statements)
+	export := &GhostfolioExport{Meta: GhostfolioMeta{Date: time.Now().Truncate(time.Second)}}
+	add := func(a GhostfolioActivity) {
+		a.AccountID = accountID
+		export.Activities = append(export.Activities, a)
+	}
+	comment := func(description, isin string) string {
+		if isin == "" {
+			return description
+		}
+		return description + " (" + isin + ")"
+	}
+
+	for _, t := range sortTradesByTime(uniqueTrades(statements)) {
+		if isFXTrade(t) || !inDateRange(normalizeDate(t.TradeDate), from, to) {
+			continue
+		}
+		typ := "BUY"
+		if t.Quantity < 0 {
+			typ = "SELL"
+		}
+		add(GhostfolioActivity{
+			Comment:    comment(t.Description, securityISIN(t.Symbol, t.ISIN, isins)),
+			Currency:   t.Currency,
+			DataSource: ghostfolioDataSource(t.AssetCategory),
+			Date:       ghostfolioDate(t.TradeDate),
+			Fee:        cleanFloat(math.Abs(t.Commission) + math.Abs(t.Taxes)),
+			Quantity:   tradeShares(t),
+			Symbol:     t.Symbol,
+			Type:       typ,
+			UnitPrice:  t.TradePrice,
+		})
+	}
+
+	dividends := make(map[string]int) // 标的|日期|币种 → Activities 下标
+	var withholding []flex.CashTransaction
+	for _, ct := range uniqueCashTransactions(statements) {
+		date := cashDate(ct)
+		if ct.Amount == 0 || !inDateRange(date, from, to) {
+			continue
+		}
+		a := GhostfolioActivity{
+			Comment:    ct.Description,
+			Currency:   ct.Currency,
+			DataSource: "MANUAL",
+			Date:       ghostfolioDate(date),
+			Quantity:   1,
+		}
+		switch ct.Type {
+		case "Dividends", "Payment In Lieu Of Dividends":
+			a.Type, a.Symbol, a.DataSource, a.UnitPrice = "DIVIDEND", ct.Symbol, "YAHOO", ct.Amount
+			a.Comment = comment(ct.Description, securityISIN(ct.Symbol, ct.ISIN, isins))
+			dividends[ct.Symbol+"|"+date+"|"+ct.Currency] = len(export.Activities)
+		case "Withholding Tax":
+			withholding = append(withholding, ct)
+			continue
+		case "Broker Interest Received", "Bond Interest Received":
+			a.Type, a.Symbol, a.UnitPrice = "INTEREST", ghostfolioInterestSymbol, ct.Amount
+		case "Broker Interest Paid", "Bond Interest Paid", "Other Fees", "Broker Fees", "Commission Adjustments":
+			if ct.Amount > 0 {
+				continue
+			}
+			a.Type, a.Symbol, a.Fee = "FEE", ghostfolioFeeSymbol, -ct.Amount
+		default:
+			continue
+		}
+		add(a)
+	}
+	for _, ct := range withholding {
+		if i, ok := dividends[ct.Symbol+"|"+cashDate(ct)+"|"+ct.Currency]; ok {
+			export.Activities[i].Fee = cleanFloat(export.Activities[i].Fee - ct.Amount)
+			continue
+		}
+		if ct.Amount < 0 {
+			add(GhostfolioActivity{
+				Comment:    ct.Description,
+				Currency:   ct.Currency,
+				DataSource: "MANUAL",
+				Date:       ghostfolioDate(cashDate(ct)),
+				Fee:        -ct.Amount,
+				Quantity:   1,
+				Symbol:     ghostfolioFeeSymbol,
+				Type:       "FEE",
+			})
+		}
+	}
+
+	sort.SliceStable(export.Activities, func(i, j int) bool {
+		return export.Activities[i].Date < export.Activities[j].Date
+	})
+	return export
+}
+
+// ghostfolioDataSource 股票和 ETF 由 Yahoo 提供行情，其余（期权、期货等）作为手工资产
+func ghostfolioDataSource(assetCategory string) string {
+	if assetCategory == "STK" || assetCategory == "ETF" || assetCategory == "" {
+		return "YAHOO"
+	}
+	return "MANUAL"
+}
+
+func ghostfolioDate(date string) string {
+	return isoDate(date) + "T00:00:00.000Z"
+}
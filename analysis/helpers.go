@@ -3,7 +3,12 @@ package analysis
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 )
 
 // normalizeDate 将 YYYY-MM-DD 格式统一转换为 YYYYMMDD
@@ -14,6 +19,124 @@ func normalizeDate(date string) string {
 	return date
 }
 
+// dateTimeSeparators dateTime 字段中日期与时间之间可能出现的分隔符
+const dateTimeSeparators = "; ,T"
+
+// datePart 取 dateTime 字段中的日期部分（"20250115;093000" → "20250115"）
+func datePart(dateTime string) string {
+	if i := strings.IndexAny(dateTime, dateTimeSeparators); i >= 0 {
+		dateTime = dateTime[:i]
+	}
+	return normalizeDate(dateTime)
+}
+
+// monthOf 返回 YYYY-MM 形式的月份
+func monthOf(date string) string {
+	nd := normalizeDate(date)
+	if len(nd) >= 6 {
+		return nd[:4] + "-" + nd[4:6]
+	}
+	return ""
+}
+
+// isoDate "20250115" → "2025-01-15"，报告中的日期统一使用 ISO 8601
+func isoDate(date string) string {
+	nd := normalizeDate(date)
+	if len(nd) != 8 {
+		return nd
+	}
+	return nd[:4] + "-" + nd[4:6] + "-" + nd[6:]
+}
+
+// isoDateTime "20250115;093000"、"2025-01-15;09:30:00"、"2025-01-15T09:30:00" → "2025-01-15T09:30:00"
+func isoDateTime(dateTime string) string {
+	date := isoDate(datePart(dateTime))
+	i := strings.IndexAny(dateTime, dateTimeSeparators)
+	if i < 0 {
+		return date
+	}
+	tm := strings.TrimLeft(dateTime[i:], dateTimeSeparators)
+	if len(tm) == 6 && !strings.Contains(tm, ":") {
+		tm = tm[:2] + ":" + tm[2:4] + ":" + tm[4:]
+	}
+	if tm == "" {
+		return date
+	}
+	return date + "T" + tm
+}
+
+// toBase 按 fxRateToBase 折算为基础货币，汇率缺失时原样返回
+func toBase(amount, fxRateToBase float64) float64 {
+	if fxRateToBase > 0 {
+		return amount * fxRateToBase
+	}
+	return amount
+}
+
+// fxHistory 各币种对基础货币的汇率观测，按日期升序
+type fxHistory map[string][]fxObservation
+
+type fxObservation struct {
+	date string
+	rate float64
+}
+
+// fxRateHistory 从持仓、交易（含换汇）、现金流水和转账的 fxRateToBase 收集每个币种的汇率
+func fxRateHistory(statements []flex.FlexStatement) fxHistory {
+	h := make(fxHistory)
+	observe := func(date, currency string, rate float64) {
+		if currency != "" && date != "" && rate > 0 {
+			h[currency] = append(h[currency], fxObservation{date, rate})
+		}
+	}
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			observe(normalizeDate(op.ReportDate), op.Currency, op.FxRateToBase)
+		}
+		for _, pp := range stmt.PriorPeriodPositions {
+			observe(normalizeDate(pp.Date), pp.Currency, pp.FxRateToBase)
+		}
+		for _, t := range stmt.Trades {
+			date := normalizeDate(t.TradeDate)
+			if !isFXTrade(t) {
+				observe(date, t.Currency, t.FxRateToBase)
+				continue
+			}
+			// 换汇交易：报价货币的汇率为 fxRateToBase，基准货币再乘以成交价
+			base, quote := splitPair(t.Symbol)
+			if quote == "" {
+				quote = t.Currency
+			}
+			observe(date, quote, t.FxRateToBase)
+			observe(date, base, t.TradePrice*t.FxRateToBase)
+		}
+		for _, ct := range stmt.CashTransactions {
+			observe(cashDate(ct), ct.Currency, ct.FxRateToBase)
+		}
+		for _, tr := range stmt.Transfers {
+			observe(datePart(tr.DateTime), tr.Currency, tr.FxRateToBase)
+		}
+	}
+	for _, obs := range h {
+		sort.SliceStable(obs, func(i, j int) bool { return obs[i].date < obs[j].date })
+	}
+	h[baseCurrency(statements)] = []fxObservation{{"", 1}}
+	return h
+}
+
+// rateOn 某日的汇率：当日或之前最近的观测，早于所有观测时取第一个；数据中没有该币种的汇率时返回 false
+func (h fxHistory) rateOn(currency, date string) (float64, bool) {
+	obs := h[currency]
+	if len(obs) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(obs), func(i int) bool { return obs[i].date > date })
+	if i == 0 {
+		return obs[0].rate, true
+	}
+	return obs[i-1].rate, true
+}
+
 // inDateRange 检查日期是否在范围内（支持 YYYYMMDD 和 YYYY-MM-DD 格式）
 func inDateRange(date, from, to string) bool {
 	d := normalizeDate(date)
@@ -26,13 +149,132 @@ func inDateRange(date, from, to string) bool {
 	return true
 }
 
-// printTable 用 tabwriter 打印表格
+// uniqueTrades 合并各账单的交易，多个 query 中重复出现的交易按 TransactionID 只保留一笔
+func uniqueTrades(statements []flex.FlexStatement) []flex.Trade {
+	var trades []flex.Trade
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, t := range stmt.Trades {
+			id := tradeEntryID(t)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			trades = append(trades, t)
+		}
+	}
+	return trades
+}
+
+// uniqueCashTransactions 同 uniqueTrades，用于 CashTransactions
+func uniqueCashTransactions(statements []flex.FlexStatement) []flex.CashTransaction {
+	var cts []flex.CashTransaction
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, ct := range stmt.CashTransactions {
+			id := cashEntryID(ct)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			cts = append(cts, ct)
+		}
+	}
+	return cts
+}
+
+// uniqueTransfers 同 uniqueTrades，用于 Transfers：按账户和 TransactionID 去重，缺少 accountId 的记录补上账单的账户
+func uniqueTransfers(statements []flex.FlexStatement) []flex.Transfer {
+	var transfers []flex.Transfer
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, tr := range stmt.Transfers {
+			tr.AccountID = accountOr(tr.AccountID, stmt.AccountID)
+			id := tr.AccountID + "|" + tr.TransactionID
+			if tr.TransactionID != "" && seen[id] {
+				continue
+			}
+			seen[id] = true
+			transfers = append(transfers, tr)
+		}
+	}
+	return transfers
+}
+
+// uniqueTransactionTaxes 同 uniqueTrades，用于 TransactionTaxes（没有 TransactionID，按账户、成交和税种识别）
+func uniqueTransactionTaxes(statements []flex.FlexStatement) []flex.TransactionTax {
+	var taxes []flex.TransactionTax
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, tt := range stmt.TransactionTaxes {
+			id := fmt.Sprintf("%s:%s:%s:%s:%s:%g", tt.AccountID, tt.TradeID, tt.Date, tt.Symbol, tt.TaxDescription, tt.TaxAmount)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			taxes = append(taxes, tt)
+		}
+	}
+	return taxes
+}
+
+// isinBySymbol 从交易、现金流水和持仓中收集代码到 ISIN 的映射，
+// 用于补齐缺少 isin 字段的记录（如部分股息行）
+func isinBySymbol(statements []flex.FlexStatement) map[string]string {
+	isins := make(map[string]string)
+	add := func(symbol, isin string) {
+		if symbol != "" && isin != "" && isins[symbol] == "" {
+			isins[symbol] = isin
+		}
+	}
+	for _, stmt := range statements {
+		for _, t := range stmt.Trades {
+			add(t.Symbol, t.ISIN)
+		}
+		for _, op := range stmt.OpenPositions {
+			add(op.Symbol, op.ISIN)
+		}
+		for _, ct := range stmt.CashTransactions {
+			add(ct.Symbol, ct.ISIN)
+		}
+	}
+	return isins
+}
+
+// csvColumns 本地 CSV 文件（批次、证券主数据等）的表头：列名（小写，去掉 BOM）→ 列号
+func csvColumns(header []string) map[string]int {
+	col := make(map[string]int)
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	return col
+}
+
+// csvField 按列名取一行的值，没有该列时为空
+func csvField(col map[string]int, rec []string, name string) string {
+	if i, ok := col[name]; ok && i < len(rec) {
+		return strings.TrimSpace(rec[i])
+	}
+	return ""
+}
+
+// printTitle 打印报告标题
+func printTitle(title string) {
+	fmt.Printf("═══ %s ═══\n", i18n.T(title))
+}
+
+// printSection 打印小节标题
+func printSection(title string) {
+	fmt.Printf("── %s ──\n", i18n.T(title))
+}
+
+// printTable 用 tabwriter 打印表格，表头按当前语言翻译
 func printTable(headers []string, rows [][]string) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
 	// 每个字段后加 \t
 	for _, h := range headers {
-		fmt.Fprintf(w, "%s\t", h)
+		fmt.Fprintf(w, "%s\t", i18n.T(h))
 	}
 	fmt.Fprintln(w)
 
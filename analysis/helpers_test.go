@@ -0,0 +1,29 @@
+package analysis
+
+import (
+	"encoding/xml"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+// readTestStatements 读取 testdata 下的 Flex XML
+func readTestStatements(t *testing.T, name string) []flex.FlexStatement {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	var resp flex.FlexQueryResponse
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+	return resp.FlexStatements
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
@@ -0,0 +1,330 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// holdingEvent 一条改变持仓数量的记录：交易、公司行动或持仓转入转出
+type holdingEvent struct {
+	date          string // YYYYMMDD
+	accountID     string
+	symbol        string
+	assetCategory string
+	currency      string
+	quantity      float64
+}
+
+// Holding 重建的某日持仓
+type Holding struct {
+	AccountID     string  `json:"account_id"`
+	Symbol        string  `json:"symbol"`
+	AssetCategory string  `json:"asset_category"`
+	Currency      string  `json:"currency"`
+	Quantity      float64 `json:"quantity"`
+}
+
+// PositionMismatch 重建的期末持仓与 OpenPositions 不一致的标的
+type PositionMismatch struct {
+	AccountID  string  `json:"account_id"`
+	Symbol     string  `json:"symbol"`
+	ReportDate string  `json:"report_date"`
+	Rebuilt    float64 `json:"rebuilt"`
+	Reported   float64 `json:"reported"`
+	Difference float64 `json:"difference"` // Reported - Rebuilt
+}
+
+type HoldingsReport struct {
+	AsOf       string             `json:"as_of"`
+	Holdings   []Holding          `json:"holdings"`
+	Checked    int                `json:"checked"` // 参与核对的持仓数
+	Mismatches []PositionMismatch `json:"mismatches"`
+}
+
+// isCashTransfer 转账是否为现金（入金/出金）；否则是 ACATS、FOP 等持仓转入转出，不影响现金
+func isCashTransfer(tr flex.Transfer) bool {
+	return tr.AssetCategory == "CASH" || tr.Symbol == "" || tr.Symbol == "--"
+}
+
+// transferValue 持仓转账的市值（交易币种），转入为正；
+// 优先取 positionAmount，其次 amount，最后用 quantity × transferPrice 估算
+func transferValue(tr flex.Transfer) float64 {
+	value := math.Abs(tr.PositionAmount)
+	if value == 0 {
+		value = math.Abs(tr.Amount)
+	}
+	if value == 0 {
+		value = math.Abs(tr.Quantity * tr.TransferPrice)
+	}
+	return transferSign(tr) * value
+}
+
+// transferSign 转入为 1、转出为 -1；转出的数量有时为正，以 direction 为准，缺失时看数量的符号
+func transferSign(tr flex.Transfer) float64 {
+	switch strings.ToUpper(tr.Direction) {
+	case "IN":
+		return 1
+	case "OUT":
+		return -1
+	}
+	if tr.Quantity < 0 {
+		return -1
+	}
+	return 1
+}
+
+// holdingEvents 收集所有改变持仓数量的记录，多个 query 中重复的按账户和 TransactionID 只保留一条。
+// 换汇交易不是持仓，现金转账没有数量，均不计入
+func holdingEvents(statements []flex.FlexStatement) []holdingEvent {
+	var events []holdingEvent
+	seen := make(map[string]bool)
+	add := func(id string, e holdingEvent) {
+		id = e.accountID + "|" + id
+		if seen[id] || e.symbol == "" || e.quantity == 0 {
+			return
+		}
+		seen[id] = true
+		events = append(events, e)
+	}
+
+	for _, stmt := range statements {
+		for _, t := range stmt.Trades {
+			if isFXTrade(t) {
+				continue
+			}
+			add(tradeEntryID(t), holdingEvent{
+				date: normalizeDate(t.TradeDate), accountID: stmt.AccountID, symbol: t.Symbol,
+				assetCategory: t.AssetCategory, currency: t.Currency, quantity: t.Quantity,
+			})
+		}
+
+		for _, ca := range stmt.CorporateActions {
+			id := "ca:" + ca.TransactionID
+			if ca.TransactionID == "" {
+				id = fmt.Sprintf("ca:%s:%s:%g", ca.DateTime, ca.Symbol, ca.Quantity)
+			}
+			date := datePart(ca.DateTime)
+			if date == "" {
+				date = normalizeDate(ca.ReportDate)
+			}
+			add(id, holdingEvent{
+				date: date, accountID: accountOr(ca.AccountID, stmt.AccountID), symbol: ca.Symbol,
+				assetCategory: ca.AssetCategory, currency: ca.Currency, quantity: ca.Quantity,
+			})
+		}
+
+		for _, tr := range stmt.Transfers {
+			if isCashTransfer(tr) {
+				continue
+			}
+			qty := transferSign(tr) * math.Abs(tr.Quantity)
+			id := "transfer:" + tr.TransactionID
+			if tr.TransactionID == "" {
+				id = fmt.Sprintf("transfer:%s:%s:%g", tr.DateTime, tr.Symbol, tr.Quantity)
+			}
+			add(id, holdingEvent{
+				date: datePart(tr.DateTime), accountID: accountOr(tr.AccountID, stmt.AccountID), symbol: tr.Symbol,
+				assetCategory: tr.AssetCategory, currency: tr.Currency, quantity: qty,
+			})
+		}
+	}
+	return events
+}
+
+func accountOr(accountID, fallback string) string {
+	if accountID != "" {
+		return accountID
+	}
+	return fallback
+}
+
+// replayHoldings 累加 asOf（含）之前的事件，asOf 为空时累加全部；返回按 账户|代码 索引的持仓，含数量为 0 的
+func replayHoldings(events []holdingEvent, asOf string) map[string]*Holding {
+	holdings := make(map[string]*Holding)
+	for _, e := range events {
+		if asOf != "" && e.date > asOf {
+			continue
+		}
+		key := e.accountID + "|" + e.symbol
+		h := holdings[key]
+		if h == nil {
+			h = &Holding{AccountID: e.accountID, Symbol: e.symbol}
+			holdings[key] = h
+		}
+		if h.AssetCategory == "" {
+			h.AssetCategory = e.assetCategory
+		}
+		if h.Currency == "" {
+			h.Currency = e.currency
+		}
+		h.Quantity = cleanFloat(h.Quantity + e.quantity)
+	}
+	return holdings
+}
+
+// seedHoldings 以期初批次为起点：批次折成期初日期的持仓事件，期初日期及之前的事件已反映在批次中，不再重放。
+// 批次没有账户时计入第一个账户；没有期初批次时原样返回
+func seedHoldings(events []holdingEvent, statements []flex.FlexStatement, lots *OpeningLots) []holdingEvent {
+	if lots == nil || len(lots.Lots) == 0 {
+		return events
+	}
+	var accounts []string
+	for _, stmt := range statements {
+		if stmt.AccountID != "" && !slices.Contains(accounts, stmt.AccountID) {
+			accounts = append(accounts, stmt.AccountID)
+		}
+	}
+	sort.Strings(accounts)
+
+	var seeded []holdingEvent
+	for _, l := range lots.Lots {
+		account := l.AccountID
+		if account == "" && len(accounts) > 0 {
+			account = accounts[0]
+		}
+		qty := l.Quantity
+		if l.Short {
+			qty = -qty
+		}
+		seeded = append(seeded, holdingEvent{
+			date: lots.AsOf, accountID: account, symbol: l.Symbol, currency: l.Currency, quantity: qty,
+		})
+	}
+	for _, e := range events {
+		if lots.AsOf == "" || e.date > lots.AsOf {
+			seeded = append(seeded, e)
+		}
+	}
+	return seeded
+}
+
+// AnalyzeHoldings 按交易、公司行动和持仓转移重建 asOf（YYYYMMDD，空为数据截止日）的持仓，
+// 并将每个账户最近报告日的重建结果与 OpenPositions 核对。
+// 重建从 opts.OpeningLots 开始，没有期初批次时以零持仓开始，数据期间之前已持有的标的会显示为差异
+func AnalyzeHoldings(statements []flex.FlexStatement, asOf string, opts Options) *HoldingsReport {
+	asOf = normalizeDate(asOf)
+	events := seedHoldings(holdingEvents(statements), statements, opts.OpeningLots)
+	report := &HoldingsReport{AsOf: isoDate(asOf)}
+	if asOf == "" {
+		_, to := reportPeriod(statements)
+		report.AsOf = isoDate(to)
+	}
+
+	for _, h := range replayHoldings(events, asOf) {
+		if math.Abs(h.Quantity) > 1e-9 {
+			report.Holdings = append(report.Holdings, *h)
+		}
+	}
+	sort.Slice(report.Holdings, func(i, j int) bool {
+		a, b := report.Holdings[i], report.Holdings[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		return a.Symbol < b.Symbol
+	})
+
+	// 核对日：账户最近的 OpenPositions 报告日，没有持仓时为账单截止日
+	checkDates := make(map[string]string)
+	positionDates := make(map[string]string)
+	for _, stmt := range statements {
+		checkDates[stmt.AccountID] = max(checkDates[stmt.AccountID], normalizeDate(stmt.ToDate))
+		for _, op := range stmt.OpenPositions {
+			positionDates[stmt.AccountID] = max(positionDates[stmt.AccountID], normalizeDate(op.ReportDate))
+		}
+	}
+	for account, d := range positionDates {
+		checkDates[account] = d
+	}
+	reported := latestPositions(statements)
+
+	for account, date := range checkDates {
+		rebuilt := replayHoldings(events, date)
+		symbols := make(map[string]bool)
+		for key, h := range rebuilt {
+			if h.AccountID == account && h.AssetCategory != "CASH" {
+				symbols[key] = true
+			}
+		}
+		for key, op := range reported {
+			if strings.HasPrefix(key, account+"|") && op.AssetCategory != "CASH" {
+				symbols[key] = true
+			}
+		}
+		for key := range symbols {
+			var got float64
+			if h := rebuilt[key]; h != nil {
+				got = h.Quantity
+			}
+			want := reported[key].Position
+			if got == 0 && want == 0 {
+				continue
+			}
+			report.Checked++
+			if math.Abs(want-got) > 1e-6 {
+				_, symbol, _ := strings.Cut(key, "|")
+				report.Mismatches = append(report.Mismatches, PositionMismatch{
+					AccountID: account, Symbol: symbol, ReportDate: isoDate(date),
+					Rebuilt: got, Reported: want, Difference: cleanFloat(want - got),
+				})
+			}
+		}
+	}
+	sort.Slice(report.Mismatches, func(i, j int) bool {
+		a, b := report.Mismatches[i], report.Mismatches[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		return a.Symbol < b.Symbol
+	})
+	return report
+}
+
+func PrintHoldingsReport(r *HoldingsReport) {
+	printTitle("持仓重建")
+	i18n.Printf("截至 %s\n\n", formatDate(normalizeDate(r.AsOf)))
+
+	if len(r.Holdings) == 0 {
+		i18n.Println("无持仓")
+	} else {
+		var rows [][]string
+		for _, h := range r.Holdings {
+			rows = append(rows, []string{h.AccountID, h.Symbol, h.AssetCategory, h.Currency, formatNumber(h.Quantity)})
+		}
+		printTable([]string{"账户", "标的", "类别", "币种", "数量"}, rows)
+	}
+	fmt.Println()
+
+	printSection("与 OpenPositions 核对")
+	if len(r.Mismatches) == 0 {
+		i18n.Printf("✓ 期末持仓与 OpenPositions 一致（%d 个持仓）\n", r.Checked)
+		return
+	}
+	var rows [][]string
+	for _, m := range r.Mismatches {
+		rows = append(rows, []string{m.AccountID, m.Symbol, formatDate(normalizeDate(m.ReportDate)),
+			formatNumber(m.Rebuilt), formatNumber(m.Reported), formatNumber(m.Difference)})
+	}
+	printTable([]string{"账户", "标的", "报告日", "重建数量", "报告数量", "差异"}, rows)
+	fmt.Println()
+	i18n.Printf("⚠ %d/%d 个持仓不一致。数据期间之前已持有、未列入期初批次（--lots-file、--lots-from）的标的，或 Flex Query 缺少 Corporate Actions、Transfers 段时会出现差异\n",
+		len(r.Mismatches), r.Checked)
+}
+
+// Tables 导出用的表：重建的持仓和核对差异
+func (r *HoldingsReport) Tables() []Table {
+	holdings := newTable("holdings", "持仓重建", "账户", "标的", "类别", "币种", "数量")
+	for _, h := range r.Holdings {
+		holdings.Rows = append(holdings.Rows, []any{h.AccountID, h.Symbol, h.AssetCategory, h.Currency, h.Quantity})
+	}
+	mismatches := newTable("mismatches", "与 OpenPositions 核对", "账户", "标的", "报告日", "重建数量", "报告数量", "差异")
+	for _, m := range r.Mismatches {
+		mismatches.Rows = append(mismatches.Rows, []any{m.AccountID, m.Symbol, m.ReportDate, m.Rebuilt, m.Reported, m.Difference})
+	}
+	return []Table{holdings, mismatches}
+}
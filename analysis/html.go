@@ -0,0 +1,251 @@
+package analysis
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+const htmlStyle = `
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; margin: 0 auto; max-width: 1040px; padding: 24px; color: #222; }
+h1 { margin-bottom: 4px; }
+h2 { margin-top: 36px; border-bottom: 1px solid #ddd; padding-bottom: 6px; }
+.meta { color: #777; font-size: 13px; }
+.cards { display: grid; grid-template-columns: repeat(auto-fill, minmax(180px, 1fr)); gap: 12px; margin-top: 20px; }
+.card { border: 1px solid #e5e5e5; border-radius: 6px; padding: 12px 14px; }
+.card .label { color: #777; font-size: 12px; }
+.card .value { font-size: 20px; font-weight: 600; margin-top: 4px; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; margin-top: 12px; }
+th, td { padding: 6px 10px; border-bottom: 1px solid #eee; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { background: #fafafa; }
+.pos { color: #2e7d32; }
+.neg { color: #c62828; }
+.chart { margin-top: 12px; }
+`
+
+// maxDonutSlices 超过此数量的持仓合并为"其他"
+const maxDonutSlices = 9
+
+// GenerateHTMLReport 生成单文件离线 HTML 报告，图表为内嵌 SVG
+func GenerateHTMLReport(statements []flex.FlexStatement, from, to string, opts Options) string {
+	data := BuildReportData(statements, from, to, opts)
+	summary, pnl, divs := data.Summary, data.PnL, data.Dividends
+
+	var b strings.Builder
+	htmlHead(&b, i18n.T("IBKR 账户报告"), "")
+
+	htmlHeading(&b, "h1", "IBKR 账户报告")
+	htmlMeta(&b, i18n.Sprintf("报告期间：%s — %s　生成时间：%s",
+		formatDate(data.PeriodFrom), formatDate(data.PeriodTo), i18n.DateTime(data.GeneratedAt)))
+
+	htmlSummaryCards(&b, summary)
+	htmlPnLCharts(&b, pnl)
+	htmlPositions(&b, summary)
+	htmlDividends(&b, divs)
+	htmlPnLBySymbol(&b, pnl)
+
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// htmlHead 输出文档头，title 已翻译，extraStyle 追加到内置样式之后
+func htmlHead(b *strings.Builder, title, extraStyle string) {
+	htmlLang := "zh-CN"
+	if i18n.Lang() == i18n.English {
+		htmlLang = "en"
+	}
+	fmt.Fprintf(b, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n", htmlLang)
+	fmt.Fprintf(b, "<title>%s</title>\n<style>", html.EscapeString(title))
+	b.WriteString(htmlStyle)
+	b.WriteString(extraStyle)
+	b.WriteString("</style>\n</head>\n<body>\n")
+}
+
+// htmlSummaryCards 概览卡片
+func htmlSummaryCards(b *strings.Builder, summary *SummaryReport) {
+	b.WriteString("<div class=\"cards\">\n")
+	htmlCard(b, "账户总值", fmtMoney(summary.AccountValue), 0)
+	htmlCard(b, "持仓市值", fmtMoney(summary.TotalValue), 0)
+	htmlCard(b, "现金余额", fmtMoney(summary.CashBalance), 0)
+	htmlCard(b, "已实现盈亏", fmtPnL(summary.TotalRealPnL), summary.TotalRealPnL)
+	htmlCard(b, "未实现盈亏", fmtPnL(summary.TotalUnrealPnL), summary.TotalUnrealPnL)
+	htmlCard(b, "净股息收入", fmtPnL(summary.TotalDivNet), summary.TotalDivNet)
+	htmlCard(b, "佣金支出", fmtPnL(summary.TotalCommission), summary.TotalCommission)
+	b.WriteString("</div>\n")
+}
+
+// htmlPnLCharts 累计已实现盈亏 + 月度盈亏
+func htmlPnLCharts(b *strings.Builder, pnl *PnLReport) {
+	if len(pnl.ByMonth) == 0 {
+		return
+	}
+	var cumulative, monthly []chartPoint
+	var sum float64
+	for _, m := range pnl.ByMonth {
+		sum += m.RealizedPnL
+		cumulative = append(cumulative, chartPoint{formatMonth(m.Period), sum})
+		monthly = append(monthly, chartPoint{formatMonth(m.Period), m.RealizedPnL})
+	}
+	htmlHeading(b, "h2", "累计已实现盈亏")
+	fmt.Fprintf(b, "<div class=\"chart\">%s</div>\n", svgLineChart(cumulative, "#4e79a7"))
+	htmlHeading(b, "h2", "月度已实现盈亏")
+	fmt.Fprintf(b, "<div class=\"chart\">%s</div>\n", svgBarChart(monthly))
+}
+
+// htmlPositions 持仓分布图和持仓明细
+func htmlPositions(b *strings.Builder, summary *SummaryReport) {
+	if len(summary.Positions) == 0 {
+		return
+	}
+	htmlHeading(b, "h2", "持仓分布")
+	fmt.Fprintf(b, "<div class=\"chart\">%s</div>\n", svgDonut(allocationPoints(summary.Positions)))
+
+	var rows [][]string
+	for _, p := range summary.Positions {
+		pct := 0.0
+		if summary.TotalValue > 0 {
+			pct = p.BaseValue() / summary.TotalValue * 100
+		}
+		rows = append(rows, []string{
+			p.Symbol,
+			fmt.Sprintf("%.4g", p.Position),
+			fmtMoney(p.MarkPrice),
+			fmtMoney(p.CostBasis),
+			fmtMoney(p.PositionValue),
+			fmtPnL(p.UnrealizedPnL),
+			fmt.Sprintf("%.1f%%", pct),
+		})
+	}
+	htmlTable(b, []string{"标的", "数量", "现价", "成本价", "市值", "未实现P&L", "占比"}, rows, 5)
+}
+
+// htmlDividends 月度股息图和按标的明细
+func htmlDividends(b *strings.Builder, divs *DividendReport) {
+	if len(divs.ByMonth) == 0 {
+		return
+	}
+	var monthly []chartPoint
+	for _, m := range divs.ByMonth {
+		monthly = append(monthly, chartPoint{formatMonth(m.Period), m.Net})
+	}
+	htmlHeading(b, "h2", "月度股息收入")
+	htmlMeta(b, i18n.Sprintf("总股息：%s　预扣税：%s　净收入：%s　派息次数：%d",
+		fmtMoney(divs.TotalGross), fmtMoney(divs.TotalWithhold), fmtMoney(divs.TotalNet), divs.TotalCount))
+	fmt.Fprintf(b, "<div class=\"chart\">%s</div>\n", svgBarChart(monthly))
+
+	var rows [][]string
+	for _, s := range divs.BySymbol {
+		rows = append(rows, []string{
+			s.Symbol,
+			fmtMoney(s.Gross),
+			fmtMoney(s.Withholding),
+			fmtMoney(s.Net),
+			fmt.Sprintf("%d", s.Transactions),
+		})
+	}
+	htmlTable(b, []string{"标的", "税前股息", "预扣税", "净收入", "次数"}, rows)
+}
+
+// htmlPnLBySymbol 已实现盈亏按标的明细
+func htmlPnLBySymbol(b *strings.Builder, pnl *PnLReport) {
+	if len(pnl.BySymbol) == 0 {
+		return
+	}
+	htmlHeading(b, "h2", "已实现盈亏明细")
+	htmlMeta(b, i18n.Sprintf("平仓交易数：%d 笔　胜率：%.1f%%　总佣金：%s",
+		pnl.TotalTrades, pnl.WinRate, fmtMoney(pnl.TotalComm)))
+	var rows [][]string
+	for _, s := range pnl.BySymbol {
+		wr := 0.0
+		if s.Trades > 0 {
+			wr = float64(s.Wins) / float64(s.Trades) * 100
+		}
+		rows = append(rows, []string{
+			s.Symbol,
+			fmtPnL(s.RealizedPnL),
+			fmt.Sprintf("%d", s.Trades),
+			fmt.Sprintf("%.0f%%", wr),
+			fmtMoney(s.Commission),
+		})
+	}
+	htmlTable(b, []string{"标的", "已实现P&L", "交易数", "胜率", "佣金"}, rows, 1)
+}
+
+// allocationPoints 按市值取前几大持仓，其余合并为"其他"
+func allocationPoints(positions []PositionSummary) []chartPoint {
+	sorted := make([]PositionSummary, len(positions))
+	copy(sorted, positions)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].BaseValue() > sorted[j].BaseValue()
+	})
+
+	var points []chartPoint
+	var other float64
+	for i, p := range sorted {
+		if i < maxDonutSlices {
+			points = append(points, chartPoint{p.Symbol, p.BaseValue()})
+		} else {
+			other += p.BaseValue()
+		}
+	}
+	if other > 0 {
+		points = append(points, chartPoint{i18n.T("其他"), other})
+	}
+	return points
+}
+
+func htmlCard(b *strings.Builder, label, value string, sign float64) {
+	class := ""
+	if sign > 0 {
+		class = " pos"
+	} else if sign < 0 {
+		class = " neg"
+	}
+	fmt.Fprintf(b, "<div class=\"card\"><div class=\"label\">%s</div><div class=\"value%s\">%s</div></div>\n",
+		html.EscapeString(i18n.T(label)), class, html.EscapeString(value))
+}
+
+// htmlHeading 输出翻译后的标题
+func htmlHeading(b *strings.Builder, tag, title string) {
+	fmt.Fprintf(b, "<%s>%s</%s>\n", tag, html.EscapeString(i18n.T(title)), tag)
+}
+
+// htmlMeta 输出说明文字（已翻译）
+func htmlMeta(b *strings.Builder, text string) {
+	fmt.Fprintf(b, "<div class=\"meta\">%s</div>\n", html.EscapeString(text))
+}
+
+// htmlTable 输出表格，pnlCols 指定的列（fmtPnL 格式）按正负着色
+func htmlTable(b *strings.Builder, headers []string, rows [][]string, pnlCols ...int) {
+	colored := make(map[int]bool)
+	for _, c := range pnlCols {
+		colored[c] = true
+	}
+
+	b.WriteString("<table>\n<tr>")
+	for _, h := range headers {
+		fmt.Fprintf(b, "<th>%s</th>", html.EscapeString(i18n.T(h)))
+	}
+	b.WriteString("</tr>\n")
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for i, cell := range row {
+			class := ""
+			if colored[i] {
+				if strings.HasPrefix(cell, "+") {
+					class = ` class="pos"`
+				} else if strings.HasPrefix(cell, "-") {
+					class = ` class="neg"`
+				}
+			}
+			fmt.Fprintf(b, "<td%s>%s</td>", class, html.EscapeString(cell))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+}
@@ -0,0 +1,693 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// JournalAccounts 记账导出（beancount / ledger）使用的账户名，对应配置中的 [accounts]
+type JournalAccounts struct {
+	Cash            string `mapstructure:"cash"`
+	Securities      string `mapstructure:"securities"`
+	CapitalGains    string `mapstructure:"capital_gains"`
+	Dividends       string `mapstructure:"dividends"`
+	Interest        string `mapstructure:"interest"`
+	InterestExpense string `mapstructure:"interest_expense"`
+	WithholdingTax  string `mapstructure:"withholding_tax"`
+	Commissions     string `mapstructure:"commissions"`
+	Fees            string `mapstructure:"fees"`
+	Transfers       string `mapstructure:"transfers"`
+	OpeningBalances string `mapstructure:"opening_balances"`
+	Other           string `mapstructure:"other"`
+}
+
+// DefaultJournalAccounts 未配置的账户使用的默认名
+var DefaultJournalAccounts = JournalAccounts{
+	Cash:            "Assets:IBKR:Cash",
+	Securities:      "Assets:IBKR:Securities",
+	CapitalGains:    "Income:IBKR:CapitalGains",
+	Dividends:       "Income:IBKR:Dividends",
+	Interest:        "Income:IBKR:Interest",
+	InterestExpense: "Expenses:IBKR:Interest",
+	WithholdingTax:  "Expenses:Taxes:Withholding",
+	Commissions:     "Expenses:IBKR:Commissions",
+	Fees:            "Expenses:IBKR:Fees",
+	Transfers:       "Equity:IBKR:Transfers",
+	OpeningBalances: "Equity:Opening-Balances",
+	Other:           "Income:IBKR:Other",
+}
+
+// WithDefaults 用默认账户名补齐未配置的项
+func (a JournalAccounts) WithDefaults() JournalAccounts {
+	fill := func(v *string, def string) {
+		if *v == "" {
+			*v = def
+		}
+	}
+	d := DefaultJournalAccounts
+	fill(&a.Cash, d.Cash)
+	fill(&a.Securities, d.Securities)
+	fill(&a.CapitalGains, d.CapitalGains)
+	fill(&a.Dividends, d.Dividends)
+	fill(&a.Interest, d.Interest)
+	fill(&a.InterestExpense, d.InterestExpense)
+	fill(&a.WithholdingTax, d.WithholdingTax)
+	fill(&a.Commissions, d.Commissions)
+	fill(&a.Fees, d.Fees)
+	fill(&a.Transfers, d.Transfers)
+	fill(&a.OpeningBalances, d.OpeningBalances)
+	fill(&a.Other, d.Other)
+	return a
+}
+
+// 分录类型，同一天内按此顺序输出（余额断言放在当天所有交易之后）
+const (
+	entryOpening = iota
+	entryTxn
+	entryBalance
+)
+
+// journalPosting 一条记账行。Lot 非空时为持仓批次（附成本和开仓日期），
+// Price 非空时为换汇的总价（@@）
+type journalPosting struct {
+	Account   string
+	Amount    float64
+	Commodity string
+	Lot       *Lot
+	Price     *journalAmount
+}
+
+type journalAmount struct {
+	Amount    float64
+	Commodity string
+}
+
+// journalEntry 一条分录；ID 写入元数据 ibkr_id，再次导出时据此去重
+type journalEntry struct {
+	ID        string
+	Kind      int
+	Date      string // YYYYMMDD
+	Narration string
+	Symbol    string
+	Postings  []journalPosting
+	Balance   journalAmount // Kind 为 entryBalance 时断言的余额，账户为 Postings[0].Account
+	Units     bool          // 断言的是证券数量而非现金
+}
+
+var journalIDPattern = regexp.MustCompile(`ibkr_id:\s*"?([^"\s]+)`)
+
+// JournalIDs 读取已有记账文件中的 ibkr_id，用于增量导出
+func JournalIDs(content []byte) map[string]bool {
+	ids := make(map[string]bool)
+	for _, m := range journalIDPattern.FindAllSubmatch(content, -1) {
+		ids[string(m[1])] = true
+	}
+	return ids
+}
+
+// ExportJournal 把期初批次、交易和持仓转账（附 FIFO 批次成本）、股息、预扣税、费用、利息、出入金和换汇生成为
+// 借贷平衡的分录，并按 CashReport 期末现金和 OpenPositions 持仓生成余额断言。
+// format 为 beancount 或 ledger；seen 中已有的 ibkr_id 跳过，返回新分录文本和条数
+func ExportJournal(statements []flex.FlexStatement, from, to, format string, accounts JournalAccounts, balances bool, seen map[string]bool, opts Options) (string, int, error) {
+	if format != "beancount" && format != "ledger" {
+		return "", 0, i18n.Errorf("不支持的记账格式: %s (可用: beancount, ledger)", format)
+	}
+	accounts = accounts.WithDefaults()
+
+	entries := journalTrades(statements, accounts, opts.OpeningLots)
+	entries = append(entries, journalCash(statements, accounts)...)
+	if balances {
+		entries = append(entries, journalBalances(statements, accounts)...)
+	}
+
+	var fresh []journalEntry
+	for _, e := range entries {
+		if seen[e.ID] || !inDateRange(e.Date, from, to) {
+			continue
+		}
+		seen[e.ID] = true
+		fresh = append(fresh, e)
+	}
+	sort.SliceStable(fresh, func(i, j int) bool {
+		if fresh[i].Date != fresh[j].Date {
+			return fresh[i].Date < fresh[j].Date
+		}
+		return fresh[i].Kind < fresh[j].Kind
+	})
+
+	var b strings.Builder
+	if format == "beancount" {
+		writeBeancountOpen(&b, fresh, seen)
+		for _, e := range fresh {
+			writeBeancountEntry(&b, e)
+		}
+	} else {
+		for _, e := range fresh {
+			writeLedgerEntry(&b, e)
+		}
+	}
+	return b.String(), len(fresh), nil
+}
+
+// journalTrades 期初批次、交易和持仓转入转出分录。从期初批次开始，所有交易和持仓转账（不受 from/to 限制）
+// 按时间送入批次引擎，保证平仓批次的成本正确，与已实现盈亏、盈亏核对一致；已包含在期初批次中的不再记账。
+// 多个 query 中重复出现的交易和转账按 TransactionID 只计一次
+func journalTrades(statements []flex.FlexStatement, accounts JournalAccounts, lots *OpeningLots) []journalEntry {
+	lots = withLotCurrencies(lots, statements)
+	engine, replay := newSeededLotEngine(lots)
+	entries := journalOpeningLots(lots, accounts)
+
+	// 持仓转账按转账市值折成一笔交易送入批次引擎，transfers 记录其分录 ID 和说明
+	trades := uniqueTrades(statements)
+	transfers := make(map[string]flex.Transfer)
+	for _, tr := range uniqueTransfers(statements) {
+		if isCashTransfer(tr) || tr.Quantity == 0 {
+			continue
+		}
+		id := transferEntryID(tr)
+		transfers[id] = tr
+		trades = append(trades, flex.Trade{
+			Currency:      tr.Currency,
+			AssetCategory: tr.AssetCategory,
+			Symbol:        tr.Symbol,
+			TradeDate:     datePart(tr.DateTime),
+			DateTime:      tr.DateTime,
+			Quantity:      transferSign(tr) * math.Abs(tr.Quantity),
+			Proceeds:      -transferValue(tr),
+			TransactionID: id,
+		})
+	}
+
+	for _, t := range sortTradesByTime(trades) {
+		if !isFXTrade(t) && !replay(t) {
+			continue
+		}
+		if tr, ok := transfers[t.TransactionID]; ok {
+			if e, ok := journalTransfer(tr, engine.Apply(t), accounts); ok {
+				entries = append(entries, e)
+			}
+			continue
+		}
+		e := journalEntry{
+			ID:        tradeEntryID(t),
+			Kind:      entryTxn,
+			Date:      normalizeDate(t.TradeDate),
+			Narration: tradeNarration(t),
+			Symbol:    t.Symbol,
+		}
+		if isFXTrade(t) {
+			e.Postings = fxPostings(t, accounts)
+			entries = append(entries, e)
+			continue
+		}
+
+		// 佣金单独记入费用，批次成本只含成交金额
+		lotTrade := t
+		lotTrade.Commission = 0
+		fill := engine.Apply(lotTrade)
+		e.Postings = lotPostings(t.Symbol, fill, accounts)
+		if t.Proceeds != 0 {
+			e.Postings = append(e.Postings, journalPosting{Account: accounts.Cash, Amount: t.Proceeds, Commodity: t.Currency})
+		}
+		e.Postings = append(e.Postings, costPostings(t, accounts)...)
+		if math.Abs(fill.Realized) > 1e-9 {
+			e.Postings = append(e.Postings, journalPosting{Account: accounts.CapitalGains, Amount: -fill.Realized, Commodity: t.Currency})
+		}
+		if len(e.Postings) > 0 {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// lotPostings 一笔成交平掉和新开的批次，各记一条证券持仓
+func lotPostings(symbol string, fill LotFill, accounts JournalAccounts) []journalPosting {
+	var postings []journalPosting
+	commodity := commodityName(symbol)
+	for _, m := range fill.Closed {
+		lot := m.Lot
+		qty := lot.Quantity // 平多头为卖出（负数），平空头为买回（正数）
+		if !lot.Short {
+			qty = -qty
+		}
+		postings = append(postings, journalPosting{Account: accounts.Securities, Amount: qty, Commodity: commodity, Lot: &lot})
+	}
+	if fill.Opened != nil {
+		lot := *fill.Opened
+		qty := lot.Quantity
+		if lot.Short {
+			qty = -qty
+		}
+		postings = append(postings, journalPosting{Account: accounts.Securities, Amount: qty, Commodity: commodity, Lot: &lot})
+	}
+	return postings
+}
+
+// journalTransfer 持仓转入转出：转入按转账市值开批次，转出按 FIFO 以原成本平掉批次（不产生已实现盈亏），
+// 对方为出入金权益账户，金额为所记批次的成本
+func journalTransfer(tr flex.Transfer, fill LotFill, accounts JournalAccounts) (journalEntry, bool) {
+	postings := lotPostings(tr.Symbol, fill, accounts)
+	if len(postings) == 0 {
+		return journalEntry{}, false
+	}
+	var cost float64
+	for _, p := range postings {
+		cost += p.Amount * p.Lot.UnitCost
+	}
+	postings = append(postings, journalPosting{Account: accounts.Transfers, Amount: -cost, Commodity: tr.Currency})
+
+	narration := tr.Description
+	if narration == "" {
+		narration = fmt.Sprintf("%s %s %s %s", tr.Type, strings.ToUpper(tr.Direction), formatNumber(math.Abs(tr.Quantity)), tr.Symbol)
+	}
+	return journalEntry{
+		ID:        transferEntryID(tr),
+		Kind:      entryTxn,
+		Date:      datePart(tr.DateTime),
+		Narration: narration,
+		Symbol:    tr.Symbol,
+		Postings:  postings,
+	}, true
+}
+
+// withLotCurrencies 返回补齐币种的期初批次副本：批次文件可不填币种，取该标的交易的币种，没有交易时取基础货币
+func withLotCurrencies(lots *OpeningLots, statements []flex.FlexStatement) *OpeningLots {
+	if lots == nil {
+		return nil
+	}
+	currencies := make(map[string]string)
+	for _, t := range uniqueTrades(statements) {
+		if currencies[t.Symbol] == "" {
+			currencies[t.Symbol] = t.Currency
+		}
+	}
+	base := baseCurrency(statements)
+	filled := &OpeningLots{AsOf: lots.AsOf, Lots: make([]Lot, len(lots.Lots))}
+	for i, l := range lots.Lots {
+		if l.Currency == "" {
+			l.Currency = currencies[l.Symbol]
+		}
+		if l.Currency == "" {
+			l.Currency = base
+		}
+		filled.Lots[i] = l
+	}
+	return filled
+}
+
+// journalOpeningLots 期初批次的期初分录，每个标的一条，对方为期初权益账户。
+// 日期为期初批次的日期，手工维护的批次文件没有日期时取最晚的开仓日期
+func journalOpeningLots(lots *OpeningLots, accounts JournalAccounts) []journalEntry {
+	if lots == nil || len(lots.Lots) == 0 {
+		return nil
+	}
+	date := lots.AsOf
+	if date == "" {
+		for _, l := range lots.Lots {
+			date = max(date, l.Date)
+		}
+	}
+
+	bySymbol := make(map[string]*journalEntry)
+	var symbols []string
+	for _, l := range lots.Lots {
+		e := bySymbol[l.Symbol]
+		if e == nil {
+			e = &journalEntry{
+				ID:        fmt.Sprintf("opening:%s:%s", accounts.Securities, commodityName(l.Symbol)),
+				Kind:      entryOpening,
+				Date:      date,
+				Narration: i18n.T("期初持仓"),
+				Symbol:    l.Symbol,
+			}
+			bySymbol[l.Symbol] = e
+			symbols = append(symbols, l.Symbol)
+		}
+		lot := l
+		qty, cost := l.Quantity, l.Quantity*l.UnitCost
+		if l.Short {
+			qty, cost = -qty, -cost
+		}
+		e.Postings = append(e.Postings,
+			journalPosting{Account: accounts.Securities, Amount: qty, Commodity: commodityName(l.Symbol), Lot: &lot},
+			journalPosting{Account: accounts.OpeningBalances, Amount: -cost, Commodity: l.Currency},
+		)
+	}
+	sort.Strings(symbols)
+
+	entries := make([]journalEntry, 0, len(symbols))
+	for _, s := range symbols {
+		entries = append(entries, *bySymbol[s])
+	}
+	return entries
+}
+
+// fxPostings 换汇：卖出币种按总价 @@ 折成买入币种，佣金单独记费用
+func fxPostings(t flex.Trade, accounts JournalAccounts) []journalPosting {
+	base, quote := splitPair(t.Symbol)
+	if quote == "" {
+		quote = t.Currency
+	}
+	postings := []journalPosting{
+		{Account: accounts.Cash, Amount: t.Quantity, Commodity: base, Price: &journalAmount{math.Abs(t.Proceeds), quote}},
+		{Account: accounts.Cash, Amount: t.Proceeds, Commodity: quote},
+	}
+	return append(postings, costPostings(t, accounts)...)
+}
+
+// costPostings 交易的佣金（按佣金币种）和交易税，各自与现金配对，使分录在每个币种内平衡
+func costPostings(t flex.Trade, accounts JournalAccounts) []journalPosting {
+	var postings []journalPosting
+	if t.Commission != 0 {
+		commCurr := t.CommissionCurr
+		if commCurr == "" {
+			commCurr = t.Currency
+		}
+		postings = append(postings,
+			journalPosting{Account: accounts.Commissions, Amount: -t.Commission, Commodity: commCurr},
+			journalPosting{Account: accounts.Cash, Amount: t.Commission, Commodity: commCurr},
+		)
+	}
+	if t.Taxes != 0 {
+		postings = append(postings,
+			journalPosting{Account: accounts.Fees, Amount: -t.Taxes, Commodity: t.Currency},
+			journalPosting{Account: accounts.Cash, Amount: t.Taxes, Commodity: t.Currency},
+		)
+	}
+	return postings
+}
+
+func tradeEntryID(t flex.Trade) string {
+	if t.TransactionID != "" {
+		return "trade:" + t.TransactionID
+	}
+	return fmt.Sprintf("trade:%s:%s:%g", t.DateTime, strings.ReplaceAll(t.Symbol, " ", ""), t.Quantity)
+}
+
+func cashEntryID(ct flex.CashTransaction) string {
+	if ct.TransactionID != "" {
+		return "cash:" + ct.TransactionID
+	}
+	return fmt.Sprintf("cash:%s:%s:%s:%g", ct.DateTime, ct.Type, strings.ReplaceAll(ct.Symbol, " ", ""), ct.Amount)
+}
+
+// transferEntryID 转账的分录 ID；没有 TransactionID 时现金转账按币种和金额、持仓转账按代码和数量识别
+func transferEntryID(tr flex.Transfer) string {
+	switch {
+	case tr.TransactionID != "":
+		return "transfer:" + tr.TransactionID
+	case isCashTransfer(tr):
+		return fmt.Sprintf("transfer:%s:%s:%g", tr.DateTime, tr.Currency, tr.Amount)
+	}
+	return fmt.Sprintf("transfer:%s:%s:%g", tr.DateTime, strings.ReplaceAll(tr.Symbol, " ", ""), tr.Quantity)
+}
+
+func tradeNarration(t flex.Trade) string {
+	if t.TransactionType == "BookTrade" {
+		return t.Description
+	}
+	return fmt.Sprintf("%s %s %s @ %s", t.BuySell, formatNumber(math.Abs(t.Quantity)), t.Symbol, formatNumber(t.TradePrice))
+}
+
+// journalCash 股息、预扣税、费用、利息、出入金（CashTransactions 和 Transfers 中的现金转账）
+func journalCash(statements []flex.FlexStatement, accounts JournalAccounts) []journalEntry {
+	var entries []journalEntry
+	add := func(id, date, narration, symbol, currency, counter string, amount float64) {
+		entries = append(entries, journalEntry{
+			ID:        id,
+			Kind:      entryTxn,
+			Date:      date,
+			Narration: narration,
+			Symbol:    symbol,
+			Postings: []journalPosting{
+				{Account: accounts.Cash, Amount: amount, Commodity: currency},
+				{Account: counter, Amount: -amount, Commodity: currency},
+			},
+		})
+	}
+
+	for _, stmt := range statements {
+		for _, ct := range stmt.CashTransactions {
+			if ct.Amount == 0 {
+				continue
+			}
+			id := cashEntryID(ct)
+			add(id, cashDate(ct), ct.Description, ct.Symbol, ct.Currency, cashCounterAccount(ct, accounts), ct.Amount)
+		}
+		for _, tr := range stmt.Transfers {
+			if tr.Amount == 0 || !isCashTransfer(tr) {
+				continue
+			}
+			add(transferEntryID(tr), datePart(tr.DateTime), tr.Description, "", tr.Currency, accounts.Transfers, tr.Amount)
+		}
+	}
+	return entries
+}
+
+// cashCounterAccount CashTransaction 的对方账户
+func cashCounterAccount(ct flex.CashTransaction, accounts JournalAccounts) string {
+	switch ct.Type {
+	case "Dividends", "Payment In Lieu Of Dividends":
+		return accounts.Dividends
+	case "Withholding Tax":
+		return accounts.WithholdingTax
+	case "Broker Interest Received", "Bond Interest Received":
+		return accounts.Interest
+	case "Broker Interest Paid", "Bond Interest Paid":
+		return accounts.InterestExpense
+	case "Commission Adjustments":
+		return accounts.Commissions
+	case "Other Fees", "Broker Fees":
+		return accounts.Fees
+	case "Deposits/Withdrawals", "Deposits & Withdrawals":
+		return accounts.Transfers
+	}
+	return accounts.Other
+}
+
+// journalBalances 期初现金（CashReport startingCash）和余额断言（endingCash、OpenPositions）。
+// 期初分录每个币种只生成一次；同一日期、账户、品种的断言只取第一条（多个 query 可能重复）
+func journalBalances(statements []flex.FlexStatement, accounts JournalAccounts) []journalEntry {
+	var entries []journalEntry
+	assert := func(date, account string, amount float64, commodity string, units bool) {
+		entries = append(entries, journalEntry{
+			ID:       fmt.Sprintf("balance:%s:%s:%s", date, account, commodity),
+			Kind:     entryBalance,
+			Date:     date,
+			Postings: []journalPosting{{Account: account, Commodity: commodity}},
+			Balance:  journalAmount{amount, commodity},
+			Units:    units,
+		})
+	}
+
+	for _, stmt := range statements {
+		for _, cr := range stmt.CashReport {
+			if cr.Currency == "BASE_SUMMARY" || cr.LevelOfDetail == "BaseCurrency" {
+				continue
+			}
+			if cr.StartingCash != 0 {
+				entries = append(entries, journalEntry{
+					ID:        fmt.Sprintf("opening:%s:%s", accounts.Cash, cr.Currency),
+					Kind:      entryOpening,
+					Date:      normalizeDate(cr.FromDate),
+					Narration: i18n.T("期初余额"),
+					Postings: []journalPosting{
+						{Account: accounts.Cash, Amount: cr.StartingCash, Commodity: cr.Currency},
+						{Account: accounts.OpeningBalances, Amount: -cr.StartingCash, Commodity: cr.Currency},
+					},
+				})
+			}
+			assert(normalizeDate(cr.ToDate), accounts.Cash, cr.EndingCash, cr.Currency, false)
+		}
+		for _, op := range stmt.OpenPositions {
+			if op.LevelOfDetail == "LOT" || op.Position == 0 {
+				continue
+			}
+			assert(normalizeDate(op.ReportDate), accounts.Securities, op.Position, commodityName(op.Symbol), true)
+		}
+	}
+	return entries
+}
+
+// commodityName 把 IBKR 代码转成 beancount 允许的商品名：大写字母开头、最长 24 个字符，
+// 只含 A-Z 0-9 ' . _ -（如 "TSLA  250117P00200000" → "TSLA.250117P00200000"，"700" → "X700"）
+func commodityName(symbol string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(strings.TrimSpace(symbol)) {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("'._-", r):
+			b.WriteRune(r)
+		case !strings.HasSuffix(b.String(), "."):
+			b.WriteRune('.')
+		}
+	}
+	name := b.String()
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		name = "X" + name
+	}
+	if len(name) > 24 {
+		name = name[:24]
+	}
+	return strings.TrimRight(name, "'._-")
+}
+
+// formatNumber 去掉浮点误差后的最短表示
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(cleanFloat(v), 'f', -1, 64)
+}
+
+// formatJournalAmount 金额至少保留两位小数
+func formatJournalAmount(v float64) string {
+	s := formatNumber(v)
+	dot := strings.IndexByte(s, '.')
+	switch {
+	case dot < 0:
+		return s + ".00"
+	case len(s)-dot == 2:
+		return s + "0"
+	}
+	return s
+}
+
+// formatPostingAmount 证券数量原样输出，货币金额保留两位小数
+func formatPostingAmount(p journalPosting) string {
+	if p.Lot != nil {
+		return formatNumber(p.Amount)
+	}
+	return formatJournalAmount(p.Amount)
+}
+
+// nextDay beancount 的 balance 在当天开始时检查，期末余额需断言在次日
+func nextDay(date string) string {
+	t, err := time.Parse("20060102", normalizeDate(date))
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, 1).Format("20060102")
+}
+
+func quoteBeancount(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// oneLine 分录描述压成一行，连续空白合并（ledger 中两个空格有特殊含义）
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// accountWidth 对齐金额列
+func accountWidth(postings []journalPosting) int {
+	width := 0
+	for _, p := range postings {
+		width = max(width, len(p.Account))
+	}
+	return width
+}
+
+// writeBeancountOpen 为本次新出现的账户生成 open 指令，日期取最早一条分录
+func writeBeancountOpen(b *strings.Builder, entries []journalEntry, seen map[string]bool) {
+	if len(entries) == 0 {
+		return
+	}
+	var accounts []string
+	for _, e := range entries {
+		for _, p := range e.Postings {
+			id := "open:" + p.Account
+			if !seen[id] {
+				seen[id] = true
+				accounts = append(accounts, p.Account)
+			}
+		}
+	}
+	sort.Strings(accounts)
+	for _, a := range accounts {
+		fmt.Fprintf(b, "%s open %s\n  ibkr_id: %s\n", isoDate(entries[0].Date), a, quoteBeancount("open:"+a))
+	}
+	if len(accounts) > 0 {
+		b.WriteString("\n")
+	}
+}
+
+func writeBeancountEntry(b *strings.Builder, e journalEntry) {
+	if e.Kind == entryBalance {
+		fmt.Fprintf(b, "%s balance %s  %s %s\n  ibkr_id: %s\n\n",
+			isoDate(nextDay(e.Date)), e.Postings[0].Account, formatBalance(e), e.Balance.Commodity, quoteBeancount(e.ID))
+		return
+	}
+
+	fmt.Fprintf(b, "%s * %s\n", isoDate(e.Date), quoteBeancount(oneLine(e.Narration)))
+	fmt.Fprintf(b, "  ibkr_id: %s\n", quoteBeancount(e.ID))
+	if e.Symbol != "" {
+		fmt.Fprintf(b, "  symbol: %s\n", quoteBeancount(e.Symbol))
+	}
+	width := accountWidth(e.Postings)
+	for _, p := range e.Postings {
+		fmt.Fprintf(b, "  %-*s  %s %s", width, p.Account, formatPostingAmount(p), p.Commodity)
+		if p.Lot != nil {
+			fmt.Fprintf(b, " {%s %s, %s}", formatNumber(p.Lot.UnitCost), p.Lot.Currency, isoDate(p.Lot.Date))
+		}
+		if p.Price != nil {
+			fmt.Fprintf(b, " @@ %s %s", formatJournalAmount(p.Price.Amount), p.Price.Commodity)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+}
+
+// ledgerCommodity ledger/hledger 中含数字或符号的商品名需加引号
+func ledgerCommodity(c string) string {
+	for _, r := range c {
+		if r < 'A' || r > 'Z' {
+			return `"` + c + `"`
+		}
+	}
+	return c
+}
+
+func writeLedgerEntry(b *strings.Builder, e journalEntry) {
+	narration := e.Narration
+	if e.Kind == entryBalance {
+		narration = i18n.T("余额断言")
+	}
+	fmt.Fprintf(b, "%s * %s\n", isoDate(e.Date), oneLine(narration))
+	fmt.Fprintf(b, "    ; ibkr_id: %s\n", e.ID)
+	if e.Symbol != "" {
+		fmt.Fprintf(b, "    ; symbol: %s\n", e.Symbol)
+	}
+	width := accountWidth(e.Postings)
+	if e.Kind == entryBalance {
+		p := e.Postings[0]
+		c := ledgerCommodity(e.Balance.Commodity)
+		fmt.Fprintf(b, "    %-*s  0 %s = %s %s\n\n", width, p.Account, c, formatBalance(e), c)
+		return
+	}
+	for _, p := range e.Postings {
+		fmt.Fprintf(b, "    %-*s  %s %s", width, p.Account, formatPostingAmount(p), ledgerCommodity(p.Commodity))
+		if p.Lot != nil {
+			fmt.Fprintf(b, " {%s %s} [%s]", formatNumber(p.Lot.UnitCost), p.Lot.Currency, isoDate(p.Lot.Date))
+		}
+		if p.Price != nil {
+			fmt.Fprintf(b, " @@ %s %s", formatJournalAmount(p.Price.Amount), ledgerCommodity(p.Price.Commodity))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+}
+
+// formatBalance 证券数量原样输出，现金保留两位小数
+func formatBalance(e journalEntry) string {
+	if e.Units {
+		return formatNumber(e.Balance.Amount)
+	}
+	return formatJournalAmount(e.Balance.Amount)
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// TestJournalEntriesBalance 每条分录在每个币种内借贷平衡：批次按成本、换汇按 @@ 总价计
+func TestJournalEntriesBalance(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		lots *OpeningLots
+	}{
+		{name: "trades, FX, cash and in-kind transfer", file: "attribution_transfer.xml"},
+		{
+			name: "sales close opening lots",
+			file: "attribution.xml",
+			lots: &OpeningLots{Lots: []Lot{{Symbol: "AAPL", Date: "20241201", Quantity: 100, UnitCost: 120}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := readTestStatements(t, tt.file)
+			accounts := JournalAccounts{}.WithDefaults()
+			entries := journalTrades(statements, accounts, tt.lots)
+			entries = append(entries, journalCash(statements, accounts)...)
+			if len(entries) == 0 {
+				t.Fatal("no entries")
+			}
+			for _, e := range entries {
+				weights := make(map[string]float64)
+				for _, p := range e.Postings {
+					switch {
+					case p.Lot != nil:
+						weights[p.Lot.Currency] += p.Amount * p.Lot.UnitCost
+					case p.Price != nil:
+						weights[p.Price.Commodity] += math.Copysign(p.Price.Amount, p.Amount)
+					default:
+						weights[p.Commodity] += p.Amount
+					}
+				}
+				for currency, w := range weights {
+					if !approxEqual(w, 0) {
+						t.Errorf("%s (%s) unbalanced by %g %s: %+v", e.ID, e.Narration, w, currency, e.Postings)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+// SchemaVersion JSON 输出的格式版本，字段改名、删除或含义变化时递增；新增字段不递增
+const SchemaVersion = 1
+
+// JSONEnvelope analyze --format json 的输出外层，字段说明见 README「JSON 输出」
+type JSONEnvelope struct {
+	SchemaVersion int       `json:"schema_version"`
+	Kind          string    `json:"kind"` // pnl、orders、dividends、commissions、fees、fx、summary
+	GeneratedAt   time.Time `json:"generated_at"`
+	Currency      string    `json:"currency"`    // 记录中没有单独 currency 字段的金额均为此币种（基础货币）
+	PeriodFrom    string    `json:"period_from"` // YYYY-MM-DD
+	PeriodTo      string    `json:"period_to"`
+	Data          any       `json:"data"`
+}
+
+// NewJSONEnvelope 包装分析结果；未指定 from/to 时取数据覆盖的期间
+func NewJSONEnvelope(kind string, statements []flex.FlexStatement, from, to string, data any) *JSONEnvelope {
+	periodFrom, periodTo := reportPeriod(statements)
+	if from != "" {
+		periodFrom = from
+	}
+	if to != "" {
+		periodTo = to
+	}
+	return &JSONEnvelope{
+		SchemaVersion: SchemaVersion,
+		Kind:          kind,
+		GeneratedAt:   time.Now().Truncate(time.Second),
+		Currency:      baseCurrency(statements),
+		PeriodFrom:    isoDate(periodFrom),
+		PeriodTo:      isoDate(periodTo),
+		Data:          data,
+	}
+}
+
+// ndjsonLine NDJSON 的一行
+type ndjsonLine struct {
+	SchemaVersion int    `json:"schema_version"`
+	Kind          string `json:"kind"`
+	Currency      string `json:"currency"`
+	Section       string `json:"section"`
+	Record        any    `json:"record"`
+}
+
+// WriteNDJSON 以 NDJSON 输出：data 中每个数组元素一行，section 为字段路径
+// （如 "by_symbol"、"efficiency.by_exchange"）；各层的标量字段合并为一行，
+// 顶层 section 为 "totals"，嵌套对象为其路径（如 "efficiency"）
+func WriteNDJSON(w io.Writer, env *JSONEnvelope) error {
+	raw, err := json.Marshal(env.Data)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	emit := func(section string, record any) error {
+		return enc.Encode(ndjsonLine{
+			SchemaVersion: env.SchemaVersion,
+			Kind:          env.Kind,
+			Currency:      env.Currency,
+			Section:       section,
+			Record:        record,
+		})
+	}
+	return writeNDJSONObject(root, "", emit)
+}
+
+func writeNDJSONObject(obj map[string]any, path string, emit func(string, any) error) error {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	scalars := make(map[string]any)
+	type nested struct {
+		path  string
+		value any
+	}
+	var children []nested
+	for _, k := range keys {
+		childPath := k
+		if path != "" {
+			childPath = path + "." + k
+		}
+		switch v := obj[k].(type) {
+		case map[string]any:
+			children = append(children, nested{childPath, v})
+		case []any:
+			if len(v) > 0 {
+				if _, ok := v[0].(map[string]any); ok {
+					children = append(children, nested{childPath, v})
+					continue
+				}
+			}
+			scalars[k] = v
+		case nil:
+			// 缺失的可选段（如 breakdown）
+		default:
+			scalars[k] = v
+		}
+	}
+
+	if len(scalars) > 0 {
+		section := path
+		if section == "" {
+			section = "totals"
+		}
+		if err := emit(section, scalars); err != nil {
+			return err
+		}
+	}
+	for _, c := range children {
+		switch v := c.value.(type) {
+		case map[string]any:
+			if err := writeNDJSONObject(v, c.path, emit); err != nil {
+				return err
+			}
+		case []any:
+			for _, item := range v {
+				if err := emit(c.path, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
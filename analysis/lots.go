@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+// Lot 一个持仓批次，金额为交易币种
+type Lot struct {
+	AccountID     string // 期初批次所属账户，手工维护的批次文件可不填
+	Symbol        string
+	Currency      string
+	Date          string  // 开仓日期 YYYYMMDD
+	TransactionID string  // 开仓交易
+	Quantity      float64 // 剩余数量，恒为正
+	UnitCost      float64 // 每单位成本（多头买入成本 / 空头收到的权利金），含佣金
+	Short         bool
+}
+
+// LotMatch 一笔交易平掉的批次部分
+type LotMatch struct {
+	Lot      Lot     // 被平仓的批次，Quantity 为本次平掉的数量
+	Realized float64 // 这部分的已实现盈亏（交易币种）
+}
+
+// LotFill 一笔交易对批次的影响：先按 FIFO 平掉反向批次，剩余数量开新批次
+type LotFill struct {
+	Closed   []LotMatch
+	Opened   *Lot
+	Realized float64 // Closed 的已实现盈亏之和（交易币种）
+}
+
+// LotEngine 按 FIFO 跟踪每个标的的持仓批次，交易需按时间顺序传入（见 sortTradesByTime）
+type LotEngine struct {
+	open map[string][]Lot
+}
+
+func NewLotEngine() *LotEngine {
+	return &LotEngine{open: make(map[string][]Lot)}
+}
+
+// sortTradesByTime 按成交时间排序（返回副本）
+func sortTradesByTime(trades []flex.Trade) []flex.Trade {
+	sorted := make([]flex.Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].DateTime < sorted[j].DateTime
+	})
+	return sorted
+}
+
+// Apply 处理一笔交易。
+// 期权到期交割（BookTrade）以 0 平掉最早的批次；买入先平空头再开多头，卖出先平多头再开空头
+func (e *LotEngine) Apply(t flex.Trade) LotFill {
+	sym := t.Symbol
+	qty := t.Quantity
+	netCash := t.Proceeds + t.Commission // Commission 为负数
+
+	var fill LotFill
+	// closeLots 从最早的批次开始平仓，直到数量用完或遇到不满足 eligible 的批次
+	closeLots := func(amount float64, eligible func(Lot) bool, realized func(l Lot, match float64) float64) float64 {
+		remaining := amount
+		for remaining > 1e-9 && len(e.open[sym]) > 0 && eligible(e.open[sym][0]) {
+			l := &e.open[sym][0]
+			match := math.Min(l.Quantity, remaining)
+			r := realized(*l, match)
+			closed := *l
+			closed.Quantity = match
+			fill.Closed = append(fill.Closed, LotMatch{Lot: closed, Realized: r})
+			fill.Realized += r
+			l.Quantity -= match
+			remaining -= match
+			if l.Quantity < 1e-9 {
+				e.open[sym] = e.open[sym][1:]
+			}
+		}
+		return remaining
+	}
+	openLot := func(remaining, unitCost float64, short bool) {
+		lot := Lot{
+			Symbol:        sym,
+			Currency:      t.Currency,
+			Date:          normalizeDate(t.TradeDate),
+			TransactionID: t.TransactionID,
+			Quantity:      remaining,
+			UnitCost:      unitCost,
+			Short:         short,
+		}
+		e.open[sym] = append(e.open[sym], lot)
+		fill.Opened = &lot
+	}
+
+	switch {
+	case t.TransactionType == "BookTrade":
+		closeLots(math.Abs(qty), func(Lot) bool { return true }, func(l Lot, match float64) float64 {
+			if l.Short {
+				return match * l.UnitCost // 保住了权利金
+			}
+			return -match * l.UnitCost // 多头到期归零，损失买入成本
+		})
+	case qty > 0:
+		costToClosePerUnit := (-netCash) / qty
+		remaining := closeLots(qty, func(l Lot) bool { return l.Short }, func(l Lot, match float64) float64 {
+			return match*l.UnitCost - match*costToClosePerUnit
+		})
+		if remaining > 1e-9 {
+			openLot(remaining, costToClosePerUnit, false)
+		}
+	case qty < 0:
+		sellQty := -qty
+		proceedsPerUnit := netCash / sellQty
+		remaining := closeLots(sellQty, func(l Lot) bool { return !l.Short }, func(l Lot, match float64) float64 {
+			return match*proceedsPerUnit - match*l.UnitCost
+		})
+		if remaining > 1e-9 {
+			openLot(remaining, proceedsPerUnit, true)
+		}
+	}
+	return fill
+}
+
+// OpenLots 标的当前仍持有的批次（按开仓先后）
+func (e *LotEngine) OpenLots(symbol string) []Lot {
+	return e.open[symbol]
+}
+
+// Symbols 仍有持仓批次的标的，按字母排序
+func (e *LotEngine) Symbols() []string {
+	var symbols []string
+	for sym, lots := range e.open {
+		if len(lots) > 0 {
+			symbols = append(symbols, sym)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
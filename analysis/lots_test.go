@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+func TestLotEngineApplySeeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		opening  *OpeningLots
+		trades   []flex.Trade
+		realized []float64 // 每笔交易的已实现盈亏，跳过的交易不计
+		open     []Lot     // 之后仍持有的批次
+	}{
+		{
+			name:    "sell closes a seeded lot",
+			opening: &OpeningLots{Lots: []Lot{{Symbol: "GOOG", Date: "20240601", Quantity: 5, UnitCost: 140}}},
+			trades: []flex.Trade{
+				{Symbol: "GOOG", TradeDate: "20250110", DateTime: "20250110;100000", Quantity: -5, Proceeds: 850, Commission: -1},
+			},
+			realized: []float64{149},
+		},
+		{
+			name:    "seeded lot is closed before later buys",
+			opening: &OpeningLots{Lots: []Lot{{Symbol: "AAPL", Date: "20240601", Quantity: 5, UnitCost: 100}}},
+			trades: []flex.Trade{
+				{Symbol: "AAPL", TradeDate: "20250110", DateTime: "20250110;100000", Quantity: 5, Proceeds: -600, TransactionID: "b1"},
+				{Symbol: "AAPL", TradeDate: "20250120", DateTime: "20250120;100000", Quantity: -7, Proceeds: 1050},
+			},
+			realized: []float64{0, 5*(150-100) + 2*(150-120)},
+			open:     []Lot{{Symbol: "AAPL", Date: "20250110", TransactionID: "b1", Quantity: 3, UnitCost: 120}},
+		},
+		{
+			name: "trades on or before the snapshot date are skipped",
+			opening: &OpeningLots{AsOf: "20250115", Lots: []Lot{
+				{Symbol: "MSFT", Date: "20250105", Quantity: 10, UnitCost: 400},
+			}},
+			trades: []flex.Trade{
+				{Symbol: "MSFT", TradeDate: "20250105", DateTime: "20250105;100000", Quantity: 10, Proceeds: -4000},
+				{Symbol: "MSFT", TradeDate: "20250120", DateTime: "20250120;100000", Quantity: -4, Proceeds: 1800},
+			},
+			realized: []float64{4 * (450 - 400)},
+			open:     []Lot{{Symbol: "MSFT", Date: "20250105", Quantity: 6, UnitCost: 400}},
+		},
+		{
+			name:    "buy to close a seeded short",
+			opening: &OpeningLots{Lots: []Lot{{Symbol: "SPY P", Date: "20241201", Quantity: 2, UnitCost: 500, Short: true}}},
+			trades: []flex.Trade{
+				{Symbol: "SPY P", TradeDate: "20250110", DateTime: "20250110;100000", Quantity: 2, Proceeds: -300, Commission: -2},
+			},
+			realized: []float64{1000 - 302},
+		},
+		{
+			name:    "seeded long option expires worthless",
+			opening: &OpeningLots{Lots: []Lot{{Symbol: "AAPL C", Date: "20241201", Quantity: 1, UnitCost: 250}}},
+			trades: []flex.Trade{
+				{Symbol: "AAPL C", TradeDate: "20250117", DateTime: "20250117;162000", Quantity: -1, TransactionType: "BookTrade"},
+			},
+			realized: []float64{-250},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, replay := newSeededLotEngine(tt.opening)
+			var realized []float64
+			for _, tr := range sortTradesByTime(tt.trades) {
+				if replay(tr) {
+					realized = append(realized, engine.Apply(tr).Realized)
+				}
+			}
+			if len(realized) != len(tt.realized) {
+				t.Fatalf("applied %d trades, want %d", len(realized), len(tt.realized))
+			}
+			for i := range realized {
+				if !approxEqual(realized[i], tt.realized[i]) {
+					t.Errorf("trade %d realized = %g, want %g", i, realized[i], tt.realized[i])
+				}
+			}
+
+			var open []Lot
+			for _, symbol := range engine.Symbols() {
+				open = append(open, engine.OpenLots(symbol)...)
+			}
+			if len(open) != len(tt.open) {
+				t.Fatalf("open lots = %+v, want %+v", open, tt.open)
+			}
+			for i := range open {
+				got, want := open[i], tt.open[i]
+				if got.Symbol != want.Symbol || got.Date != want.Date || got.TransactionID != want.TransactionID ||
+					!approxEqual(got.Quantity, want.Quantity) || !approxEqual(got.UnitCost, want.UnitCost) || got.Short != want.Short {
+					t.Errorf("open lot %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+// metricFamily Prometheus 文本格式中的一个指标（均为 gauge）
+type metricFamily struct {
+	name, help string
+	samples    []metricSample
+}
+
+type metricSample struct {
+	labels [][2]string
+	value  float64
+}
+
+func (m *metricFamily) add(value float64, labels ...string) {
+	s := metricSample{value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		s.labels = append(s.labels, [2]string{labels[i], labels[i+1]})
+	}
+	m.samples = append(m.samples, s)
+}
+
+// WriteMetrics 以 Prometheus 文本格式（version 0.0.4，OpenMetrics 兼容解析）输出账户指标，按账户分别计算：
+// 账户总值、现金、各持仓的数量/市值/未实现盈亏，以及当年（以账单截止日所在年份为准）的
+// 净股息、预扣税、佣金、已实现盈亏。除持仓市值外金额均为基础货币（currency 标签）
+func WriteMetrics(w io.Writer, statements []flex.FlexStatement, opts Options) error {
+	var (
+		accountValue  = &metricFamily{name: "ibkr_account_value", help: "Account value (positions + cash) in base currency."}
+		cashBase      = &metricFamily{name: "ibkr_cash_balance_base", help: "Total ending cash in base currency."}
+		cash          = &metricFamily{name: "ibkr_cash_balance", help: "Ending cash per currency."}
+		quantity      = &metricFamily{name: "ibkr_position_quantity", help: "Open position quantity."}
+		marketValue   = &metricFamily{name: "ibkr_position_market_value", help: "Open position market value in the position currency."}
+		marketBase    = &metricFamily{name: "ibkr_position_market_value_base", help: "Open position market value in base currency."}
+		unrealized    = &metricFamily{name: "ibkr_position_unrealized_pnl", help: "Open position unrealized P&L in the position currency."}
+		dividends     = &metricFamily{name: "ibkr_dividends_ytd", help: "Year-to-date dividends net of withholding tax, base currency."}
+		withholding   = &metricFamily{name: "ibkr_dividend_withholding_ytd", help: "Year-to-date dividend withholding tax (positive), base currency."}
+		commissions   = &metricFamily{name: "ibkr_commissions_ytd", help: "Year-to-date commissions paid (positive), base currency."}
+		realized      = &metricFamily{name: "ibkr_realized_pnl_ytd", help: "Year-to-date realized P&L, base currency."}
+		statementDate = &metricFamily{name: "ibkr_statement_end_timestamp_seconds", help: "End date of the latest statement as a Unix timestamp."}
+		families      = []*metricFamily{accountValue, cashBase, cash, quantity, marketValue, marketBase, unrealized,
+			dividends, withholding, commissions, realized, statementDate}
+	)
+
+	var accountIDs []string
+	byAccount := make(map[string][]flex.FlexStatement)
+	for _, stmt := range statements {
+		if _, ok := byAccount[stmt.AccountID]; !ok {
+			accountIDs = append(accountIDs, stmt.AccountID)
+		}
+		byAccount[stmt.AccountID] = append(byAccount[stmt.AccountID], stmt)
+	}
+	sort.Strings(accountIDs)
+
+	for _, account := range accountIDs {
+		stmts := byAccount[account]
+		base := baseCurrency(stmts)
+		_, periodTo := reportPeriod(stmts)
+		periodTo = normalizeDate(periodTo)
+
+		// 持仓：最近报告日的 SUMMARY 行，多个 query 中重复的只计一次
+		latest := ""
+		for _, stmt := range stmts {
+			for _, op := range stmt.OpenPositions {
+				latest = max(latest, normalizeDate(op.ReportDate))
+			}
+		}
+		var positions []flex.OpenPosition
+		seen := make(map[string]bool)
+		for _, stmt := range stmts {
+			for _, op := range stmt.OpenPositions {
+				if op.LevelOfDetail == "LOT" || normalizeDate(op.ReportDate) != latest || seen[op.Symbol] {
+					continue
+				}
+				seen[op.Symbol] = true
+				positions = append(positions, op)
+			}
+		}
+		sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+
+		var positionsBase float64
+		for _, op := range positions {
+			labels := []string{"account", account, "symbol", op.Symbol, "currency", op.Currency, "asset_category", op.AssetCategory}
+			valueBase := toBase(op.PositionValue, op.FxRateToBase)
+			quantity.add(op.Position, labels...)
+			marketValue.add(op.PositionValue, labels...)
+			marketBase.add(valueBase, "account", account, "symbol", op.Symbol, "currency", base, "asset_category", op.AssetCategory)
+			unrealized.add(op.FifoPnlUnrealized, labels...)
+			positionsBase += valueBase
+		}
+
+		// 现金：最近一期 CashReport
+		var latestStmt *flex.FlexStatement
+		for i := range stmts {
+			if len(stmts[i].CashReport) > 0 && (latestStmt == nil || stmts[i].ToDate > latestStmt.ToDate) {
+				latestStmt = &stmts[i]
+			}
+		}
+		var totalCash float64
+		if latestStmt != nil {
+			for _, cr := range latestStmt.CashReport {
+				if cr.Currency == "BASE_SUMMARY" {
+					totalCash = cr.EndingCash
+					continue
+				}
+				cash.add(cr.EndingCash, "account", account, "currency", cr.Currency)
+			}
+		}
+		cashBase.add(totalCash, "account", account, "currency", base)
+		accountValue.add(positionsBase+totalCash, "account", account, "currency", base)
+
+		// 当年累计
+		ytdFrom := ""
+		if len(periodTo) >= 4 {
+			ytdFrom = periodTo[:4] + "0101"
+		}
+		divs := AnalyzeDividends(stmts, ytdFrom, periodTo)
+		dividends.add(divs.TotalNet, "account", account, "currency", base)
+		withholding.add(-divs.TotalWithhold, "account", account, "currency", base)
+		commissions.add(-AnalyzeCommissions(stmts, ytdFrom, periodTo).TotalComm, "account", account, "currency", base)
+		// 已实现盈亏用全部历史交易重放建立批次，只计当年的平仓
+		ytdRealized, _ := realizedPnL(stmts, ytdFrom, periodTo, opts)
+		var realizedTotal float64
+		for _, pnl := range ytdRealized {
+			realizedTotal += pnl
+		}
+		realized.add(realizedTotal, "account", account, "currency", base)
+
+		if t, err := time.Parse("20060102", periodTo); err == nil {
+			statementDate.add(float64(t.Unix()), "account", account)
+		}
+	}
+
+	var b strings.Builder
+	for _, m := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name)
+		for _, s := range m.samples {
+			b.WriteString(m.name)
+			if len(s.labels) > 0 {
+				b.WriteByte('{')
+				for i, l := range s.labels {
+					if i > 0 {
+						b.WriteByte(',')
+					}
+					fmt.Fprintf(&b, "%s=\"%s\"", l[0], escapeLabel(l[1]))
+				}
+				b.WriteByte('}')
+			}
+			b.WriteString(" " + strconv.FormatFloat(cleanFloat(s.value), 'f', -1, 64) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel 标签值中的反斜杠、双引号和换行需要转义
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
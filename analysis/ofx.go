@@ -0,0 +1,547 @@
+package analysis
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/ofx"
+)
+
+const ofxBrokerID = "interactivebrokers.com"
+
+// ofxSecurity SECLIST 中的一个证券
+type ofxSecurity struct {
+	symbol, isin, name string
+	assetCategory      string
+	currency           string
+	fxRate             float64
+	price              float64
+	priceDate          string
+	putCall            string
+	strike, multiplier float64
+	expiry             string // YYYYMMDD
+}
+
+// secID ISIN 优先，没有时用代码
+func (s *ofxSecurity) secID() *ofx.Node {
+	if s.isin != "" {
+		return ofx.Agg("SECID", ofx.Elem("UNIQUEID", s.isin), ofx.Elem("UNIQUEIDTYPE", "ISIN"))
+	}
+	return ofx.Agg("SECID", ofx.Elem("UNIQUEID", truncateRunes(s.symbol, 32)), ofx.Elem("UNIQUEIDTYPE", "TICKER"))
+}
+
+// ofxSecurities 收集交易、持仓、股息涉及的证券
+type ofxSecurities struct {
+	bySymbol map[string]*ofxSecurity
+	isins    map[string]string
+}
+
+func (ss *ofxSecurities) get(symbol string) *ofxSecurity {
+	s, ok := ss.bySymbol[symbol]
+	if !ok {
+		s = &ofxSecurity{symbol: symbol, name: symbol, isin: ss.isins[symbol]}
+		ss.bySymbol[symbol] = s
+	}
+	return s
+}
+
+func (ss *ofxSecurities) addTrade(t flex.Trade) *ofxSecurity {
+	s := ss.get(t.Symbol)
+	if t.Description != "" {
+		s.name = t.Description
+	}
+	s.assetCategory, s.currency, s.fxRate = t.AssetCategory, t.Currency, t.FxRateToBase
+	if t.AssetCategory == "OPT" {
+		s.putCall, s.strike, s.multiplier, s.expiry = t.PutCall, t.Strike, t.Multiplier, normalizeDate(t.Expiry)
+	}
+	return s
+}
+
+func (ss *ofxSecurities) addPosition(op flex.OpenPosition) *ofxSecurity {
+	s := ss.get(op.Symbol)
+	if op.Description != "" {
+		s.name = op.Description
+	}
+	s.assetCategory, s.currency, s.fxRate = op.AssetCategory, op.Currency, op.FxRateToBase
+	s.price, s.priceDate = op.MarkPrice, normalizeDate(op.ReportDate)
+	if op.AssetCategory == "OPT" && s.multiplier == 0 {
+		s.multiplier = op.Multiplier
+	}
+	return s
+}
+
+// WriteOFX 生成 OFX 2.2 投资对账单（INVSTMTRS），每个账户一个：
+// INVTRANLIST 为交易和现金流水、INVPOSLIST 为 OpenPositions、INVBAL 为 CashReport 期末现金，
+// SECLIST 列出涉及的证券。FITID 使用 TransactionID，财务软件重复导入时据此去重。
+// 写出前按规范结构检查，不通过时返回错误
+func WriteOFX(w io.Writer, statements []flex.FlexStatement, from, to string) error {
+	doc := BuildOFX(statements, from, to)
+	var b strings.Builder
+	if err := ofx.Write(&b, doc); err != nil {
+		return err
+	}
+	if err := ofx.Validate([]byte(b.String())); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// BuildOFX 构建 OFX 文档树，见 WriteOFX
+func BuildOFX(statements []flex.FlexStatement, from, to string) *ofx.Node {
+	now := time.Now()
+	securities := &ofxSecurities{bySymbol: make(map[string]*ofxSecurity), isins: isinBySymbol(statements)}
+
+	// 按账户分组，保持首次出现的顺序
+	var accountIDs []string
+	byAccount := make(map[string][]flex.FlexStatement)
+	for _, stmt := range statements {
+		if _, ok := byAccount[stmt.AccountID]; !ok {
+			accountIDs = append(accountIDs, stmt.AccountID)
+		}
+		byAccount[stmt.AccountID] = append(byAccount[stmt.AccountID], stmt)
+	}
+
+	msgs := ofx.Agg("INVSTMTMSGSRSV1")
+	base := baseCurrency(statements)
+	for _, id := range accountIDs {
+		stmts := byAccount[id]
+		msgs.Add(ofx.Agg("INVSTMTTRNRS",
+			ofx.Elem("TRNUID", truncateRunes(id, 36)),
+			ofxStatus(),
+			ofxStatement(stmts, from, to, securities),
+		))
+	}
+
+	symbols := make([]string, 0, len(securities.bySymbol))
+	for sym := range securities.bySymbol {
+		symbols = append(symbols, sym)
+	}
+	sort.Strings(symbols)
+	seclist := ofx.Agg("SECLIST")
+	for _, sym := range symbols {
+		seclist.Add(ofxSecInfo(securities.bySymbol[sym], base))
+	}
+
+	return ofx.Agg("OFX",
+		ofx.Agg("SIGNONMSGSRSV1", ofx.Agg("SONRS",
+			ofxStatus(),
+			ofx.Elem("DTSERVER", now.Format("20060102150405")),
+			ofx.Elem("LANGUAGE", "ENG"),
+			ofx.Agg("FI", ofx.Elem("ORG", "Interactive Brokers")),
+		)),
+		msgs,
+		ofx.Agg("SECLISTMSGSRSV1", seclist),
+	)
+}
+
+func ofxStatus() *ofx.Node {
+	return ofx.Agg("STATUS", ofx.Elem("CODE", "0"), ofx.Elem("SEVERITY", "INFO"))
+}
+
+// ofxStatement 一个账户的 INVSTMTRS
+func ofxStatement(stmts []flex.FlexStatement, from, to string, securities *ofxSecurities) *ofx.Node {
+	base := baseCurrency(stmts)
+	periodFrom, periodTo := reportPeriod(stmts)
+	if from != "" {
+		periodFrom = normalizeDate(from)
+	}
+	if to != "" {
+		periodTo = normalizeDate(to)
+	}
+
+	type dated struct {
+		date string
+		node *ofx.Node
+	}
+	var txns []dated
+	currency := func(curr string, rate float64) *ofx.Node {
+		return ofxCurrency(curr, rate, base)
+	}
+
+	for _, t := range uniqueTrades(stmts) {
+		if isFXTrade(t) || !inDateRange(normalizeDate(t.TradeDate), from, to) {
+			continue
+		}
+		sec := securities.addTrade(t)
+		txns = append(txns, dated{t.DateTime, ofxTrade(t, sec, currency(t.Currency, t.FxRateToBase))})
+	}
+
+	for _, ct := range uniqueCashTransactions(stmts) {
+		date := cashDate(ct)
+		if ct.Amount == 0 || !inDateRange(date, from, to) {
+			continue
+		}
+		fitid := ct.TransactionID
+		if fitid == "" {
+			fitid = cashEntryID(ct)
+		}
+		curr := currency(ct.Currency, ct.FxRateToBase)
+		isDividend := ct.Type == "Dividends" || ct.Type == "Payment In Lieu Of Dividends"
+		if isDividend && ct.Symbol != "" && ct.Amount > 0 {
+			sec := securities.get(ct.Symbol)
+			if sec.currency == "" {
+				sec.currency, sec.fxRate = ct.Currency, ct.FxRateToBase
+			}
+			txns = append(txns, dated{date, ofx.Agg("INCOME",
+				ofxInvTran(fitid, date, ct.Description),
+				sec.secID(),
+				ofx.Elem("INCOMETYPE", "DIV"),
+				ofx.Elem("TOTAL", formatNumber(ct.Amount)),
+				ofx.Elem("SUBACCTSEC", "CASH"),
+				ofx.Elem("SUBACCTFUND", "CASH"),
+				curr,
+			)})
+			continue
+		}
+		txns = append(txns, dated{date, ofxBankTran(ofxCashTrnType(ct), fitid, date, ct.Amount, ct.Type, ct.Description, curr)})
+	}
+
+	for _, stmt := range stmts {
+		for _, tr := range stmt.Transfers {
+			date := datePart(tr.DateTime)
+			if tr.Amount == 0 || !isCashTransfer(tr) || !inDateRange(date, from, to) {
+				continue
+			}
+			fitid := tr.TransactionID
+			if fitid == "" {
+				fitid = fmt.Sprintf("transfer:%s:%s:%g", tr.DateTime, tr.Currency, tr.Amount)
+			}
+			txns = append(txns, dated{date, ofxBankTran("XFER", fitid, date, tr.Amount, "Transfer", tr.Description, currency(tr.Currency, tr.FxRateToBase))})
+		}
+	}
+	sort.SliceStable(txns, func(i, j int) bool { return datePart(txns[i].date) < datePart(txns[j].date) })
+
+	tranList := ofx.Agg("INVTRANLIST", ofx.Elem("DTSTART", periodFrom), ofx.Elem("DTEND", periodTo))
+	for _, t := range txns {
+		tranList.Add(t.node)
+	}
+
+	stmt := ofx.Agg("INVSTMTRS",
+		ofx.Elem("DTASOF", periodTo),
+		ofx.Elem("CURDEF", base),
+		ofx.Agg("INVACCTFROM", ofx.Elem("BROKERID", ofxBrokerID), ofx.Elem("ACCTID", truncateRunes(stmts[0].AccountID, 22))),
+		tranList,
+	)
+	if posList := ofxPositions(stmts, securities, currency); posList != nil {
+		stmt.Add(posList)
+	}
+	if bal := ofxBalance(stmts, base); bal != nil {
+		stmt.Add(bal)
+	}
+	return stmt
+}
+
+// ofxTrade 买卖股票/期权/其他证券；期权到期、行权、被行权为 CLOSUREOPT
+func ofxTrade(t flex.Trade, sec *ofxSecurity, currency *ofx.Node) *ofx.Node {
+	tran := ofxInvTran(t.TransactionID, t.DateTime, t.Description)
+	if t.TransactionID == "" {
+		tran = ofxInvTran(tradeEntryID(t), t.DateTime, t.Description)
+	}
+	isOpt := t.AssetCategory == "OPT"
+	shPerCtrct := formatNumber(max(t.Multiplier, 1))
+
+	if isOpt && t.TransactionType == "BookTrade" {
+		action := "EXPIRE"
+		for code := range strings.SplitSeq(t.Notes, ";") {
+			switch code {
+			case "A":
+				action = "ASSIGN"
+			case "Ex":
+				action = "EXERCISE"
+			}
+		}
+		return ofx.Agg("CLOSUREOPT",
+			tran,
+			sec.secID(),
+			ofx.Elem("OPTACTION", action),
+			ofx.Elem("UNITS", formatNumber(t.Quantity)),
+			ofx.Elem("SHPERCTRCT", shPerCtrct),
+			ofx.Elem("SUBACCTSEC", "CASH"),
+		)
+	}
+
+	opening := strings.Contains(t.OpenCloseInd, "O")
+	closing := strings.Contains(t.OpenCloseInd, "C")
+	amount := func(name string, v float64) *ofx.Node {
+		if v == 0 {
+			return nil
+		}
+		return ofx.Elem(name, formatNumber(-v)) // 佣金、税在 Flex 中为负数，OFX 中为正数
+	}
+
+	if t.Quantity > 0 {
+		buy := ofx.Agg("INVBUY",
+			tran,
+			sec.secID(),
+			ofx.Elem("UNITS", formatNumber(t.Quantity)),
+			ofx.Elem("UNITPRICE", formatNumber(t.TradePrice)),
+			amount("COMMISSION", t.Commission),
+			amount("TAXES", t.Taxes),
+			ofx.Elem("TOTAL", formatNumber(t.Proceeds+t.Commission+t.Taxes)),
+			currency,
+			ofx.Elem("SUBACCTSEC", "CASH"),
+			ofx.Elem("SUBACCTFUND", "CASH"),
+		)
+		switch t.AssetCategory {
+		case "STK":
+			buyType := "BUY"
+			if closing {
+				buyType = "BUYTOCOVER"
+			}
+			return ofx.Agg("BUYSTOCK", buy, ofx.Elem("BUYTYPE", buyType))
+		case "OPT":
+			optType := "BUYTOOPEN"
+			if closing {
+				optType = "BUYTOCLOSE"
+			}
+			return ofx.Agg("BUYOPT", buy, ofx.Elem("OPTBUYTYPE", optType), ofx.Elem("SHPERCTRCT", shPerCtrct))
+		}
+		return ofx.Agg("BUYOTHER", buy)
+	}
+
+	sell := ofx.Agg("INVSELL",
+		tran,
+		sec.secID(),
+		ofx.Elem("UNITS", formatNumber(t.Quantity)),
+		ofx.Elem("UNITPRICE", formatNumber(t.TradePrice)),
+		amount("COMMISSION", t.Commission),
+		amount("TAXES", t.Taxes),
+		ofx.Elem("TOTAL", formatNumber(t.Proceeds+t.Commission+t.Taxes)),
+		currency,
+		ofx.Elem("SUBACCTSEC", "CASH"),
+		ofx.Elem("SUBACCTFUND", "CASH"),
+	)
+	switch t.AssetCategory {
+	case "STK":
+		sellType := "SELL"
+		if opening {
+			sellType = "SELLSHORT"
+		}
+		return ofx.Agg("SELLSTOCK", sell, ofx.Elem("SELLTYPE", sellType))
+	case "OPT":
+		optType := "SELLTOCLOSE"
+		if opening {
+			optType = "SELLTOOPEN"
+		}
+		return ofx.Agg("SELLOPT", sell, ofx.Elem("OPTSELLTYPE", optType), ofx.Elem("SHPERCTRCT", shPerCtrct))
+	}
+	return ofx.Agg("SELLOTHER", sell)
+}
+
+func ofxInvTran(fitid, dateTime, memo string) *ofx.Node {
+	return ofx.Agg("INVTRAN",
+		ofx.Elem("FITID", fitid),
+		ofx.Elem("DTTRADE", ofxDateTime(dateTime)),
+		ofx.OptElem("MEMO", truncateRunes(memo, 255)),
+	)
+}
+
+// ofxBankTran 不涉及证券的现金流水（利息、费用、预扣税、出入金）
+func ofxBankTran(trnType, fitid, date string, amount float64, name, memo string, currency *ofx.Node) *ofx.Node {
+	return ofx.Agg("INVBANKTRAN",
+		ofx.Agg("STMTTRN",
+			ofx.Elem("TRNTYPE", trnType),
+			ofx.Elem("DTPOSTED", ofxDateTime(date)),
+			ofx.Elem("TRNAMT", formatNumber(amount)),
+			ofx.Elem("FITID", fitid),
+			ofx.OptElem("NAME", truncateRunes(name, 32)),
+			ofx.OptElem("MEMO", truncateRunes(memo, 255)),
+			currency,
+		),
+		ofx.Elem("SUBACCTFUND", "CASH"),
+	)
+}
+
+func ofxCashTrnType(ct flex.CashTransaction) string {
+	switch ct.Type {
+	case "Broker Interest Received", "Broker Interest Paid", "Bond Interest Received", "Bond Interest Paid":
+		return "INT"
+	case "Dividends", "Payment In Lieu Of Dividends":
+		return "DIV"
+	case "Other Fees", "Broker Fees", "Commission Adjustments":
+		if ct.Amount < 0 {
+			return "FEE"
+		}
+	case "Deposits/Withdrawals", "Deposits & Withdrawals":
+		return "XFER"
+	}
+	if ct.Amount < 0 {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+// ofxCurrency 非基础货币的金额附带币种和折算汇率
+func ofxCurrency(curr string, rate float64, base string) *ofx.Node {
+	if curr == "" || curr == base || rate <= 0 {
+		return nil
+	}
+	return ofx.Agg("CURRENCY", ofx.Elem("CURRATE", formatNumber(rate)), ofx.Elem("CURSYM", curr))
+}
+
+// ofxPositions 最近一期的 OpenPositions（不含 LOT 明细行）
+func ofxPositions(stmts []flex.FlexStatement, securities *ofxSecurities, currency func(string, float64) *ofx.Node) *ofx.Node {
+	latest := ""
+	for _, stmt := range stmts {
+		for _, op := range stmt.OpenPositions {
+			latest = max(latest, normalizeDate(op.ReportDate))
+		}
+	}
+	if latest == "" {
+		return nil
+	}
+
+	list := ofx.Agg("INVPOSLIST")
+	seen := make(map[string]bool)
+	for _, stmt := range stmts {
+		for _, op := range stmt.OpenPositions {
+			if op.LevelOfDetail == "LOT" || normalizeDate(op.ReportDate) != latest || seen[op.Symbol] {
+				continue
+			}
+			seen[op.Symbol] = true
+			sec := securities.addPosition(op)
+			posType := "LONG"
+			if op.Position < 0 {
+				posType = "SHORT"
+			}
+			pos := ofx.Agg("INVPOS",
+				sec.secID(),
+				ofx.Elem("HELDINACCT", "CASH"),
+				ofx.Elem("POSTYPE", posType),
+				ofx.Elem("UNITS", formatNumber(op.Position)),
+				ofx.Elem("UNITPRICE", formatNumber(op.MarkPrice)),
+				ofx.Elem("MKTVAL", formatNumber(op.PositionValue)),
+				ofx.Elem("DTPRICEASOF", latest),
+				currency(op.Currency, op.FxRateToBase),
+			)
+			switch op.AssetCategory {
+			case "STK":
+				list.Add(ofx.Agg("POSSTOCK", pos))
+			case "OPT":
+				list.Add(ofx.Agg("POSOPT", pos))
+			default:
+				list.Add(ofx.Agg("POSOTHER", pos))
+			}
+		}
+	}
+	return list
+}
+
+// ofxBalance 最近一期 CashReport：AVAILCASH 为基础货币期末现金，BALLIST 列出各币种期末现金
+func ofxBalance(stmts []flex.FlexStatement, base string) *ofx.Node {
+	var latest *flex.FlexStatement
+	for i := range stmts {
+		if len(stmts[i].CashReport) > 0 && (latest == nil || stmts[i].ToDate > latest.ToDate) {
+			latest = &stmts[i]
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+
+	var availCash float64
+	var haveSummary bool
+	balList := ofx.Agg("BALLIST")
+	for _, cr := range latest.CashReport {
+		if cr.Currency == "BASE_SUMMARY" || cr.LevelOfDetail == "BaseCurrency" {
+			availCash, haveSummary = cr.EndingCash, true
+			continue
+		}
+		if cr.Currency == base && !haveSummary {
+			availCash = cr.EndingCash
+		}
+		balList.Add(ofx.Agg("BAL",
+			ofx.Elem("NAME", "Cash "+cr.Currency),
+			ofx.Elem("DESC", "Ending cash ("+cr.Currency+")"),
+			ofx.Elem("BALTYPE", "DOLLAR"),
+			ofx.Elem("VALUE", formatNumber(cr.EndingCash)),
+			ofx.OptElem("DTASOF", normalizeDate(cr.ToDate)),
+		))
+	}
+
+	bal := ofx.Agg("INVBAL",
+		ofx.Elem("AVAILCASH", formatNumber(availCash)),
+		ofx.Elem("MARGINBALANCE", "0"),
+		ofx.Elem("SHORTBALANCE", "0"),
+	)
+	if len(balList.Children) > 0 {
+		bal.Add(balList)
+	}
+	return bal
+}
+
+// ofxSecInfo SECLIST 条目；期权缺少到期日、行权价等信息时按 OTHERINFO 输出
+func ofxSecInfo(s *ofxSecurity, base string) *ofx.Node {
+	var price, priceDate *ofx.Node
+	if s.price != 0 {
+		price, priceDate = ofx.Elem("UNITPRICE", formatNumber(s.price)), ofx.Elem("DTASOF", s.priceDate)
+	}
+	info := ofx.Agg("SECINFO",
+		s.secID(),
+		ofx.Elem("SECNAME", truncateRunes(s.name, 120)),
+		ofx.Elem("TICKER", truncateRunes(s.symbol, 32)),
+		price,
+		priceDate,
+		ofxCurrency(s.currency, s.fxRate, base),
+	)
+
+	switch s.assetCategory {
+	case "STK", "":
+		return ofx.Agg("STOCKINFO", info)
+	case "OPT":
+		putCall, strike, expiry := s.putCall, s.strike, s.expiry
+		if putCall == "" || strike == 0 || expiry == "" {
+			putCall, strike, expiry = parseOCCSymbol(s.symbol)
+		}
+		if putCall != "" {
+			optType := "CALL"
+			if putCall == "P" {
+				optType = "PUT"
+			}
+			return ofx.Agg("OPTINFO",
+				info,
+				ofx.Elem("OPTTYPE", optType),
+				ofx.Elem("STRIKEPRICE", formatNumber(strike)),
+				ofx.Elem("DTEXPIRE", expiry),
+				ofx.Elem("SHPERCTRCT", formatNumber(max(s.multiplier, 1))),
+			)
+		}
+	}
+	return ofx.Agg("OTHERINFO", info, ofx.OptElem("TYPEDESC", s.assetCategory))
+}
+
+// parseOCCSymbol 解析 OCC 期权代码（如 "TSLA  250117P00200000"），返回 P/C、行权价、到期日 YYYYMMDD
+func parseOCCSymbol(symbol string) (string, float64, string) {
+	s := strings.ReplaceAll(symbol, " ", "")
+	if len(s) < 16 {
+		return "", 0, ""
+	}
+	tail := s[len(s)-15:]
+	putCall := tail[6:7]
+	strike, err := strconv.Atoi(tail[7:])
+	if (putCall != "P" && putCall != "C") || err != nil {
+		return "", 0, ""
+	}
+	if _, err := time.Parse("060102", tail[:6]); err != nil {
+		return "", 0, ""
+	}
+	return putCall, float64(strike) / 1000, "20" + tail[:6]
+}
+
+// ofxDateTime "20250115;093000" → "20250115093000"，只有日期时为 "20250115"
+func ofxDateTime(dateTime string) string {
+	return strings.NewReplacer("-", "", "T", "", ":", "").Replace(isoDateTime(dateTime))
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// OpeningLots 数据中最早的交易之前已持有的批次，作为 FIFO 的起点
+type OpeningLots struct {
+	// AsOf 批次对应的日期（YYYYMMDD）：这天及之前的交易已反映在批次中，重放时跳过。
+	// 手工维护的批次文件为空，所有交易都在批次之后重放
+	AsOf string
+	Lots []Lot
+}
+
+// newSeededLotEngine 创建带期初批次的引擎，openingLots 为 nil 时从零开始；
+// replay 报告一笔交易是否需要送入引擎（未包含在期初批次中）
+func newSeededLotEngine(openingLots *OpeningLots) (engine *LotEngine, replay func(flex.Trade) bool) {
+	engine = NewLotEngine()
+	if openingLots == nil {
+		return engine, func(flex.Trade) bool { return true }
+	}
+	lots := make([]Lot, len(openingLots.Lots))
+	copy(lots, openingLots.Lots)
+	sort.SliceStable(lots, func(i, j int) bool { return lots[i].Date < lots[j].Date })
+	for _, l := range lots {
+		engine.open[l.Symbol] = append(engine.open[l.Symbol], l)
+	}
+	asOf := openingLots.AsOf
+	return engine, func(t flex.Trade) bool {
+		return asOf == "" || normalizeDate(t.TradeDate) > asOf
+	}
+}
+
+// OpeningLotsFromStatements 以较早快照的 OpenPositions 作为期初批次。
+// 有 LOT 行（Flex Query 中 Open Positions 选择按批次展开）时每行一个批次，保留原开仓日期；
+// 否则每个标的按 SUMMARY 行的 costBasisMoney 生成一个批次，日期为报告日
+func OpeningLotsFromStatements(statements []flex.FlexStatement) *OpeningLots {
+	o := &OpeningLots{}
+	hasLots := false
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			o.AsOf = max(o.AsOf, normalizeDate(op.ReportDate))
+			hasLots = hasLots || op.LevelOfDetail == "LOT"
+		}
+	}
+	seen := make(map[string]bool)
+	for _, stmt := range statements {
+		for _, op := range stmt.OpenPositions {
+			reportDate := normalizeDate(op.ReportDate)
+			if (op.LevelOfDetail == "LOT") != hasLots || reportDate != o.AsOf || op.Position == 0 || op.AssetCategory == "CASH" {
+				continue
+			}
+			// 多个 query 中重复的行只取一次
+			key := strings.Join([]string{stmt.AccountID, op.Symbol, op.OpenDateTime, strconv.FormatFloat(op.Position, 'g', -1, 64)}, "|")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			date := datePart(op.OpenDateTime)
+			if date == "" {
+				date = reportDate
+			}
+			o.Lots = append(o.Lots, Lot{
+				AccountID: stmt.AccountID,
+				Symbol:    op.Symbol,
+				Currency:  op.Currency,
+				Date:      date,
+				Quantity:  math.Abs(op.Position),
+				UnitCost:  math.Abs(op.CostBasisMoney / op.Position),
+				Short:     op.Position < 0,
+			})
+		}
+	}
+	return o
+}
+
+// ReadLotsCSV 读取手工维护的批次文件，表头为 symbol,date,quantity,cost，可选 currency、account。
+// quantity 为负表示空头；cost 为该批次的总成本（含佣金，空头为收到的金额），交易币种
+func ReadLotsCSV(r io.Reader) (*OpeningLots, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if err != nil {
+		return nil, i18n.Errorf("读取批次文件失败: %w", err)
+	}
+	col := csvColumns(header)
+	for _, name := range []string{"symbol", "date", "quantity", "cost"} {
+		if _, ok := col[name]; !ok {
+			return nil, i18n.Errorf("批次文件缺少列: %s", name)
+		}
+	}
+	field := func(rec []string, name string) string { return csvField(col, rec, name) }
+
+	o := &OpeningLots{}
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, i18n.Errorf("读取批次文件失败: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		date := normalizeDate(field(rec, "date"))
+		if _, err := time.Parse("20060102", date); err != nil {
+			return nil, i18n.Errorf("批次文件第 %d 行: 日期无效: %s", line, field(rec, "date"))
+		}
+		qty, err := strconv.ParseFloat(field(rec, "quantity"), 64)
+		if err != nil || qty == 0 {
+			return nil, i18n.Errorf("批次文件第 %d 行: 数量无效: %s", line, field(rec, "quantity"))
+		}
+		cost, err := strconv.ParseFloat(field(rec, "cost"), 64)
+		if err != nil {
+			return nil, i18n.Errorf("批次文件第 %d 行: 成本无效: %s", line, field(rec, "cost"))
+		}
+		symbol := field(rec, "symbol")
+		if symbol == "" {
+			return nil, i18n.Errorf("批次文件第 %d 行: 缺少 symbol", line)
+		}
+		o.Lots = append(o.Lots, Lot{
+			AccountID: field(rec, "account"),
+			Symbol:    symbol,
+			Currency:  field(rec, "currency"),
+			Date:      date,
+			Quantity:  math.Abs(qty),
+			UnitCost:  math.Abs(cost / qty),
+			Short:     qty < 0,
+		})
+	}
+	return o, nil
+}
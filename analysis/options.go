@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// 已实现盈亏的来源
+const (
+	PnLSourceComputed = "computed" // 按交易记录重算 FIFO（默认）
+	PnLSourceIBKR     = "ibkr"     // 交易记录中 IBKR 给出的 fifoPnlRealized
+)
+
+// Options 影响分析结果的配置，由调用方从配置文件和命令行参数构建；零值为默认行为
+type Options struct {
+	PnLSource   string           // 已实现盈亏来源，见 ReconcilePnL；为空时为 computed
+	OpeningLots *OpeningLots     // FIFO 计算（已实现盈亏、持仓成本、持仓重建、记账导出）的期初批次，nil 为从零开始
+	Allocation  AllocationConfig // 资产配置的目标权重和调仓约束
+	Securities  *SecurityMaster  // 资产配置和风险检查按行业、国家/地区分组时使用，nil 为不分组
+	Benchmarks  []*PriceSeries   // 汇总报告对比的基准
+}
+
+// Validate 检查盈亏来源和目标权重：每个标的最多一条，权重不为负，合计不超过 100（剩余为现金）
+func (o Options) Validate() error {
+	switch o.PnLSource {
+	case "", PnLSourceComputed, PnLSourceIBKR:
+	default:
+		return i18n.Errorf("不支持的盈亏来源: %s (可用: computed, ibkr)", o.PnLSource)
+	}
+
+	seen := make(map[string]bool)
+	var total float64
+	for _, t := range o.Allocation.Targets {
+		symbol := strings.ToUpper(strings.TrimSpace(t.Symbol))
+		switch {
+		case symbol == "":
+			return i18n.Errorf("目标权重缺少 symbol")
+		case seen[symbol]:
+			return i18n.Errorf("目标权重重复: %s", t.Symbol)
+		case t.Weight < 0 || t.Weight > 100:
+			return i18n.Errorf("目标权重无效: %s=%g", t.Symbol, t.Weight)
+		}
+		seen[symbol] = true
+		total += t.Weight
+	}
+	if total > 100+1e-9 {
+		return i18n.Errorf("目标权重合计 %.2f%% 超过 100%%", total)
+	}
+	if o.Allocation.MinTrade < 0 {
+		return i18n.Errorf("min_trade 不能为负: %g", o.Allocation.MinTrade)
+	}
+	return nil
+}
+
+// pnlSource 实际使用的已实现盈亏来源
+func (o Options) pnlSource() string {
+	if o.PnLSource == "" {
+		return PnLSourceComputed
+	}
+	return o.PnLSource
+}
@@ -0,0 +1,264 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// Order 一个订单的所有成交合并后的结果
+type Order struct {
+	OrderID       string  `json:"order_id"`
+	AccountID     string  `json:"account_id"`
+	Symbol        string  `json:"symbol"`
+	AssetCategory string  `json:"asset_category"`
+	Currency      string  `json:"currency"`
+	BuySell       string  `json:"buy_sell"`
+	TradeDate     string  `json:"trade_date"`
+	Quantity      float64 `json:"quantity"`
+	VWAP          float64 `json:"vwap"` // 成交量加权均价
+	Proceeds      float64 `json:"proceeds"`
+	Commission    float64 `json:"commission"`
+	NetCash       float64 `json:"net_cash"`
+	FirstFill     string  `json:"first_fill"`
+	LastFill      string  `json:"last_fill"`
+	Fills         int     `json:"fills"`
+}
+
+type OrderReport struct {
+	Orders           []Order `json:"orders"`
+	TotalOrders      int     `json:"total_orders"`
+	TotalFills       int     `json:"total_fills"`
+	TotalComm        float64 `json:"total_commission"`
+	AvgCommPerOrder  float64 `json:"avg_commission_per_order"`
+	AvgFillsPerOrder float64 `json:"avg_fills_per_order"`
+}
+
+// orderKey 同一订单的成交归为一组；没有 OrderID 的记录（如到期、行权）单独成组
+func orderKey(accountID string, t flex.Trade) string {
+	if t.OrderID == "" {
+		return "txn:" + accountID + ":" + t.TransactionID + ":" + t.DateTime + ":" + t.Symbol
+	}
+	return accountID + ":" + t.OrderID + ":" + t.Symbol + ":" + t.BuySell
+}
+
+// mergeFills 将同一订单的多笔成交合并为一条交易记录，成交价取 VWAP，汇率按成交金额加权。
+// TransactionID 为各笔的 TransactionID 以 ; 连接，不会与逐笔成交的记录混淆；
+// 同时有开仓和平仓的成交时 OpenCloseInd 为各标记以 ; 连接（如 C;O）
+func mergeFills(fills []flex.Trade) flex.Trade {
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].DateTime < fills[j].DateTime
+	})
+	merged := fills[0]
+	if len(fills) == 1 {
+		return merged
+	}
+
+	var qty, value, weight, weightedRate float64
+	var ids, indicators []string
+	merged.Quantity, merged.Proceeds, merged.Cost, merged.RealizedPnL = 0, 0, 0, 0
+	merged.Commission, merged.Taxes, merged.NetCash = 0, 0, 0
+	for _, f := range fills {
+		qty += f.Quantity
+		value += f.Quantity * f.TradePrice
+		merged.Quantity += f.Quantity
+		merged.Proceeds += f.Proceeds
+		merged.Cost += f.Cost
+		merged.RealizedPnL += f.RealizedPnL
+		merged.Commission += f.Commission
+		merged.Taxes += f.Taxes
+		merged.NetCash += f.NetCash
+		if f.Exchange != merged.Exchange {
+			merged.Exchange = "MULTI"
+		}
+		if f.FxRateToBase > 0 {
+			weight += math.Abs(f.Proceeds)
+			weightedRate += math.Abs(f.Proceeds) * f.FxRateToBase
+		}
+		if f.TransactionID != "" {
+			ids = append(ids, f.TransactionID)
+		}
+		for _, ind := range strings.Split(f.OpenCloseInd, ";") {
+			if ind != "" && !slices.Contains(indicators, ind) {
+				indicators = append(indicators, ind)
+			}
+		}
+	}
+	if qty != 0 {
+		merged.TradePrice = value / qty
+	}
+	if weight > 0 {
+		merged.FxRateToBase = weightedRate / weight
+	}
+	merged.TransactionID = strings.Join(ids, ";")
+	sort.Strings(indicators)
+	merged.OpenCloseInd = strings.Join(indicators, ";")
+	return merged
+}
+
+// MergeOrderFills 返回按订单合并部分成交后的 statements，
+// 之后的分析（交易数、佣金/笔等）即以订单为单位
+func MergeOrderFills(statements []flex.FlexStatement) []flex.FlexStatement {
+	result := make([]flex.FlexStatement, len(statements))
+	for i, stmt := range statements {
+		groups := make(map[string][]flex.Trade)
+		var keys []string
+		for _, t := range stmt.Trades {
+			key := orderKey(stmt.AccountID, t)
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], t)
+		}
+
+		merged := make([]flex.Trade, 0, len(keys))
+		for _, key := range keys {
+			merged = append(merged, mergeFills(groups[key]))
+		}
+
+		result[i] = stmt
+		result[i].Trades = merged
+	}
+	return result
+}
+
+// AggregateOrders 按 OrderID 聚合成交，返回订单列表
+func AggregateOrders(accountID string, trades []flex.Trade) []Order {
+	groups := make(map[string][]flex.Trade)
+	var keys []string
+	for _, t := range trades {
+		key := orderKey(accountID, t)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	orders := make([]Order, 0, len(keys))
+	for _, key := range keys {
+		fills := groups[key]
+		m := mergeFills(fills)
+		orders = append(orders, Order{
+			OrderID:       m.OrderID,
+			AccountID:     accountID,
+			Symbol:        m.Symbol,
+			AssetCategory: m.AssetCategory,
+			Currency:      m.Currency,
+			BuySell:       m.BuySell,
+			TradeDate:     isoDate(m.TradeDate),
+			Quantity:      m.Quantity,
+			VWAP:          m.TradePrice,
+			Proceeds:      m.Proceeds,
+			Commission:    m.Commission,
+			NetCash:       m.NetCash,
+			FirstFill:     isoDateTime(fills[0].DateTime),
+			LastFill:      isoDateTime(fills[len(fills)-1].DateTime),
+			Fills:         len(fills),
+		})
+	}
+	return orders
+}
+
+func AnalyzeOrders(statements []flex.FlexStatement, from, to string) *OrderReport {
+	report := &OrderReport{}
+	fx := fxRateHistory(statements)
+
+	seen := make(map[string]bool) // 多个 query 中重复的成交只计一次，见 uniqueTrades
+	for _, stmt := range statements {
+		var trades []flex.Trade
+		for _, t := range stmt.Trades {
+			id := tradeEntryID(t)
+			if seen[id] || !inDateRange(t.TradeDate, from, to) {
+				continue
+			}
+			seen[id] = true
+			trades = append(trades, t)
+			report.TotalComm += commissionInBase(t, fx)
+		}
+		report.Orders = append(report.Orders, AggregateOrders(stmt.AccountID, trades)...)
+	}
+
+	sort.SliceStable(report.Orders, func(i, j int) bool {
+		return report.Orders[i].FirstFill < report.Orders[j].FirstFill
+	})
+
+	for _, o := range report.Orders {
+		report.TotalOrders++
+		report.TotalFills += o.Fills
+	}
+	if report.TotalOrders > 0 {
+		report.AvgCommPerOrder = report.TotalComm / float64(report.TotalOrders)
+		report.AvgFillsPerOrder = float64(report.TotalFills) / float64(report.TotalOrders)
+	}
+	return report
+}
+
+// formatDateTime "2025-01-15T09:30:00" → "2025-01-15 09:30:00"
+func formatDateTime(dt string) string {
+	date, tm, ok := strings.Cut(dt, "T")
+	if !ok {
+		return formatDate(dt)
+	}
+	return formatDate(date) + " " + tm
+}
+
+func PrintOrderReport(r *OrderReport) {
+	printTitle("订单明细")
+	i18n.Printf("订单数:       %d\n", r.TotalOrders)
+	i18n.Printf("成交笔数:     %d\n", r.TotalFills)
+	i18n.Printf("平均成交笔数: %s\n", fmtMoney(r.AvgFillsPerOrder))
+	i18n.Printf("总佣金:       %s\n", fmtMoney(r.TotalComm))
+	i18n.Printf("平均佣金/单:  %s\n", fmtMoney(r.AvgCommPerOrder))
+	fmt.Println()
+
+	if len(r.Orders) > 0 {
+		printTable(
+			[]string{"日期", "标的", "方向", "数量", "均价", "成交额", "佣金", "成交笔数", "首笔成交", "末笔成交"},
+			func() [][]string {
+				var rows [][]string
+				for _, o := range r.Orders {
+					rows = append(rows, []string{
+						formatDate(o.TradeDate),
+						o.Symbol,
+						o.BuySell,
+						fmt.Sprintf("%g", o.Quantity),
+						fmt.Sprintf("%.4f", o.VWAP),
+						fmtMoney(math.Abs(o.Proceeds)),
+						fmtMoney(o.Commission),
+						fmt.Sprintf("%d", o.Fills),
+						formatDateTime(o.FirstFill),
+						formatDateTime(o.LastFill),
+					})
+				}
+				return rows
+			}(),
+		)
+	}
+}
+
+// Tables 导出用的表：汇总、订单明细
+func (r *OrderReport) Tables() []Table {
+	orders := newTable("orders", "订单明细", "日期", "标的", "类别", "币种", "方向", "数量", "均价", "成交额", "佣金", "成交笔数", "首笔成交", "末笔成交")
+	for _, o := range r.Orders {
+		orders.Rows = append(orders.Rows, []any{
+			o.TradeDate, o.Symbol, o.AssetCategory, o.Currency, o.BuySell, o.Quantity, o.VWAP,
+			o.Proceeds, o.Commission, o.Fills, o.FirstFill, o.LastFill,
+		})
+	}
+
+	return []Table{
+		totalsTable(
+			[]any{"订单数", r.TotalOrders},
+			[]any{"成交笔数", r.TotalFills},
+			[]any{"平均成交笔数", r.AvgFillsPerOrder},
+			[]any{"总佣金", r.TotalComm},
+			[]any{"平均佣金/单", r.AvgCommPerOrder},
+		),
+		orders,
+	}
+}
@@ -6,189 +6,92 @@ import (
 	"sort"
 
 	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 )
 
 type SymbolPnL struct {
-	Symbol      string
-	RealizedPnL float64
-	Trades      int
-	Wins        int
-	Commission  float64
+	Symbol      string  `json:"symbol"`
+	RealizedPnL float64 `json:"realized_pnl"`
+	Trades      int     `json:"trades"`
+	Wins        int     `json:"wins"`
+	Commission  float64 `json:"commission"`
 }
 
 type PeriodPnL struct {
-	Period      string
-	RealizedPnL float64
-	Trades      int
-	Commission  float64
+	Period      string  `json:"period"`
+	RealizedPnL float64 `json:"realized_pnl"`
+	Trades      int     `json:"trades"`
+	Commission  float64 `json:"commission"`
 }
 
 type PnLReport struct {
-	BySymbol    []SymbolPnL
-	ByMonth     []PeriodPnL
-	TotalPnL    float64
-	TotalTrades int
-	WinRate     float64
-	TotalComm   float64
+	BySymbol    []SymbolPnL `json:"by_symbol"`
+	ByMonth     []PeriodPnL `json:"by_month"`
+	TotalPnL    float64     `json:"total_realized_pnl"`
+	TotalTrades int         `json:"total_trades"`
+	WinRate     float64     `json:"win_rate"`
+	TotalComm   float64     `json:"total_commission"`
+	Source      string      `json:"pnl_source"` // computed 或 ibkr，见 Options.PnLSource
 }
 
-// fifoLot 表示一个持仓批次
-type fifoLot struct {
-	qty      float64
-	unitCost float64 // 每单位成本（多头买入成本 / 空头收到的权利金），恒为正
-	isShort  bool
-}
+// computeFIFOPnL 用 FIFO 方法计算每个标的的已实现盈亏（按平仓交易的汇率折算为基础货币）
+// 处理多头（先买后卖）和空头（先卖后到期/平仓）两种情况，从期初批次 lots 开始。
+// 所有交易都参与重放以建立批次，只统计 from/to 范围内的平仓，期间之前开仓的成本因此不会丢失。
+// 返回按标的和按平仓月份的结果
+func computeFIFOPnL(trades []flex.Trade, from, to string, lots *OpeningLots) (bySymbol, byMonth map[string]float64) {
+	engine, replay := newSeededLotEngine(lots)
+	bySymbol, byMonth = make(map[string]float64), make(map[string]float64)
+
+	for _, t := range sortTradesByTime(trades) {
+		if !replay(t) {
+			continue
+		}
+		fill := engine.Apply(t)
+		if fill.Realized != 0 && inDateRange(normalizeDate(t.TradeDate), from, to) {
+			pnl := toBase(fill.Realized, t.FxRateToBase)
+			bySymbol[t.Symbol] += pnl
+			byMonth[monthOf(t.TradeDate)] += pnl
+		}
+	}
 
-// computeFIFOPnL 用 FIFO 方法计算每个标的的已实现盈亏
-// 处理多头（先买后卖）和空头（先卖后到期/平仓）两种情况
-func computeFIFOPnL(trades []flex.Trade) map[string]float64 {
-	sorted := make([]flex.Trade, len(trades))
-	copy(sorted, trades)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].DateTime < sorted[j].DateTime
-	})
+	return bySymbol, byMonth
+}
 
-	openLots := make(map[string][]fifoLot)
-	pnl := make(map[string]float64)
-
-	for _, t := range sorted {
-		sym := t.Symbol
-		qty := t.Quantity
-		netCash := t.Proceeds + t.Commission // Commission 为负数
-
-		if t.TransactionType == "BookTrade" {
-			// 期权到期交割，以 0 平仓
-			closeQty := math.Abs(qty)
-			remaining := closeQty
-			for remaining > 1e-9 && len(openLots[sym]) > 0 {
-				l := &openLots[sym][0]
-				match := math.Min(l.qty, remaining)
-				if l.isShort {
-					pnl[sym] += match * l.unitCost // 保住了权利金
-				} else {
-					pnl[sym] -= match * l.unitCost // 多头到期归零，损失买入成本
-				}
-				l.qty -= match
-				remaining -= match
-				if l.qty < 1e-9 {
-					openLots[sym] = openLots[sym][1:]
-				}
-			}
-		} else if qty > 0 {
-			// 买入：先平空头，再开多头
-			remaining := qty
-			for remaining > 1e-9 && len(openLots[sym]) > 0 && openLots[sym][0].isShort {
-				l := &openLots[sym][0]
-				match := math.Min(l.qty, remaining)
-				costToClosePerUnit := (-netCash) / qty
-				pnl[sym] += match*l.unitCost - match*costToClosePerUnit
-				l.qty -= match
-				remaining -= match
-				if l.qty < 1e-9 {
-					openLots[sym] = openLots[sym][1:]
-				}
-			}
-			if remaining > 1e-9 {
-				unitCost := (-netCash) / qty
-				openLots[sym] = append(openLots[sym], fifoLot{remaining, unitCost, false})
-			}
-		} else if qty < 0 {
-			// 卖出：先平多头，再开空头
-			sellQty := -qty
-			remaining := sellQty
-			for remaining > 1e-9 && len(openLots[sym]) > 0 && !openLots[sym][0].isShort {
-				l := &openLots[sym][0]
-				match := math.Min(l.qty, remaining)
-				proceedsPerUnit := netCash / sellQty
-				pnl[sym] += match*proceedsPerUnit - match*l.unitCost
-				l.qty -= match
-				remaining -= match
-				if l.qty < 1e-9 {
-					openLots[sym] = openLots[sym][1:]
-				}
-			}
-			if remaining > 1e-9 {
-				unitPremium := netCash / sellQty
-				openLots[sym] = append(openLots[sym], fifoLot{remaining, unitPremium, true})
-			}
+// reportedPnL 汇总 from/to 范围内 IBKR 给出的 fifoPnlRealized（折算为基础货币），返回按标的和按月份的结果
+func reportedPnL(trades []flex.Trade, from, to string) (bySymbol, byMonth map[string]float64) {
+	bySymbol, byMonth = make(map[string]float64), make(map[string]float64)
+	for _, t := range trades {
+		if t.RealizedPnL != 0 && inDateRange(normalizeDate(t.TradeDate), from, to) {
+			pnl := toBase(t.RealizedPnL, t.FxRateToBase)
+			bySymbol[t.Symbol] += pnl
+			byMonth[monthOf(t.TradeDate)] += pnl
 		}
 	}
-
-	return pnl
+	return bySymbol, byMonth
 }
 
 // computeCostBasisFromTrades 返回每个标的当前持仓的 FIFO 总成本（仍持有的批次）
-func computeCostBasisFromTrades(statements []flex.FlexStatement) map[string]float64 {
+func computeCostBasisFromTrades(statements []flex.FlexStatement, lots *OpeningLots) map[string]float64 {
 	var allTrades []flex.Trade
-	for _, stmt := range statements {
-		allTrades = append(allTrades, stmt.Trades...)
+	for _, t := range uniqueTrades(statements) {
+		if !isFXTrade(t) {
+			allTrades = append(allTrades, t)
+		}
 	}
 
-	sorted := make([]flex.Trade, len(allTrades))
-	copy(sorted, allTrades)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].DateTime < sorted[j].DateTime
-	})
-
-	openLots := make(map[string][]fifoLot)
-
-	for _, t := range sorted {
-		sym := t.Symbol
-		qty := t.Quantity
-		netCash := t.Proceeds + t.Commission
-
-		if t.TransactionType == "BookTrade" {
-			closeQty := math.Abs(qty)
-			remaining := closeQty
-			for remaining > 1e-9 && len(openLots[sym]) > 0 {
-				l := &openLots[sym][0]
-				match := math.Min(l.qty, remaining)
-				l.qty -= match
-				remaining -= match
-				if l.qty < 1e-9 {
-					openLots[sym] = openLots[sym][1:]
-				}
-			}
-		} else if qty > 0 {
-			remaining := qty
-			for remaining > 1e-9 && len(openLots[sym]) > 0 && openLots[sym][0].isShort {
-				l := &openLots[sym][0]
-				match := math.Min(l.qty, remaining)
-				l.qty -= match
-				remaining -= match
-				if l.qty < 1e-9 {
-					openLots[sym] = openLots[sym][1:]
-				}
-			}
-			if remaining > 1e-9 {
-				unitCost := (-netCash) / qty
-				openLots[sym] = append(openLots[sym], fifoLot{remaining, unitCost, false})
-			}
-		} else if qty < 0 {
-			sellQty := -qty
-			remaining := sellQty
-			for remaining > 1e-9 && len(openLots[sym]) > 0 && !openLots[sym][0].isShort {
-				l := &openLots[sym][0]
-				match := math.Min(l.qty, remaining)
-				l.qty -= match
-				remaining -= match
-				if l.qty < 1e-9 {
-					openLots[sym] = openLots[sym][1:]
-				}
-			}
-			if remaining > 1e-9 {
-				unitPremium := netCash / sellQty
-				openLots[sym] = append(openLots[sym], fifoLot{remaining, unitPremium, true})
-			}
+	engine, replay := newSeededLotEngine(lots)
+	for _, t := range sortTradesByTime(allTrades) {
+		if replay(t) {
+			engine.Apply(t)
 		}
 	}
 
 	result := make(map[string]float64)
-	for sym, lots := range openLots {
+	for _, sym := range engine.Symbols() {
 		var totalCost float64
-		for _, l := range lots {
-			if !l.isShort {
-				totalCost += l.qty * l.unitCost
+		for _, l := range engine.OpenLots(sym) {
+			if !l.Short {
+				totalCost += l.Quantity * l.UnitCost
 			}
 		}
 		if totalCost > 0 {
@@ -198,43 +101,56 @@ func computeCostBasisFromTrades(statements []flex.FlexStatement) map[string]floa
 	return result
 }
 
-func AnalyzePnL(statements []flex.FlexStatement, from, to string) *PnLReport {
+// realizedPnL 期间内的已实现盈亏（基础货币），按标的和平仓月份汇总。
+// 按 opts.PnLSource 用全部交易做 FIFO 重放，或直接采用 IBKR 的结果；换汇交易单独在 AnalyzeFX 中分析
+func realizedPnL(statements []flex.FlexStatement, from, to string, opts Options) (bySymbol, byMonth map[string]float64) {
+	var trades []flex.Trade
+	for _, t := range uniqueTrades(statements) {
+		if !isFXTrade(t) {
+			trades = append(trades, t)
+		}
+	}
+	if opts.pnlSource() == PnLSourceIBKR {
+		return reportedPnL(trades, from, to)
+	}
+	return computeFIFOPnL(trades, from, to, opts.OpeningLots)
+}
+
+func AnalyzePnL(statements []flex.FlexStatement, from, to string, opts Options) *PnLReport {
 	// 收集符合日期范围的所有交易
 	var filteredTrades []flex.Trade
-	for _, stmt := range statements {
-		for _, t := range stmt.Trades {
-			if !inDateRange(normalizeDate(t.TradeDate), from, to) {
-				continue
-			}
-			filteredTrades = append(filteredTrades, t)
+	for _, t := range uniqueTrades(statements) {
+		if !inDateRange(normalizeDate(t.TradeDate), from, to) {
+			continue
 		}
+		if isFXTrade(t) {
+			continue // 换汇交易单独在 AnalyzeFX 中分析
+		}
+		filteredTrades = append(filteredTrades, t)
 	}
 
-	// FIFO 计算已实现盈亏
-	fifoResult := computeFIFOPnL(filteredTrades)
+	fifoResult, monthResult := realizedPnL(statements, from, to, opts)
 
 	// 按标的统计佣金和交易次数（以平仓 ExchTrade 为准）
 	symbolMap := make(map[string]*SymbolPnL)
 	monthMap := make(map[string]*PeriodPnL)
 
 	var totalComm float64
+	fx := fxRateHistory(statements)
 	for _, t := range filteredTrades {
-		totalComm += t.Commission
+		comm := commissionInBase(t, fx)
+		totalComm += comm
 
 		sp, ok := symbolMap[t.Symbol]
 		if !ok {
 			sp = &SymbolPnL{Symbol: t.Symbol}
 			symbolMap[t.Symbol] = sp
 		}
-		sp.Commission += t.Commission
+		sp.Commission += comm
 
 		// 只统计平仓 ExchTrade 的交易次数
 		if t.TransactionType != "BookTrade" && (t.Quantity < 0 || t.OpenCloseInd == "C" || t.OpenCloseInd == "C;") {
-			nd := normalizeDate(t.TradeDate)
-			month := ""
-			if len(nd) >= 6 {
-				month = nd[:6]
-			}
+			month := monthOf(t.TradeDate)
 			mp, ok := monthMap[month]
 			if !ok {
 				mp = &PeriodPnL{Period: month}
@@ -265,35 +181,22 @@ func AnalyzePnL(statements []flex.FlexStatement, from, to string) *PnLReport {
 		}
 		totalTrades++
 		totalPnL += symPnL
+	}
 
-		// 月度汇总用第一个平仓交易的月份
-		for _, t := range filteredTrades {
-			if t.Symbol != sym {
-				continue
-			}
-			isSell := t.Quantity < 0
-			isClose := t.TransactionType == "BookTrade" || t.OpenCloseInd == "C" || t.OpenCloseInd == "C;"
-			if isSell || isClose {
-				nd := normalizeDate(t.TradeDate)
-				month := ""
-				if len(nd) >= 6 {
-					month = nd[:6]
-				}
-				mp, ok := monthMap[month]
-				if !ok {
-					mp = &PeriodPnL{Period: month}
-					monthMap[month] = mp
-				}
-				mp.RealizedPnL += symPnL
-				break
-			}
+	for month, pnl := range monthResult {
+		mp, ok := monthMap[month]
+		if !ok {
+			mp = &PeriodPnL{Period: month}
+			monthMap[month] = mp
 		}
+		mp.RealizedPnL += pnl
 	}
 
 	report := &PnLReport{
 		TotalPnL:    totalPnL,
 		TotalTrades: totalTrades,
 		TotalComm:   totalComm,
+		Source:      opts.pnlSource(),
 	}
 	if totalTrades > 0 {
 		report.WinRate = float64(totalWins) / float64(totalTrades) * 100
@@ -316,23 +219,24 @@ func AnalyzePnL(statements []flex.FlexStatement, from, to string) *PnLReport {
 	return report
 }
 
+// formatMonth "2025-01" → 按当前语言格式化的月份
 func formatMonth(yyyymm string) string {
-	if len(yyyymm) >= 6 {
-		return yyyymm[:4] + "-" + yyyymm[4:6]
-	}
-	return yyyymm
+	return i18n.Month(yyyymm)
 }
 
 func PrintPnLReport(r *PnLReport) {
-	fmt.Println("═══ 盈亏分析 ═══")
-	fmt.Printf("已实现盈亏: %.2f\n", r.TotalPnL)
-	fmt.Printf("总交易数:   %d\n", r.TotalTrades)
-	fmt.Printf("胜率:       %.1f%%\n", r.WinRate)
-	fmt.Printf("总佣金:     %.2f\n", r.TotalComm)
+	printTitle("盈亏分析")
+	i18n.Printf("已实现盈亏: %s\n", fmtMoney(r.TotalPnL))
+	i18n.Printf("总交易数:   %d\n", r.TotalTrades)
+	i18n.Printf("胜率:       %.1f%%\n", r.WinRate)
+	i18n.Printf("总佣金:     %s\n", fmtMoney(r.TotalComm))
+	if r.Source == PnLSourceIBKR {
+		i18n.Println("已实现盈亏采用 IBKR 的 fifoPnlRealized")
+	}
 	fmt.Println()
 
 	if len(r.BySymbol) > 0 {
-		fmt.Println("── 按标的 ──")
+		printSection("按标的")
 		printTable(
 			[]string{"标的", "已实现P&L", "交易数", "胜率", "佣金"},
 			func() [][]string {
@@ -344,10 +248,10 @@ func PrintPnLReport(r *PnLReport) {
 					}
 					rows = append(rows, []string{
 						s.Symbol,
-						fmt.Sprintf("%.2f", s.RealizedPnL),
+						fmtMoney(s.RealizedPnL),
 						fmt.Sprintf("%d", s.Trades),
 						fmt.Sprintf("%.0f%%", wr),
-						fmt.Sprintf("%.2f", s.Commission),
+						fmtMoney(s.Commission),
 					})
 				}
 				return rows
@@ -356,7 +260,7 @@ func PrintPnLReport(r *PnLReport) {
 	}
 
 	if len(r.ByMonth) > 0 {
-		fmt.Println("── 按月份 ──")
+		printSection("按月份")
 		printTable(
 			[]string{"月份", "已实现P&L", "交易数", "佣金"},
 			func() [][]string {
@@ -364,9 +268,9 @@ func PrintPnLReport(r *PnLReport) {
 				for _, m := range r.ByMonth {
 					rows = append(rows, []string{
 						formatMonth(m.Period),
-						fmt.Sprintf("%.2f", m.RealizedPnL),
+						fmtMoney(m.RealizedPnL),
 						fmt.Sprintf("%d", m.Trades),
-						fmt.Sprintf("%.2f", m.Commission),
+						fmtMoney(m.Commission),
 					})
 				}
 				return rows
@@ -374,3 +278,31 @@ func PrintPnLReport(r *PnLReport) {
 		)
 	}
 }
+
+// Tables 导出用的表：汇总、按标的、按月份
+func (r *PnLReport) Tables() []Table {
+	bySymbol := newTable("by_symbol", "按标的", "标的", "已实现P&L", "交易数", "胜率 (%)", "佣金")
+	for _, s := range r.BySymbol {
+		wr := 0.0
+		if s.Trades > 0 {
+			wr = float64(s.Wins) / float64(s.Trades) * 100
+		}
+		bySymbol.Rows = append(bySymbol.Rows, []any{s.Symbol, s.RealizedPnL, s.Trades, wr, s.Commission})
+	}
+
+	byMonth := newTable("by_month", "按月份", "月份", "已实现P&L", "交易数", "佣金")
+	for _, m := range r.ByMonth {
+		byMonth.Rows = append(byMonth.Rows, []any{m.Period, m.RealizedPnL, m.Trades, m.Commission})
+	}
+
+	return []Table{
+		totalsTable(
+			[]any{"已实现盈亏", r.TotalPnL},
+			[]any{"总交易数", r.TotalTrades},
+			[]any{"胜率 (%)", r.WinRate},
+			[]any{"总佣金", r.TotalComm},
+		),
+		bySymbol,
+		byMonth,
+	}
+}
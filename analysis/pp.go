@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+// Portfolio Performance CSV 导入的列名（英文界面），导入时按列名自动匹配
+var ppHeaders = []string{
+	"Date", "Time", "Type", "Value", "Transaction Currency", "Shares",
+	"Security Name", "ISIN", "Ticker Symbol", "Fees", "Taxes", "Note",
+}
+
+// ppRow PP 的一条交易；金额均为正数，方向由 Type 决定
+type ppRow struct {
+	date, time, typ string
+	value           float64
+	currency        string
+	shares          float64
+	name, isin      string
+	symbol          string
+	fees, taxes     float64
+	note            string
+}
+
+func (r ppRow) cells() []any {
+	row := []any{isoDate(r.date), r.time, r.typ, r.value, r.currency, nil, r.name, r.isin, r.symbol, nil, nil, r.note}
+	if r.shares != 0 {
+		row[5] = r.shares
+	}
+	if r.fees != 0 {
+		row[9] = r.fees
+	}
+	if r.taxes != 0 {
+		row[10] = r.taxes
+	}
+	return row
+}
+
+// PortfolioPerformanceTables 生成 Portfolio Performance 的两个导入表：
+// 投资组合交易（买卖，Value 为含费用、税的结算金额）和账户交易（股息、利息、费用、税、出入金）。
+// 股息与同日同标的的预扣税合并为一行，Value 为税后净额、Taxes 为预扣税。换汇交易不导出
+func PortfolioPerformanceTables(statements []flex.FlexStatement, from, to string) []Table {
+	isins := isinBySymbol(statements)
+
+	portfolio := Table{Name: "portfolio_transactions", Title: "Portfolio Transactions", Headers: ppHeaders}
+	for _, t := range sortTradesByTime(uniqueTrades(statements)) {
+		if isFXTrade(t) || !inDateRange(normalizeDate(t.TradeDate), from, to) {
+			continue
+		}
+		typ := "Buy"
+		if t.Quantity < 0 {
+			typ = "Sell"
+		}
+		_, tm, _ := strings.Cut(isoDateTime(t.DateTime), "T")
+		portfolio.Rows = append(portfolio.Rows, ppRow{
+			date:     t.TradeDate,
+			time:     tm,
+			typ:      typ,
+			value:    math.Abs(t.Proceeds + t.Commission + t.Taxes),
+			currency: t.Currency,
+			shares:   tradeShares(t),
+			name:     t.Description,
+			isin:     securityISIN(t.Symbol, t.ISIN, isins),
+			symbol:   t.Symbol,
+			fees:     math.Abs(t.Commission),
+			taxes:    math.Abs(t.Taxes),
+			note:     "IBKR " + t.TransactionID,
+		}.cells())
+	}
+
+	account := Table{Name: "account_transactions", Title: "Account Transactions", Headers: ppHeaders}
+	var rows []ppRow
+	dividends := make(map[string]int) // 标的|日期|币种 → rows 下标，用于合并预扣税
+	var withholding []flex.CashTransaction
+	for _, ct := range uniqueCashTransactions(statements) {
+		date := cashDate(ct)
+		if ct.Amount == 0 || !inDateRange(date, from, to) {
+			continue
+		}
+		row := ppRow{
+			date:     date,
+			value:    math.Abs(ct.Amount),
+			currency: ct.Currency,
+			note:     ct.Description,
+		}
+		if ct.Symbol != "" {
+			row.symbol = ct.Symbol
+			row.isin = securityISIN(ct.Symbol, ct.ISIN, isins)
+		}
+		switch ct.Type {
+		case "Dividends", "Payment In Lieu Of Dividends":
+			row.typ = "Dividend"
+			dividends[ct.Symbol+"|"+date+"|"+ct.Currency] = len(rows)
+		case "Withholding Tax":
+			withholding = append(withholding, ct)
+			continue
+		case "Broker Interest Received", "Bond Interest Received", "Broker Interest Paid", "Bond Interest Paid":
+			row.typ = signedType(ct.Amount, "Interest", "Interest Charge")
+		case "Other Fees", "Broker Fees", "Commission Adjustments":
+			row.typ = signedType(ct.Amount, "Fees Refund", "Fees")
+		default:
+			row.typ = signedType(ct.Amount, "Deposit", "Removal")
+		}
+		rows = append(rows, row)
+	}
+	for _, ct := range withholding {
+		if i, ok := dividends[ct.Symbol+"|"+cashDate(ct)+"|"+ct.Currency]; ok {
+			rows[i].value += ct.Amount // 预扣税为负数，Value 变为净额
+			rows[i].taxes -= ct.Amount
+			continue
+		}
+		rows = append(rows, ppRow{
+			date:     cashDate(ct),
+			typ:      signedType(ct.Amount, "Tax Refund", "Taxes"),
+			value:    math.Abs(ct.Amount),
+			currency: ct.Currency,
+			symbol:   ct.Symbol,
+			isin:     securityISIN(ct.Symbol, ct.ISIN, isins),
+			note:     ct.Description,
+		})
+	}
+	for _, stmt := range statements {
+		for _, tr := range stmt.Transfers {
+			date := datePart(tr.DateTime)
+			if tr.Amount == 0 || !isCashTransfer(tr) || !inDateRange(date, from, to) {
+				continue
+			}
+			rows = append(rows, ppRow{
+				date:     date,
+				typ:      signedType(tr.Amount, "Deposit", "Removal"),
+				value:    math.Abs(tr.Amount),
+				currency: tr.Currency,
+				note:     tr.Description,
+			})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].date < rows[j].date })
+	for _, r := range rows {
+		account.Rows = append(account.Rows, r.cells())
+	}
+
+	return []Table{account, portfolio}
+}
+
+func signedType(amount float64, positive, negative string) string {
+	if amount >= 0 {
+		return positive
+	}
+	return negative
+}
+
+// tradeShares 成交数量（绝对值）；期权、期货按合约乘数折算为标的数量，使单价与 tradePrice 一致
+func tradeShares(t flex.Trade) float64 {
+	qty := math.Abs(t.Quantity)
+	if t.Multiplier > 1 {
+		qty *= t.Multiplier
+	}
+	return qty
+}
+
+// securityISIN 优先用记录自带的 ISIN，否则取同一代码在其他记录中出现过的 ISIN
+func securityISIN(symbol, isin string, isins map[string]string) string {
+	if isin != "" {
+		return isin
+	}
+	return isins[symbol]
+}
+
+// cashDate CashTransaction 的日期（YYYYMMDD），dateTime 缺失时用 settleDate
+func cashDate(ct flex.CashTransaction) string {
+	if d := datePart(ct.DateTime); d != "" {
+		return d
+	}
+	return normalizeDate(ct.TradeDate)
+}
@@ -0,0 +1,544 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// reconcileTolerance 小于此金额的差异视为舍入误差
+const reconcileTolerance = 0.01
+
+// ReconcileComponent 一类现金变动：CashReport 的汇总值与按明细记录重算的值
+type ReconcileComponent struct {
+	Name       string  `json:"name"` // trades、dividends、withholding_tax、interest、fees、deposits、other
+	Reported   float64 `json:"reported"`
+	Computed   float64 `json:"computed"`
+	Difference float64 `json:"difference"` // Reported - Computed
+}
+
+// CurrencyReconciliation 一个账户一个币种在一个 CashReport 期间内的现金核对
+type CurrencyReconciliation struct {
+	AccountID           string               `json:"account_id"`
+	Currency            string               `json:"currency"`
+	From                string               `json:"from"`
+	To                  string               `json:"to"`
+	StartingCash        float64              `json:"starting_cash"`
+	EndingCash          float64              `json:"ending_cash"`
+	SummaryEnding       float64              `json:"summary_ending"`       // 期初 + CashReport 各汇总项
+	TransactionEnding   float64              `json:"transaction_ending"`   // 期初 + 交易、现金流水、转账明细
+	SummaryResidual     float64              `json:"summary_residual"`     // 期末 - SummaryEnding，不为 0 说明有未解析的汇总项
+	TransactionResidual float64              `json:"transaction_residual"` // 期末 - TransactionEnding，不为 0 说明缺少明细
+	Components          []ReconcileComponent `json:"components"`
+}
+
+type CashReconciliation struct {
+	Currencies []CurrencyReconciliation `json:"currencies"`
+	Unbalanced int                      `json:"unbalanced"` // 有无法解释差异的币种数
+}
+
+// cashComponents CashReport 汇总项的分类，顺序即输出顺序
+var cashComponents = []struct {
+	name     string
+	reported func(cr flex.CashReportCurrency) float64
+}{
+	{"trades", func(cr flex.CashReportCurrency) float64 {
+		return cr.NetTradesSales + cr.NetTradesPurchases + cr.Commissions + cr.TransactionTax
+	}},
+	{"dividends", func(cr flex.CashReportCurrency) float64 { return cr.Dividends + cr.PaymentInLieu }},
+	{"withholding_tax", func(cr flex.CashReportCurrency) float64 { return cr.WithholdingTax }},
+	{"interest", func(cr flex.CashReportCurrency) float64 { return cr.BrokerInterest + cr.BondInterest }},
+	{"fees", func(cr flex.CashReportCurrency) float64 { return cr.OtherFees + cr.AdvisorFees + cr.SalesTax }},
+	{"deposits", func(cr flex.CashReportCurrency) float64 {
+		deposits := cr.DepositWithdrawals
+		if deposits == 0 {
+			deposits = cr.Deposits + cr.Withdrawals
+		}
+		return deposits + cr.AccountTransfers + cr.InternalTransfers
+	}},
+	{"other", func(cr flex.CashReportCurrency) float64 { return cr.Other + cr.FxTranslationGainLoss }},
+}
+
+// componentName 汇总项分类的显示名称
+func componentName(name string) string {
+	return i18n.T(map[string]string{
+		"trades":          "交易（含佣金、交易税）",
+		"dividends":       "股息",
+		"withholding_tax": "预扣税",
+		"interest":        "利息",
+		"fees":            "费用",
+		"deposits":        "出入金",
+		"other":           "其他",
+	}[name])
+}
+
+// cashComponent 现金流水归入的汇总项，与 CashReport 的分类一致
+func cashComponent(ct flex.CashTransaction) string {
+	switch ct.Type {
+	case "Dividends", "Payment In Lieu Of Dividends":
+		return "dividends"
+	case "Withholding Tax":
+		return "withholding_tax"
+	case "Broker Interest Received", "Broker Interest Paid", "Bond Interest Received", "Bond Interest Paid":
+		return "interest"
+	case "Commission Adjustments":
+		return "trades"
+	case "Other Fees", "Broker Fees", "Advisor Fees":
+		return "fees"
+	case "Deposits/Withdrawals", "Deposits & Withdrawals":
+		return "deposits"
+	}
+	return "other"
+}
+
+// cashFlow 一笔明细记录对某币种现金的影响
+type cashFlow struct {
+	date      string
+	currency  string
+	component string
+	amount    float64
+}
+
+// accountCashFlows 按账户收集交易、现金流水和现金转账的现金影响，多个 query 中重复的只计一次。
+// 换汇交易拆为两个币种；佣金币种与交易币种不同时佣金单独计入佣金币种
+func accountCashFlows(statements []flex.FlexStatement) map[string][]cashFlow {
+	flows := make(map[string][]cashFlow)
+	seen := make(map[string]bool)
+	first := func(account, id string) bool {
+		key := account + "|" + id
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+	add := func(account, date, currency, component string, amount float64) {
+		if currency != "" && amount != 0 {
+			flows[account] = append(flows[account], cashFlow{date, currency, component, amount})
+		}
+	}
+
+	for _, stmt := range statements {
+		account := stmt.AccountID
+		for _, t := range stmt.Trades {
+			if !first(account, tradeEntryID(t)) {
+				continue
+			}
+			date := normalizeDate(t.TradeDate)
+			commissionApart := t.CommissionCurr != "" && t.CommissionCurr != t.Currency
+			switch {
+			case isFXTrade(t):
+				base, quote := splitPair(t.Symbol)
+				if quote == "" {
+					quote = t.Currency
+				}
+				add(account, date, base, "trades", t.Quantity)
+				add(account, date, quote, "trades", t.Proceeds)
+			case commissionApart:
+				add(account, date, t.Currency, "trades", t.Proceeds+t.Taxes)
+			default:
+				add(account, date, t.Currency, "trades", t.NetCash)
+			}
+			if isFXTrade(t) || commissionApart {
+				commCurr := t.CommissionCurr
+				if commCurr == "" {
+					commCurr = t.Currency
+				}
+				add(account, date, commCurr, "trades", t.Commission)
+			}
+		}
+
+		for _, ct := range stmt.CashTransactions {
+			if first(account, cashEntryID(ct)) {
+				add(account, cashDate(ct), ct.Currency, cashComponent(ct), ct.Amount)
+			}
+		}
+
+		for _, tr := range stmt.Transfers {
+			if !isCashTransfer(tr) {
+				continue // 持仓转入转出不计入现金
+			}
+			id := "transfer:" + tr.TransactionID
+			if tr.TransactionID == "" {
+				id = fmt.Sprintf("transfer:%s:%s:%g", tr.DateTime, tr.Currency, tr.Amount)
+			}
+			if first(account, id) {
+				add(account, datePart(tr.DateTime), tr.Currency, "deposits", tr.Amount)
+			}
+		}
+	}
+	return flows
+}
+
+// ReconcileCash 核对每个币种的现金：期初 + 汇总项 = 期末，期初 + 明细记录 = 期末，
+// 并逐项比较 CashReport 汇总值与明细合计，找出缺少的 Flex 段或解析问题。
+// 只核对按币种的 CashReport 行，基础货币汇总行含折算差额，不参与
+func ReconcileCash(statements []flex.FlexStatement) *CashReconciliation {
+	flows := accountCashFlows(statements)
+	report := &CashReconciliation{}
+	seen := make(map[string]bool)
+
+	for _, stmt := range statements {
+		for _, cr := range stmt.CashReport {
+			if cr.Currency == "BASE_SUMMARY" || cr.LevelOfDetail == "BaseCurrency" {
+				continue
+			}
+			account := accountOr(cr.AccountID, stmt.AccountID)
+			from, to := normalizeDate(cr.FromDate), normalizeDate(cr.ToDate)
+			if from == "" {
+				from = normalizeDate(stmt.FromDate)
+			}
+			if to == "" {
+				to = normalizeDate(stmt.ToDate)
+			}
+			key := account + "|" + cr.Currency + "|" + from + "|" + to
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			computed := make(map[string]float64)
+			for _, f := range flows[account] {
+				if f.currency == cr.Currency && inDateRange(f.date, from, to) {
+					computed[f.component] += f.amount
+				}
+			}
+
+			rec := CurrencyReconciliation{
+				AccountID: account, Currency: cr.Currency, From: isoDate(from), To: isoDate(to),
+				StartingCash: cr.StartingCash, EndingCash: cr.EndingCash,
+				SummaryEnding: cr.StartingCash, TransactionEnding: cr.StartingCash,
+			}
+			for _, c := range cashComponents {
+				reported := c.reported(cr)
+				rec.SummaryEnding += reported
+				rec.TransactionEnding += computed[c.name]
+				rec.Components = append(rec.Components, ReconcileComponent{
+					Name:       c.name,
+					Reported:   cleanFloat(reported),
+					Computed:   cleanFloat(computed[c.name]),
+					Difference: cleanFloat(reported - computed[c.name]),
+				})
+			}
+			rec.SummaryEnding = cleanFloat(rec.SummaryEnding)
+			rec.TransactionEnding = cleanFloat(rec.TransactionEnding)
+			rec.SummaryResidual = cleanFloat(rec.EndingCash - rec.SummaryEnding)
+			rec.TransactionResidual = cleanFloat(rec.EndingCash - rec.TransactionEnding)
+			if !rec.Balanced() {
+				report.Unbalanced++
+			}
+			report.Currencies = append(report.Currencies, rec)
+		}
+	}
+
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		a, b := report.Currencies[i], report.Currencies[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		return a.From < b.From
+	})
+	return report
+}
+
+// Balanced 两种重建方式都与期末现金一致，且各汇总项与明细相符
+func (r *CurrencyReconciliation) Balanced() bool {
+	if math.Abs(r.SummaryResidual) >= reconcileTolerance || math.Abs(r.TransactionResidual) >= reconcileTolerance {
+		return false
+	}
+	for _, c := range r.Components {
+		if math.Abs(c.Difference) >= reconcileTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func PrintCashReconciliation(r *CashReconciliation) {
+	printTitle("现金核对")
+	if len(r.Currencies) == 0 {
+		i18n.Println("无 CashReport 数据，请在 Flex Query 中添加 Cash Report 段")
+		return
+	}
+
+	var rows [][]string
+	for _, c := range r.Currencies {
+		rows = append(rows, []string{
+			c.AccountID, c.Currency,
+			formatDate(normalizeDate(c.From)) + " — " + formatDate(normalizeDate(c.To)),
+			fmtMoney(c.StartingCash), fmtMoney(c.EndingCash),
+			fmtMoney(c.SummaryEnding), fmtPnL(c.SummaryResidual),
+			fmtMoney(c.TransactionEnding), fmtPnL(c.TransactionResidual),
+		})
+	}
+	printTable([]string{"账户", "币种", "期间", "期初", "期末", "按汇总项", "汇总差异", "按明细", "明细差异"}, rows)
+	fmt.Println()
+
+	rows = nil
+	for _, c := range r.Currencies {
+		for _, comp := range c.Components {
+			if math.Abs(comp.Difference) >= reconcileTolerance {
+				rows = append(rows, []string{c.AccountID, c.Currency, componentName(comp.Name),
+					fmtMoney(comp.Reported), fmtMoney(comp.Computed), fmtPnL(comp.Difference)})
+			}
+		}
+		if math.Abs(c.SummaryResidual) >= reconcileTolerance {
+			rows = append(rows, []string{c.AccountID, c.Currency, i18n.T("未解析的汇总项"),
+				fmtMoney(c.SummaryResidual), fmtMoney(0), fmtPnL(c.SummaryResidual)})
+		}
+	}
+	if len(rows) == 0 {
+		i18n.Println("✓ 所有币种的期末现金均可由汇总项和明细记录重建")
+		return
+	}
+	printSection("未解释的差异")
+	printTable([]string{"账户", "币种", "类别", "CashReport", "明细合计", "差异"}, rows)
+	fmt.Println()
+	i18n.Printf("⚠ %d 个币种的现金对不上：明细合计偏离说明 Flex Query 缺少对应的段（如 Cash Transactions、Transfers），未解析的汇总项说明 CashReport 有尚未支持的字段\n", r.Unbalanced)
+}
+
+// Tables 导出用的表：各币种核对结果和逐项差异
+func (r *CashReconciliation) Tables() []Table {
+	currencies := newTable("currencies", "现金核对", "账户", "币种", "起始日期", "结束日期", "期初", "期末", "按汇总项", "汇总差异", "按明细", "明细差异")
+	components := newTable("components", "汇总项与明细", "账户", "币种", "起始日期", "结束日期", "类别", "CashReport", "明细合计", "差异")
+	for _, c := range r.Currencies {
+		currencies.Rows = append(currencies.Rows, []any{c.AccountID, c.Currency, c.From, c.To, c.StartingCash, c.EndingCash,
+			c.SummaryEnding, c.SummaryResidual, c.TransactionEnding, c.TransactionResidual})
+		for _, comp := range c.Components {
+			components.Rows = append(components.Rows, []any{c.AccountID, c.Currency, c.From, c.To, comp.Name,
+				comp.Reported, comp.Computed, comp.Difference})
+		}
+	}
+	return []Table{currencies, components}
+}
+
+// 盈亏差异的原因
+const (
+	PnLDiffMissingLots     = "missing_lots"     // 开仓批次不在数据期间内，FIFO 找不到可平的仓位
+	PnLDiffCorporateAction = "corporate_action" // 公司行动调整了数量或成本，FIFO 未处理
+	PnLDiffOptionExercise  = "option_exercise"  // 期权行权/被指派，IBKR 将权利金计入标的成本
+	PnLDiffUnexplained     = "unexplained"
+)
+
+// PnLTradeDiff 一笔平仓交易的 FIFO 重算结果与 IBKR fifoPnlRealized 不一致，金额为交易币种
+type PnLTradeDiff struct {
+	Date          string  `json:"date"`
+	TransactionID string  `json:"transaction_id"`
+	Symbol        string  `json:"symbol"`
+	Currency      string  `json:"currency"`
+	Quantity      float64 `json:"quantity"`
+	Computed      float64 `json:"computed"`
+	Reported      float64 `json:"reported"`
+	Difference    float64 `json:"difference"` // Reported - Computed
+	Reason        string  `json:"reason"`
+}
+
+// SymbolPnLReconciliation 一个标的的已实现盈亏核对，金额为基础货币
+type SymbolPnLReconciliation struct {
+	Symbol     string  `json:"symbol"`
+	Trades     int     `json:"trades"`     // 有已实现盈亏的交易数
+	Mismatched int     `json:"mismatched"` // 其中不一致的交易数
+	Computed   float64 `json:"computed"`
+	Reported   float64 `json:"reported"`
+	Difference float64 `json:"difference"`
+}
+
+type PnLReconciliation struct {
+	Source        string                    `json:"pnl_source"` // 报告当前采用的来源
+	TotalComputed float64                   `json:"total_computed"`
+	TotalReported float64                   `json:"total_reported"`
+	Difference    float64                   `json:"difference"`
+	BySymbol      []SymbolPnLReconciliation `json:"by_symbol"`
+	Trades        []PnLTradeDiff            `json:"trades"` // 只含不一致的交易
+}
+
+// hasNote Trade.Notes 中是否含有某个代码（以 ; 分隔）
+func hasNote(notes string, codes ...string) bool {
+	for n := range strings.SplitSeq(notes, ";") {
+		if slices.Contains(codes, strings.TrimSpace(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pnlDiffName 差异原因的显示名称
+func pnlDiffName(reason string) string {
+	return i18n.T(map[string]string{
+		PnLDiffMissingLots:     "缺少开仓批次",
+		PnLDiffCorporateAction: "公司行动",
+		PnLDiffOptionExercise:  "期权行权/被指派",
+		PnLDiffUnexplained:     "未知",
+	}[reason])
+}
+
+// ReconcilePnL 逐笔比较 FIFO 重算的已实现盈亏与 IBKR 的 fifoPnlRealized。
+// FIFO 从期初批次（opts.OpeningLots）开始使用全部交易建立批次，只比较 from/to 范围内的交易，并推断差异原因：
+// 平仓数量超过已知批次（开仓在数据期间之前）、标的有公司行动、平仓交易或被平批次来自期权行权/被指派
+func ReconcilePnL(statements []flex.FlexStatement, from, to string, opts Options) *PnLReconciliation {
+	var trades []flex.Trade
+	for _, t := range uniqueTrades(statements) {
+		if !isFXTrade(t) {
+			trades = append(trades, t)
+		}
+	}
+	exercised := make(map[string]bool) // 来自行权/被指派的开仓交易
+	for _, t := range trades {
+		if hasNote(t.Notes, "A", "Ex") {
+			exercised[t.TransactionID] = true
+		}
+	}
+	corporateActions := make(map[string]string) // 标的 → 最早的公司行动日期
+	for _, stmt := range statements {
+		for _, ca := range stmt.CorporateActions {
+			date := datePart(ca.DateTime)
+			if d, ok := corporateActions[ca.Symbol]; !ok || date < d {
+				corporateActions[ca.Symbol] = date
+			}
+		}
+	}
+
+	report := &PnLReconciliation{Source: opts.pnlSource()}
+	symbols := make(map[string]*SymbolPnLReconciliation)
+	engine, replay := newSeededLotEngine(opts.OpeningLots)
+	for _, t := range sortTradesByTime(trades) {
+		if !replay(t) {
+			continue
+		}
+		fill := engine.Apply(t)
+		date := normalizeDate(t.TradeDate)
+		if !inDateRange(date, from, to) || (fill.Realized == 0 && t.RealizedPnL == 0) {
+			continue
+		}
+
+		s := symbols[t.Symbol]
+		if s == nil {
+			s = &SymbolPnLReconciliation{Symbol: t.Symbol}
+			symbols[t.Symbol] = s
+		}
+		s.Trades++
+		s.Computed += toBase(fill.Realized, t.FxRateToBase)
+		s.Reported += toBase(t.RealizedPnL, t.FxRateToBase)
+
+		diff := cleanFloat(t.RealizedPnL - fill.Realized)
+		if math.Abs(diff) < reconcileTolerance {
+			continue
+		}
+		s.Mismatched++
+
+		var closed float64
+		fromExercise := hasNote(t.Notes, "A", "Ex")
+		for _, m := range fill.Closed {
+			closed += m.Lot.Quantity
+			fromExercise = fromExercise || exercised[m.Lot.TransactionID]
+		}
+		caDate, hasCA := corporateActions[t.Symbol]
+		reason := PnLDiffUnexplained
+		switch {
+		case closed < math.Abs(t.Quantity)-1e-9:
+			reason = PnLDiffMissingLots
+		case hasCA && caDate <= date:
+			reason = PnLDiffCorporateAction
+		case fromExercise:
+			reason = PnLDiffOptionExercise
+		}
+		report.Trades = append(report.Trades, PnLTradeDiff{
+			Date: isoDate(date), TransactionID: t.TransactionID, Symbol: t.Symbol, Currency: t.Currency,
+			Quantity: t.Quantity, Computed: cleanFloat(fill.Realized), Reported: t.RealizedPnL,
+			Difference: diff, Reason: reason,
+		})
+	}
+
+	for _, s := range symbols {
+		s.Computed, s.Reported = cleanFloat(s.Computed), cleanFloat(s.Reported)
+		s.Difference = cleanFloat(s.Reported - s.Computed)
+		report.TotalComputed += s.Computed
+		report.TotalReported += s.Reported
+		report.BySymbol = append(report.BySymbol, *s)
+	}
+	report.TotalComputed, report.TotalReported = cleanFloat(report.TotalComputed), cleanFloat(report.TotalReported)
+	report.Difference = cleanFloat(report.TotalReported - report.TotalComputed)
+	sort.Slice(report.BySymbol, func(i, j int) bool {
+		a, b := math.Abs(report.BySymbol[i].Difference), math.Abs(report.BySymbol[j].Difference)
+		if a != b {
+			return a > b
+		}
+		return report.BySymbol[i].Symbol < report.BySymbol[j].Symbol
+	})
+	return report
+}
+
+func PrintPnLReconciliation(r *PnLReconciliation) {
+	printTitle("盈亏核对")
+	i18n.Printf("FIFO 重算:             %s\n", fmtMoney(r.TotalComputed))
+	i18n.Printf("IBKR fifoPnlRealized:  %s\n", fmtMoney(r.TotalReported))
+	i18n.Printf("差异:                  %s\n", fmtPnL(r.Difference))
+	i18n.Printf("报告采用:              %s\n", r.Source)
+	fmt.Println()
+
+	if len(r.BySymbol) > 0 {
+		printSection("按标的")
+		var rows [][]string
+		for _, s := range r.BySymbol {
+			rows = append(rows, []string{s.Symbol, fmt.Sprintf("%d/%d", s.Mismatched, s.Trades),
+				fmtMoney(s.Computed), fmtMoney(s.Reported), fmtPnL(s.Difference)})
+		}
+		printTable([]string{"标的", "不一致/交易数", "FIFO 重算", "IBKR", "差异"}, rows)
+		fmt.Println()
+	}
+
+	if len(r.Trades) == 0 {
+		i18n.Println("✓ 每笔交易的已实现盈亏都与 IBKR 一致")
+		return
+	}
+	printSection("不一致的交易")
+	var rows [][]string
+	for _, t := range r.Trades {
+		rows = append(rows, []string{formatDate(normalizeDate(t.Date)), t.TransactionID, t.Symbol, formatNumber(t.Quantity),
+			fmtMoney(t.Computed) + " " + t.Currency, fmtMoney(t.Reported), fmtPnL(t.Difference), pnlDiffName(t.Reason)})
+	}
+	printTable([]string{"日期", "TransactionID", "标的", "数量", "FIFO 重算", "IBKR", "差异", "原因"}, rows)
+	fmt.Println()
+	reasons := make(map[string]bool)
+	for _, t := range r.Trades {
+		reasons[t.Reason] = true
+	}
+	if reasons[PnLDiffMissingLots] {
+		i18n.Println("缺少开仓批次：开仓早于数据期间，FIFO 无法得知成本，IBKR 的结果更可信")
+	}
+	if reasons[PnLDiffCorporateAction] || reasons[PnLDiffOptionExercise] {
+		i18n.Println("公司行动、期权行权/被指派：IBKR 调整了批次的数量或成本，本程序的 FIFO 未处理")
+	}
+	if r.Source != PnLSourceIBKR {
+		i18n.Println("可用 --pnl-source ibkr（或配置 pnl_source = \"ibkr\"）让各报告采用 IBKR 的已实现盈亏")
+	}
+}
+
+// Tables 导出用的表：汇总、按标的、不一致的交易
+func (r *PnLReconciliation) Tables() []Table {
+	bySymbol := newTable("by_symbol", "按标的", "标的", "交易数", "不一致", "FIFO 重算", "IBKR", "差异")
+	for _, s := range r.BySymbol {
+		bySymbol.Rows = append(bySymbol.Rows, []any{s.Symbol, s.Trades, s.Mismatched, s.Computed, s.Reported, s.Difference})
+	}
+	trades := newTable("trades", "不一致的交易", "日期", "TransactionID", "标的", "币种", "数量", "FIFO 重算", "IBKR", "差异", "原因")
+	for _, t := range r.Trades {
+		trades.Rows = append(trades.Rows, []any{t.Date, t.TransactionID, t.Symbol, t.Currency, t.Quantity, t.Computed, t.Reported, t.Difference, t.Reason})
+	}
+	return []Table{
+		totalsTable(
+			[]any{"FIFO 重算", r.TotalComputed},
+			[]any{"IBKR", r.TotalReported},
+			[]any{"差异", r.Difference},
+		),
+		bySymbol,
+		trades,
+	}
+}
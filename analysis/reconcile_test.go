@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+)
+
+func TestReconcilePnLReasons(t *testing.T) {
+	buy := flex.Trade{Symbol: "AAPL", TradeDate: "20250110", DateTime: "20250110;100000", Quantity: 10, Proceeds: -1000, FxRateToBase: 1, TransactionID: "b1"}
+	sell := func(reported float64) flex.Trade {
+		return flex.Trade{Symbol: "AAPL", TradeDate: "20250120", DateTime: "20250120;100000", Quantity: -10, Proceeds: 1200,
+			RealizedPnL: reported, FxRateToBase: 1, TransactionID: "s1"}
+	}
+
+	tests := []struct {
+		name             string
+		trades           []flex.Trade
+		corporateActions []flex.CorporateAction
+		opts             Options
+		reasons          []string // 不一致交易的原因，按交易顺序
+		difference       float64
+	}{
+		{
+			name:   "matching trade",
+			trades: []flex.Trade{buy, sell(200)},
+		},
+		{
+			name:       "close without a known lot",
+			trades:     []flex.Trade{sell(300)},
+			reasons:    []string{PnLDiffMissingLots},
+			difference: 300, // 没有批次时卖出开空头，重算为 0
+		},
+		{
+			name:   "opening lots supply the missing lot",
+			trades: []flex.Trade{sell(300)},
+			opts: Options{OpeningLots: &OpeningLots{Lots: []Lot{
+				{Symbol: "AAPL", Date: "20240601", Quantity: 10, UnitCost: 90},
+			}}},
+		},
+		{
+			name:   "corporate action before the close",
+			trades: []flex.Trade{buy, sell(150)},
+			corporateActions: []flex.CorporateAction{
+				{Symbol: "AAPL", DateTime: "20250115;000000", Type: "FS", Quantity: 10, TransactionID: "ca1"},
+			},
+			reasons:    []string{PnLDiffCorporateAction},
+			difference: -50,
+		},
+		{
+			name: "lot opened by an option exercise",
+			trades: []flex.Trade{
+				{Symbol: "AAPL", TradeDate: "20250110", DateTime: "20250110;100000", Quantity: 10, Proceeds: -1000,
+					FxRateToBase: 1, TransactionID: "b1", Notes: "Ex"},
+				sell(120),
+			},
+			reasons:    []string{PnLDiffOptionExercise},
+			difference: -80,
+		},
+		{
+			name:       "unexplained difference",
+			trades:     []flex.Trade{buy, sell(190)},
+			reasons:    []string{PnLDiffUnexplained},
+			difference: -10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := []flex.FlexStatement{{
+				AccountID: "U1", FromDate: "20250101", ToDate: "20250131",
+				Trades: tt.trades, CorporateActions: tt.corporateActions,
+			}}
+			r := ReconcilePnL(statements, "", "", tt.opts)
+			if len(r.Trades) != len(tt.reasons) {
+				t.Fatalf("mismatched trades = %+v, want reasons %v", r.Trades, tt.reasons)
+			}
+			for i, d := range r.Trades {
+				if d.Reason != tt.reasons[i] {
+					t.Errorf("trade %s reason = %s, want %s", d.TransactionID, d.Reason, tt.reasons[i])
+				}
+			}
+			if !approxEqual(r.Difference, tt.difference) {
+				t.Errorf("Difference = %g, want %g", r.Difference, tt.difference)
+			}
+		})
+	}
+}
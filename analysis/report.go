@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+//go:embed templates/report.md.tmpl
+var defaultReportTemplate string
+
+// ReportData 报告模板的数据模型，模板中以 "." 访问，例如 {{.Summary.AccountValue}}。
+//
+// 各字段即对应 analyze 子命令的分析结果：
+//
+//	.Summary      *SummaryReport     账户汇总：Positions、TotalValue、CashBalance、AccountValue、
+//	                                 TotalUnrealPnL、TotalRealPnL、TotalDivNet、TotalCommission、
+//	                                 TotalDeposits、TotalWithdrawals
+//	.PnL          *PnLReport         已实现盈亏：BySymbol、ByMonth、TotalPnL、TotalTrades、WinRate、TotalComm
+//	.Dividends    *DividendReport    股息：BySymbol、ByMonth、TotalGross、TotalWithhold、TotalNet、TotalCount
+//	.Commissions  *CommissionReport  佣金：BySymbol、ByCategory、TotalComm、TotalTrades、Efficiency、Breakdown
+//	.Fees         *FeeReport         交易成本：ByType、BySymbol、ByMonth、TotalCommission、TotalFees、TotalTaxes、TotalCost
+//	.FX           *FXReport          外汇：BaseCurrency、Conversions、ByCurrency、TotalRealized、TotalUnrealized
+//
+// 模板中可用的函数：
+//
+//	t msg                 按当前语言翻译（以中文原文为键）
+//	fmtMoney v            保留两位小数（英文带千位分隔符）
+//	fmtPnL v              同 fmtMoney，正数带 "+"
+//	formatDate d          "20250115" → "2025-01-15"（英文 "Jan 15, 2025"）
+//	formatMonth m         "2025-01"（英文 "Jan 2025"）
+//	formatDateTime t      生成时间等 time.Time
+//	percent part total    占比，默认一位小数，如 "12.3%"；percent a b 0 指定小数位
+//	feeCategoryName c     费用类别的显示名称
+//	add a b / sub a b     数值加减
+type ReportData struct {
+	PeriodFrom  string // 报告期间（YYYYMMDD）
+	PeriodTo    string
+	GeneratedAt time.Time
+
+	Summary     *SummaryReport
+	PnL         *PnLReport
+	Dividends   *DividendReport
+	Commissions *CommissionReport
+	Fees        *FeeReport
+	FX          *FXReport
+
+	NetDeposits float64 // 总入金 + 总出金（出金为负数）
+	TotalReturn float64 // 已实现 + 未实现 + 净股息 + 佣金
+	ReturnPct   float64 // 综合收益 / 总入金 × 100
+}
+
+// BuildReportData 运行所有分析，组装报告数据
+func BuildReportData(statements []flex.FlexStatement, from, to string, opts Options) *ReportData {
+	data := &ReportData{
+		GeneratedAt: time.Now(),
+		Summary:     AnalyzeSummary(statements, from, to, opts),
+		PnL:         AnalyzePnL(statements, from, to, opts),
+		Dividends:   AnalyzeDividends(statements, from, to),
+		Commissions: AnalyzeCommissions(statements, from, to),
+		Fees:        AnalyzeFees(statements, from, to),
+		FX:          AnalyzeFX(statements, from, to),
+	}
+	data.PeriodFrom, data.PeriodTo = reportPeriod(statements)
+
+	s := data.Summary
+	data.NetDeposits = s.TotalDeposits + s.TotalWithdrawals
+	data.TotalReturn = s.TotalRealPnL + s.TotalUnrealPnL + s.TotalDivNet + s.TotalCommission
+	if s.TotalDeposits > 0 {
+		data.ReturnPct = data.TotalReturn / s.TotalDeposits * 100
+	}
+	return data
+}
+
+// toFloat 模板中的数值参数可能是 int 或 float64
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
+
+var templateFuncs = template.FuncMap{
+	"t":               i18n.T,
+	"formatDateTime":  i18n.DateTime,
+	"fmtMoney":        fmtMoney,
+	"fmtPnL":          fmtPnL,
+	"formatDate":      formatDate,
+	"formatMonth":     formatMonth,
+	"feeCategoryName": feeCategoryName,
+	"percent": func(part, total any, decimals ...int) string {
+		d := 1
+		if len(decimals) > 0 {
+			d = decimals[0]
+		}
+		pct := 0.0
+		if t := toFloat(total); t != 0 {
+			pct = toFloat(part) / t * 100
+		}
+		return fmt.Sprintf("%.*f%%", d, pct)
+	},
+	"add": func(a, b any) float64 { return toFloat(a) + toFloat(b) },
+	"sub": func(a, b any) float64 { return toFloat(a) - toFloat(b) },
+}
+
+// RenderReport 用 text/template 渲染报告，tmplText 为空时使用内置 Markdown 模板
+func RenderReport(data *ReportData, tmplText string) (string, error) {
+	if tmplText == "" {
+		tmplText = defaultReportTemplate
+	}
+	tmpl, err := template.New("report").Funcs(templateFuncs).Parse(tmplText)
+	if err != nil {
+		return "", i18n.Errorf("解析模板失败: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", i18n.Errorf("渲染模板失败: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,259 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// 风险规则，与配置 [risk] 中的键相同
+const (
+	RiskMaxPositionWeight      = "max_position_weight"
+	RiskMaxSectorWeight        = "max_sector_weight"
+	RiskMaxMarginUsage         = "max_margin_usage"
+	RiskMaxShortOptionNotional = "max_short_option_notional"
+	RiskMinCash                = "min_cash"
+)
+
+// RiskRules 风险限额，0 为不检查。权重、使用率为百分比，金额为基础货币
+type RiskRules struct {
+	MaxPositionWeight      float64 `mapstructure:"max_position_weight"`       // 单一标的（期权归入标的）占净资产
+	MaxSectorWeight        float64 `mapstructure:"max_sector_weight"`         // 单一行业占净资产，需要证券主数据
+	MaxMarginUsage         float64 `mapstructure:"max_margin_usage"`          // 每个账户的融资（负现金）占多头市值
+	MaxShortOptionNotional float64 `mapstructure:"max_short_option_notional"` // 卖出期权的名义金额（行权价 × 乘数 × 张数）合计
+	MinCash                float64 `mapstructure:"min_cash"`                  // 各账户期末现金合计
+}
+
+// RiskCheck 一条规则对一个对象（标的、行业、账户，账户级规则为空）的检查结果
+type RiskCheck struct {
+	Rule    string  `json:"rule"`
+	Subject string  `json:"subject"`
+	Value   float64 `json:"value"`
+	Limit   float64 `json:"limit"`
+	Passed  bool    `json:"passed"`
+	Skipped bool    `json:"skipped"` // 缺少所需数据，未检查
+}
+
+type RiskReport struct {
+	AsOf           string      `json:"as_of"`
+	NetLiquidation float64     `json:"net_liquidation"`
+	Checks         []RiskCheck `json:"checks"`
+	Violations     int         `json:"violations"`
+}
+
+// CheckRisk 用最近一期 OpenPositions 和 CashReport 检查风险限额。
+// 按对象检查的规则列出所有超限的对象，都未超限时只列出最接近限额的一个
+func CheckRisk(statements []flex.FlexStatement, rules RiskRules, opts Options) *RiskReport {
+	alloc := AnalyzeAllocation(statements, opts)
+	report := &RiskReport{AsOf: alloc.AsOf, NetLiquidation: alloc.NetLiquidation}
+
+	// check 检查一组对象：超限的全部列出，否则列出最接近限额的一个；below 为下限规则
+	check := func(rule string, limit float64, values map[string]float64, below bool) {
+		if limit == 0 {
+			return
+		}
+		var checks []RiskCheck
+		for subject, v := range values {
+			passed := v <= limit
+			if below {
+				passed = v >= limit
+			}
+			checks = append(checks, RiskCheck{Rule: rule, Subject: subject, Value: v, Limit: limit, Passed: passed})
+		}
+		sort.Slice(checks, func(i, j int) bool {
+			if checks[i].Value == checks[j].Value {
+				return checks[i].Subject < checks[j].Subject
+			}
+			if below {
+				return checks[i].Value < checks[j].Value
+			}
+			return checks[i].Value > checks[j].Value
+		})
+		var failed []RiskCheck
+		for _, c := range checks {
+			if !c.Passed {
+				failed = append(failed, c)
+			}
+		}
+		switch {
+		case len(failed) > 0:
+			report.Checks = append(report.Checks, failed...)
+		case len(checks) > 0:
+			report.Checks = append(report.Checks, checks[0])
+		}
+	}
+	weights := func(exposures []Exposure) map[string]float64 {
+		m := make(map[string]float64)
+		for _, e := range exposures {
+			if e.Name != ExposureCash && e.Name != ExposureUnclassified {
+				m[e.Name] = math.Abs(e.Weight)
+			}
+		}
+		return m
+	}
+
+	check(RiskMaxPositionWeight, rules.MaxPositionWeight, weights(alloc.ByName), false)
+
+	if rules.MaxSectorWeight != 0 && opts.Securities == nil {
+		report.Checks = append(report.Checks, RiskCheck{Rule: RiskMaxSectorWeight, Limit: rules.MaxSectorWeight, Passed: true, Skipped: true})
+	} else {
+		check(RiskMaxSectorWeight, rules.MaxSectorWeight, weights(alloc.BySector), false)
+	}
+
+	check(RiskMaxMarginUsage, rules.MaxMarginUsage, marginUsage(statements), false)
+	check(RiskMaxShortOptionNotional, rules.MaxShortOptionNotional, map[string]float64{"": shortOptionNotional(statements)}, false)
+	check(RiskMinCash, rules.MinCash, map[string]float64{"": alloc.Cash}, true)
+
+	for _, c := range report.Checks {
+		if !c.Passed {
+			report.Violations++
+		}
+	}
+	return report
+}
+
+// marginUsage 每个账户的融资使用率：负的期末现金（基础货币）占多头持仓市值的百分比
+func marginUsage(statements []flex.FlexStatement) map[string]float64 {
+	long := make(map[string]float64)
+	for key, op := range latestPositions(statements) {
+		account, _, _ := strings.Cut(key, "|")
+		if op.Position > 0 && op.AssetCategory != "CASH" {
+			long[account] += toBase(op.PositionValue, op.FxRateToBase)
+		}
+	}
+	usage := make(map[string]float64)
+	for key, cash := range latestCashReport(statements) {
+		account, currency, _ := strings.Cut(key, "|")
+		if currency != "BASE_SUMMARY" {
+			continue
+		}
+		switch {
+		case cash >= 0:
+			usage[account] = 0
+		case long[account] > 0:
+			usage[account] = -cash / long[account] * 100
+		default:
+			usage[account] = 100 // 有借款但没有多头持仓，视为用尽
+		}
+	}
+	return usage
+}
+
+// shortOptionNotional 卖出期权的名义金额合计（基础货币）：行权价 × 乘数 × 张数，
+// 没有 strike 字段时从 OCC 代码解析行权价
+func shortOptionNotional(statements []flex.FlexStatement) float64 {
+	var total float64
+	for _, op := range latestPositions(statements) {
+		if op.AssetCategory != "OPT" || op.Position >= 0 {
+			continue
+		}
+		strike := op.Strike
+		if strike == 0 {
+			_, strike, _ = parseOCCSymbol(op.Symbol)
+		}
+		mult := op.Multiplier
+		if mult == 0 {
+			mult = 100
+		}
+		total += toBase(-op.Position*mult*strike, op.FxRateToBase)
+	}
+	return total
+}
+
+// riskRuleName 风险规则的显示名称
+func riskRuleName(rule string) string {
+	return i18n.T(map[string]string{
+		RiskMaxPositionWeight:      "单一标的占比上限",
+		RiskMaxSectorWeight:        "单一行业占比上限",
+		RiskMaxMarginUsage:         "融资使用率上限",
+		RiskMaxShortOptionNotional: "卖出期权名义金额上限",
+		RiskMinCash:                "现金下限",
+	}[rule])
+}
+
+// formatRiskValue 权重、使用率显示为百分比，其余为金额
+func formatRiskValue(rule string, v float64) string {
+	switch rule {
+	case RiskMaxPositionWeight, RiskMaxSectorWeight, RiskMaxMarginUsage:
+		return fmt.Sprintf("%.1f%%", v)
+	}
+	return fmtMoney(v)
+}
+
+// Summary 一行文字概括检查结果，用于 webhook 通知
+func (r *RiskReport) Summary() string {
+	if r.Violations == 0 {
+		return i18n.Sprintf("风险检查通过（%s）", formatDate(normalizeDate(r.AsOf)))
+	}
+	var parts []string
+	for _, c := range r.Checks {
+		if c.Passed {
+			continue
+		}
+		part := riskRuleName(c.Rule)
+		if c.Subject != "" {
+			part += " " + c.Subject
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s / %s", part, formatRiskValue(c.Rule, c.Value), formatRiskValue(c.Rule, c.Limit)))
+	}
+	return i18n.Sprintf("风险检查（%s）发现 %d 项超限: %s", formatDate(normalizeDate(r.AsOf)), r.Violations, strings.Join(parts, "; "))
+}
+
+func PrintRiskReport(r *RiskReport) {
+	printTitle("风险检查")
+	i18n.Printf("截至 %s\n", formatDate(normalizeDate(r.AsOf)))
+	i18n.Printf("净资产: %s\n", fmtMoney(r.NetLiquidation))
+	fmt.Println()
+
+	if len(r.Checks) == 0 {
+		i18n.Println("未配置风险规则（[risk]）")
+		return
+	}
+	var rows [][]string
+	for _, c := range r.Checks {
+		status := "✓"
+		switch {
+		case c.Skipped:
+			status = i18n.T("跳过")
+		case !c.Passed:
+			status = "✗"
+		}
+		value := formatRiskValue(c.Rule, c.Value)
+		if c.Skipped {
+			value = "-"
+		}
+		rows = append(rows, []string{riskRuleName(c.Rule), c.Subject, value, formatRiskValue(c.Rule, c.Limit), status})
+	}
+	printTable([]string{"规则", "对象", "当前值", "限额", "结果"}, rows)
+
+	for _, c := range r.Checks {
+		if c.Skipped && c.Rule == RiskMaxSectorWeight {
+			i18n.Println("单一行业占比需要证券主数据（security_master），已跳过")
+		}
+	}
+	if r.Violations > 0 {
+		i18n.Printf("✗ %d 项超限\n", r.Violations)
+	} else {
+		i18n.Println("✓ 所有规则均未超限")
+	}
+}
+
+// Tables 导出用的表：检查结果
+func (r *RiskReport) Tables() []Table {
+	checks := newTable("checks", "风险检查", "规则", "对象", "当前值", "限额", "结果")
+	for _, c := range r.Checks {
+		status := "passed"
+		switch {
+		case c.Skipped:
+			status = "skipped"
+		case !c.Passed:
+			status = "failed"
+		}
+		checks.Rows = append(checks.Rows, []any{c.Rule, c.Subject, c.Value, c.Limit, status})
+	}
+	return []Table{checks}
+}
@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// Security 证券主数据中的一条：Flex 数据里没有的行业和国家/地区
+type Security struct {
+	Symbol  string `json:"symbol"`
+	ISIN    string `json:"isin,omitempty"`
+	Sector  string `json:"sector"`
+	Country string `json:"country"`
+}
+
+// SecurityMaster 本地维护的证券主数据，按代码和 ISIN 查找
+type SecurityMaster struct {
+	bySymbol map[string]Security
+	byISIN   map[string]Security
+}
+
+// Len 主数据中的证券数
+func (m *SecurityMaster) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.bySymbol)
+}
+
+// lookup 查找持仓对应的证券：先按 ISIN，再按代码；期权、期货按标的代码。m 为 nil 时都找不到
+func (m *SecurityMaster) lookup(op flex.OpenPosition) (Security, bool) {
+	if m == nil {
+		return Security{}, false
+	}
+	if s, ok := m.byISIN[op.ISIN]; ok && op.ISIN != "" {
+		return s, true
+	}
+	if s, ok := m.bySymbol[strings.ToUpper(op.Symbol)]; ok {
+		return s, true
+	}
+	if op.UnderlyingSymbol != "" {
+		s, ok := m.bySymbol[strings.ToUpper(op.UnderlyingSymbol)]
+		return s, ok
+	}
+	return Security{}, false
+}
+
+// ReadSecurityMaster 读取证券主数据 CSV，表头为 symbol,sector,country，可选 isin
+func ReadSecurityMaster(r io.Reader) (*SecurityMaster, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	header, err := cr.Read()
+	if err != nil {
+		return nil, i18n.Errorf("读取证券主数据失败: %w", err)
+	}
+	col := csvColumns(header)
+	for _, name := range []string{"symbol", "sector", "country"} {
+		if _, ok := col[name]; !ok {
+			return nil, i18n.Errorf("证券主数据缺少列: %s", name)
+		}
+	}
+
+	m := &SecurityMaster{bySymbol: make(map[string]Security), byISIN: make(map[string]Security)}
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, i18n.Errorf("读取证券主数据失败: %w", err)
+		}
+		s := Security{
+			Symbol:  csvField(col, rec, "symbol"),
+			ISIN:    csvField(col, rec, "isin"),
+			Sector:  csvField(col, rec, "sector"),
+			Country: csvField(col, rec, "country"),
+		}
+		if s.Symbol == "" {
+			line, _ := cr.FieldPos(0)
+			return nil, i18n.Errorf("证券主数据第 %d 行: 缺少 symbol", line)
+		}
+		m.bySymbol[strings.ToUpper(s.Symbol)] = s
+		if s.ISIN != "" {
+			m.byISIN[s.ISIN] = s
+		}
+	}
+	return m, nil
+}
@@ -5,41 +5,53 @@ import (
 	"sort"
 
 	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 )
 
 type PositionSummary struct {
-	Symbol         string
-	Category       string
-	Currency       string
-	Position       float64
-	MarkPrice      float64
-	CostBasis      float64
-	PositionValue  float64
-	UnrealizedPnL  float64
+	Symbol        string  `json:"symbol"`
+	Category      string  `json:"category"`
+	Currency      string  `json:"currency"`
+	Position      float64 `json:"position"`
+	MarkPrice     float64 `json:"mark_price"`
+	CostBasis     float64 `json:"cost_basis"`
+	PositionValue float64 `json:"position_value"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	FxRateToBase  float64 `json:"fx_rate_to_base"`
+}
+
+// BaseValue 折算为基础货币的市值，用于与 TotalValue 比较占比
+func (p PositionSummary) BaseValue() float64 {
+	return toBase(p.PositionValue, p.FxRateToBase)
 }
 
 type SummaryReport struct {
-	Positions       []PositionSummary
-	TotalValue      float64
-	TotalUnrealPnL  float64
-	TotalRealPnL    float64
-	TotalDivNet     float64
-	TotalCommission float64
-	CashBalance     float64
-	TotalDeposits   float64
-	TotalWithdrawals float64
-	AccountValue    float64 // 持仓 + 现金
+	Positions        []PositionSummary `json:"positions"`
+	TotalValue       float64           `json:"total_value"`
+	TotalUnrealPnL   float64           `json:"total_unrealized_pnl"`
+	TotalRealPnL     float64           `json:"total_realized_pnl"`
+	TotalDivNet      float64           `json:"total_dividends_net"`
+	TotalCommission  float64           `json:"total_commission"`
+	CashBalance      float64           `json:"cash_balance"`
+	TotalDeposits    float64           `json:"total_deposits"`
+	TotalWithdrawals float64           `json:"total_withdrawals"`
+	AccountValue     float64           `json:"account_value"` // 持仓 + 现金
+
+	Benchmarks []BenchmarkComparison `json:"benchmarks"` // 配置了 [[benchmarks]] 时与各基准的对比
 }
 
-func AnalyzeSummary(statements []flex.FlexStatement, from, to string) *SummaryReport {
+func AnalyzeSummary(statements []flex.FlexStatement, from, to string, opts Options) *SummaryReport {
 	report := &SummaryReport{}
 
 	// 用 FIFO 推算持仓成本价（当 OpenPosition 里的 costBasisPrice 为 0 时）
-	costBySymbol := computeCostBasisFromTrades(statements)
+	costBySymbol := computeCostBasisFromTrades(statements, opts.OpeningLots)
 
 	// 持仓概览
 	for _, stmt := range statements {
 		for _, op := range stmt.OpenPositions {
+			if op.LevelOfDetail == "LOT" {
+				continue // 按批次展开时另有 SUMMARY 行
+			}
 			costBasis := op.CostBasis
 			if costBasis == 0 {
 				if totalCost, ok := costBySymbol[op.Symbol]; ok && op.Position > 0 {
@@ -59,15 +71,16 @@ func AnalyzeSummary(statements []flex.FlexStatement, from, to string) *SummaryRe
 				CostBasis:     costBasis,
 				PositionValue: op.PositionValue,
 				UnrealizedPnL: unrealPnL,
+				FxRateToBase:  op.FxRateToBase,
 			}
 			report.Positions = append(report.Positions, ps)
-			report.TotalValue += op.PositionValue
-			report.TotalUnrealPnL += unrealPnL
+			report.TotalValue += toBase(op.PositionValue, op.FxRateToBase)
+			report.TotalUnrealPnL += toBase(unrealPnL, op.FxRateToBase)
 		}
 	}
 
 	sort.Slice(report.Positions, func(i, j int) bool {
-		return report.Positions[i].PositionValue > report.Positions[j].PositionValue
+		return report.Positions[i].BaseValue() > report.Positions[j].BaseValue()
 	})
 
 	// 从 CashReport 获取期间数据（BASE_SUMMARY 行）
@@ -84,31 +97,32 @@ func AnalyzeSummary(statements []flex.FlexStatement, from, to string) *SummaryRe
 	}
 
 	// 已实现盈亏（从 Trades）
-	pnl := AnalyzePnL(statements, from, to)
+	pnl := AnalyzePnL(statements, from, to, opts)
 	report.TotalRealPnL = pnl.TotalPnL
 
 	report.AccountValue = report.TotalValue + report.CashBalance
+	report.Benchmarks = CompareBenchmarks(statements, from, to, opts.Benchmarks)
 
 	return report
 }
 
 func PrintSummaryReport(r *SummaryReport) {
-	fmt.Println("═══ 账户综合汇总 ═══")
+	printTitle("账户综合汇总")
 	fmt.Println()
-	fmt.Printf("账户总值:       %.2f\n", r.AccountValue)
-	fmt.Printf("  持仓市值:     %.2f\n", r.TotalValue)
-	fmt.Printf("  现金余额:     %.2f\n", r.CashBalance)
+	i18n.Printf("账户总值:       %s\n", fmtMoney(r.AccountValue))
+	i18n.Printf("  持仓市值:     %s\n", fmtMoney(r.TotalValue))
+	i18n.Printf("  现金余额:     %s\n", fmtMoney(r.CashBalance))
 	fmt.Println()
-	fmt.Printf("未实现盈亏:     %.2f\n", r.TotalUnrealPnL)
-	fmt.Printf("已实现盈亏:     %.2f\n", r.TotalRealPnL)
-	fmt.Printf("净股息收入:     %.2f\n", r.TotalDivNet)
-	fmt.Printf("总佣金:         %.2f\n", r.TotalCommission)
-	fmt.Printf("总入金:         %.2f\n", r.TotalDeposits)
-	fmt.Printf("总出金:         %.2f\n", r.TotalWithdrawals)
+	i18n.Printf("未实现盈亏:     %s\n", fmtMoney(r.TotalUnrealPnL))
+	i18n.Printf("已实现盈亏:     %s\n", fmtMoney(r.TotalRealPnL))
+	i18n.Printf("净股息收入:     %s\n", fmtMoney(r.TotalDivNet))
+	i18n.Printf("总佣金:         %s\n", fmtMoney(r.TotalCommission))
+	i18n.Printf("总入金:         %s\n", fmtMoney(r.TotalDeposits))
+	i18n.Printf("总出金:         %s\n", fmtMoney(r.TotalWithdrawals))
 	fmt.Println()
 
 	if len(r.Positions) > 0 {
-		fmt.Println("── 当前持仓 ──")
+		printSection("当前持仓")
 		printTable(
 			[]string{"标的", "数量", "现价", "成本价", "市值", "未实现P&L"},
 			func() [][]string {
@@ -117,14 +131,47 @@ func PrintSummaryReport(r *SummaryReport) {
 					rows = append(rows, []string{
 						p.Symbol,
 						fmt.Sprintf("%.0f", p.Position),
-						fmt.Sprintf("%.2f", p.MarkPrice),
-						fmt.Sprintf("%.2f", p.CostBasis),
-						fmt.Sprintf("%.2f", p.PositionValue),
-						fmt.Sprintf("%.2f", p.UnrealizedPnL),
+						fmtMoney(p.MarkPrice),
+						fmtMoney(p.CostBasis),
+						fmtMoney(p.PositionValue),
+						fmtMoney(p.UnrealizedPnL),
 					})
 				}
 				return rows
 			}(),
 		)
 	}
+
+	if len(r.Benchmarks) > 0 {
+		printBenchmarks(r.Benchmarks)
+	}
+}
+
+// Tables 导出用的表：汇总、当前持仓，配置了基准时加上基准对比
+func (r *SummaryReport) Tables() []Table {
+	positions := newTable("positions", "当前持仓", "标的", "类别", "币种", "数量", "现价", "成本价", "市值", "未实现P&L")
+	for _, p := range r.Positions {
+		positions.Rows = append(positions.Rows, []any{
+			p.Symbol, p.Category, p.Currency, p.Position, p.MarkPrice, p.CostBasis, p.PositionValue, p.UnrealizedPnL,
+		})
+	}
+
+	tables := []Table{
+		totalsTable(
+			[]any{"账户总值", r.AccountValue},
+			[]any{"持仓市值", r.TotalValue},
+			[]any{"现金余额", r.CashBalance},
+			[]any{"未实现盈亏", r.TotalUnrealPnL},
+			[]any{"已实现盈亏", r.TotalRealPnL},
+			[]any{"净股息收入", r.TotalDivNet},
+			[]any{"总佣金", r.TotalCommission},
+			[]any{"总入金", r.TotalDeposits},
+			[]any{"总出金", r.TotalWithdrawals},
+		),
+		positions,
+	}
+	if len(r.Benchmarks) > 0 {
+		tables = append(tables, benchmarkTables(r.Benchmarks)...)
+	}
+	return tables
 }
@@ -0,0 +1,192 @@
+package analysis
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+// chartPoint 图表中的一个数据点
+type chartPoint struct {
+	Label string
+	Value float64
+}
+
+const (
+	chartWidth    = 640
+	chartHeight   = 260
+	chartPadLeft  = 70
+	chartPadTop   = 16
+	chartPadBot   = 40
+	chartPadRight = 16
+)
+
+var chartPalette = []string{
+	"#4e79a7", "#f28e2b", "#59a14f", "#e15759", "#76b7b2",
+	"#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac",
+}
+
+// chartScale 计算 y 轴范围（始终包含 0）
+func chartScale(points []chartPoint) (lo, hi float64) {
+	for _, p := range points {
+		lo = math.Min(lo, p.Value)
+		hi = math.Max(hi, p.Value)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	pad := (hi - lo) * 0.05
+	if hi > 0 {
+		hi += pad
+	}
+	if lo < 0 {
+		lo -= pad
+	}
+	return lo, hi
+}
+
+// chartAxes 画坐标轴、网格线和 x 轴标签，返回 y 坐标换算函数
+func chartAxes(b *strings.Builder, points []chartPoint, lo, hi float64, xAt func(i int) float64) func(float64) float64 {
+	plotH := float64(chartHeight - chartPadTop - chartPadBot)
+	y := func(v float64) float64 {
+		return chartPadTop + (hi-v)/(hi-lo)*plotH
+	}
+
+	for i := 0; i <= 4; i++ {
+		v := lo + (hi-lo)*float64(i)/4
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eee"/>`,
+			chartPadLeft, y(v), chartWidth-chartPadRight, y(v))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" font-size="11" text-anchor="end" fill="#666">%s</text>`,
+			chartPadLeft-6, y(v)+4, fmtMoney(v))
+	}
+	fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#999"/>`,
+		chartPadLeft, y(0), chartWidth-chartPadRight, y(0))
+
+	step := int(math.Ceil(float64(len(points)) / 12))
+	if step < 1 {
+		step = 1
+	}
+	for i := 0; i < len(points); i += step {
+		fmt.Fprintf(b, `<text x="%.1f" y="%d" font-size="11" text-anchor="middle" fill="#666">%s</text>`,
+			xAt(i), chartHeight-chartPadBot+16, html.EscapeString(points[i].Label))
+	}
+	return y
+}
+
+// svgLineChart 折线图
+func svgLineChart(points []chartPoint, color string) string {
+	if len(points) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" width="100%%" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+
+	lo, hi := chartScale(points)
+	plotW := float64(chartWidth - chartPadLeft - chartPadRight)
+	xAt := func(i int) float64 {
+		if len(points) == 1 {
+			return chartPadLeft + plotW/2
+		}
+		return chartPadLeft + plotW*float64(i)/float64(len(points)-1)
+	}
+	y := chartAxes(&b, points, lo, hi, xAt)
+
+	var path []string
+	for i, p := range points {
+		path = append(path, fmt.Sprintf("%.1f,%.1f", xAt(i), y(p.Value)))
+	}
+	fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(path, " "))
+	for i, p := range points {
+		fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s: %s</title></circle>`,
+			xAt(i), y(p.Value), color, html.EscapeString(p.Label), fmtPnL(p.Value))
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// svgBarChart 柱状图，正值绿色、负值红色
+func svgBarChart(points []chartPoint) string {
+	if len(points) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" width="100%%" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+
+	lo, hi := chartScale(points)
+	slot := float64(chartWidth-chartPadLeft-chartPadRight) / float64(len(points))
+	xAt := func(i int) float64 {
+		return chartPadLeft + slot*(float64(i)+0.5)
+	}
+	y := chartAxes(&b, points, lo, hi, xAt)
+
+	barW := slot * 0.7
+	for i, p := range points {
+		color := "#59a14f"
+		if p.Value < 0 {
+			color = "#e15759"
+		}
+		top, bottom := y(math.Max(p.Value, 0)), y(math.Min(p.Value, 0))
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`,
+			xAt(i)-barW/2, top, barW, math.Max(bottom-top, 0.5), color, html.EscapeString(p.Label), fmtPnL(p.Value))
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// svgDonut 环形图，负值和 0 忽略
+func svgDonut(points []chartPoint) string {
+	var total float64
+	for _, p := range points {
+		if p.Value > 0 {
+			total += p.Value
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	const size, r, inner = 260.0, 110.0, 65.0
+	cx, cy := size/2, size/2
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %.0f %.0f" width="100%%" xmlns="http://www.w3.org/2000/svg">`, size+280, size)
+
+	angle := -math.Pi / 2
+	slice := 0
+	for _, p := range points {
+		if p.Value <= 0 {
+			continue
+		}
+		color := chartPalette[slice%len(chartPalette)]
+		frac := p.Value / total
+		title := fmt.Sprintf("%s: %s (%.1f%%)", html.EscapeString(p.Label), fmtMoney(p.Value), frac*100)
+
+		if frac > 0.9999 {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="none" stroke="%s" stroke-width="%.1f"><title>%s</title></circle>`,
+				cx, cy, (r+inner)/2, color, r-inner, title)
+		} else {
+			end := angle + frac*2*math.Pi
+			large := 0
+			if frac > 0.5 {
+				large = 1
+			}
+			fmt.Fprintf(&b, `<path d="M %.2f %.2f A %.0f %.0f 0 %d 1 %.2f %.2f L %.2f %.2f A %.0f %.0f 0 %d 0 %.2f %.2f Z" fill="%s"><title>%s</title></path>`,
+				cx+r*math.Cos(angle), cy+r*math.Sin(angle),
+				r, r, large, cx+r*math.Cos(end), cy+r*math.Sin(end),
+				cx+inner*math.Cos(end), cy+inner*math.Sin(end),
+				inner, inner, large, cx+inner*math.Cos(angle), cy+inner*math.Sin(angle),
+				color, title)
+			angle = end
+		}
+
+		// 图例
+		ly := 20 + float64(slice)*20
+		if slice < 12 {
+			fmt.Fprintf(&b, `<rect x="%.0f" y="%.0f" width="12" height="12" fill="%s"/>`, size+20, ly, color)
+			fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" font-size="12" fill="#333">%s</text>`, size+38, ly+10, title)
+		}
+		slice++
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
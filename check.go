@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/analysis"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+	"github.com/spf13/cobra"
+)
+
+// errRiskViolations check 发现超限时返回，进程以 2 退出，与运行出错（1）区分
+var errRiskViolations = errors.New("risk limits violated")
+
+func checkCmd() *cobra.Command {
+	var webhook string
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: i18n.T("按 [risk] 配置的限额检查最新数据，有超限时以退出码 2 退出并可通知 webhook"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+			statements, err := loadLatestData(cfg)
+			if err != nil {
+				return err
+			}
+
+			r := analysis.CheckRisk(statements, cfg.Risk.RiskRules, cfg.Analysis)
+			if err := writeReport("check", r, func() { analysis.PrintRiskReport(r) }, statements, "", "", flagFormat, cfg.DataDir); err != nil {
+				return err
+			}
+			if r.Violations == 0 {
+				return nil
+			}
+
+			if webhook == "" {
+				webhook = cfg.Risk.Webhook
+			}
+			if webhook != "" {
+				// 通知失败不能掩盖超限结果：记录到 stderr，仍以退出码 2 退出
+				if err := postWebhook(webhook, r.Summary(), analysis.NewJSONEnvelope("check", statements, "", "", r)); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				} else {
+					i18n.Fprintf(os.Stderr, "✓ 已通知 webhook\n")
+				}
+			}
+			cmd.SilenceUsage, cmd.SilenceErrors = true, true
+			return errRiskViolations
+		},
+	}
+	cmd.Flags().StringVar(&webhook, "webhook", "", i18n.T("有超限时 POST 检查结果的 URL，默认取配置 risk.webhook"))
+	cmd.Flags().StringVarP(&flagOutput, "output", "o", "", i18n.T("导出路径：csv 为目录，xlsx 为文件（默认保存到 data 目录）"))
+	return cmd
+}
+
+// webhookPayload 发送到 webhook 的 JSON：text 为一行概括（Slack 等可直接显示），其余同 --format json
+type webhookPayload struct {
+	Text string `json:"text"`
+	*analysis.JSONEnvelope
+}
+
+func postWebhook(url, text string, envelope *analysis.JSONEnvelope) error {
+	body, err := json.Marshal(webhookPayload{Text: text, JSONEnvelope: envelope})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return i18n.Errorf("通知 webhook 失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return i18n.Errorf("通知 webhook 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return i18n.Errorf("通知 webhook 失败: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/solarhell/ibkr-finance-analysis/analysis"
+)
+
+func TestPostWebhook(t *testing.T) {
+	var got map[string]any
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		contentType = r.Header.Get("Content-Type")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	envelope := &analysis.JSONEnvelope{SchemaVersion: 1, Kind: "check", Currency: "USD", Data: map[string]int{"violations": 2}}
+	if err := postWebhook(srv.URL, "2 violations", envelope); err != nil {
+		t.Fatalf("postWebhook: %v", err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if got["text"] != "2 violations" || got["kind"] != "check" || got["currency"] != "USD" {
+		t.Errorf("payload = %v", got)
+	}
+	if data, _ := got["data"].(map[string]any); data["violations"] != float64(2) {
+		t.Errorf("payload data = %v", got["data"])
+	}
+}
+
+func TestPostWebhookErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	envelope := &analysis.JSONEnvelope{SchemaVersion: 1, Kind: "check"}
+	if err := postWebhook(srv.URL, "x", envelope); err == nil {
+		t.Error("HTTP 500: want error")
+	}
+
+	srv.Close() // 关闭后连接被拒绝
+	if err := postWebhook(srv.URL, "x", envelope); err == nil {
+		t.Error("closed server: want error")
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/solarhell/ibkr-finance-analysis/analysis"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 	"github.com/spf13/viper"
 )
 
@@ -12,38 +15,199 @@ type Config struct {
 	Token   string            `mapstructure:"token"`
 	Queries map[string]string `mapstructure:"queries"`
 	DataDir string            `mapstructure:"data_dir"`
+	Lang    string            `mapstructure:"lang"`
+
+	// 已实现盈亏来源：computed（FIFO 重算）或 ibkr（fifoPnlRealized），--pnl-source 优先
+	PnLSource string `mapstructure:"pnl_source"`
+
+	// 期初批次（历史交易不全时 FIFO 的起点）：手工维护的 CSV，或较早快照的 OpenPositions，二选一
+	LotsFile     string `mapstructure:"lots_file"`
+	LotsSnapshot string `mapstructure:"lots_snapshot"`
+
+	// 证券主数据 CSV（symbol,sector,country[,isin]），资产配置和风险检查按行业、国家/地区分组时使用
+	SecurityMaster string `mapstructure:"security_master"`
+
+	// 基准指数的本地价格文件，汇总报告与之对比
+	Benchmarks []BenchmarkConfig `mapstructure:"benchmarks"`
+
+	// 资产配置的目标权重和调仓约束
+	Allocation analysis.AllocationConfig `mapstructure:"allocation"`
+
+	// 记账导出的账户名，未配置的使用 analysis.DefaultJournalAccounts
+	Accounts analysis.JournalAccounts `mapstructure:"accounts"`
+
+	Daemon DaemonConfig `mapstructure:"daemon"`
+	Risk   RiskConfig   `mapstructure:"risk"`
+
+	// 由以上配置和命令行参数构建，传给各项分析
+	Analysis analysis.Options `mapstructure:"-"`
 }
 
-func LoadConfig() (*Config, error) {
+// BenchmarkConfig 一个基准：名称、收盘价 CSV（date,close 或 Adj Close，相对路径基于当前目录）和价格币种
+type BenchmarkConfig struct {
+	Name     string `mapstructure:"name"`
+	File     string `mapstructure:"file"`
+	Currency string `mapstructure:"currency"` // 与基础货币不同时按快照中的汇率折算，为空时视为基础货币
+}
+
+// RiskConfig ibkr check 的风险限额和通知地址
+type RiskConfig struct {
+	analysis.RiskRules `mapstructure:",squash"`
+	Webhook            string `mapstructure:"webhook"` // 有超限时 POST 检查结果
+}
+
+// DaemonConfig ibkr daemon 的调度、重试和快照保留策略
+type DaemonConfig struct {
+	Schedule          string        `mapstructure:"schedule"`            // cron 表达式（分 时 日 月 周），本地时区
+	Retries           int           `mapstructure:"retries"`             // 拉取失败后的重试次数
+	RetryBackoff      time.Duration `mapstructure:"retry_backoff"`       // 第一次重试前的等待时间，之后每次翻倍
+	KeepDays          int           `mapstructure:"keep_days"`           // 删除早于此天数的快照，0 为不删除
+	CompressAfterDays int           `mapstructure:"compress_after_days"` // gzip 压缩早于此天数的快照，0 为不压缩
+	LogFile           string        `mapstructure:"log_file"`            // 运行日志，相对路径基于 data_dir
+}
+
+func setupViper() {
 	viper.SetConfigName("config")
 	viper.SetConfigType("toml")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("$HOME/.ibkr")
+}
+
+// configLang 读取配置中的 lang；配置文件缺失或无效时返回空串，不报错
+func configLang() string {
+	setupViper()
+	if err := viper.ReadInConfig(); err != nil {
+		return ""
+	}
+	return viper.GetString("lang")
+}
+
+func LoadConfig() (*Config, error) {
+	setupViper()
 
 	viper.SetDefault("data_dir", "./data")
+	viper.SetDefault("daemon.schedule", "0 8 * * 2-6")
+	viper.SetDefault("daemon.retries", 3)
+	viper.SetDefault("daemon.retry_backoff", "5m")
+	viper.SetDefault("daemon.compress_after_days", 30)
+	viper.SetDefault("daemon.log_file", "daemon.log")
 
 	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		return nil, i18n.Errorf("读取配置文件失败: %w", err)
 	}
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
-		return nil, fmt.Errorf("解析配置失败: %w", err)
+		return nil, i18n.Errorf("解析配置失败: %w", err)
 	}
 
 	if cfg.Token == "" {
-		return nil, fmt.Errorf("配置缺少 token")
+		return nil, i18n.Errorf("配置缺少 token")
 	}
 	if len(cfg.Queries) == 0 {
-		return nil, fmt.Errorf("配置缺少 queries")
+		return nil, i18n.Errorf("配置缺少 queries")
 	}
 
 	// 确保数据目录存在
 	absDir, _ := filepath.Abs(cfg.DataDir)
 	cfg.DataDir = absDir
 	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建数据目录失败: %w", err)
+		return nil, i18n.Errorf("创建数据目录失败: %w", err)
+	}
+
+	if flagPnLSource != "" {
+		cfg.PnLSource = flagPnLSource
+	}
+	cfg.Analysis = analysis.Options{PnLSource: cfg.PnLSource, Allocation: cfg.Allocation}
+	if err := cfg.Analysis.Validate(); err != nil {
+		return nil, err
+	}
+	var err error
+	if cfg.Analysis.OpeningLots, err = loadOpeningLots(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Analysis.Securities, err = loadSecurityMaster(cfg.SecurityMaster); err != nil {
+		return nil, err
+	}
+	if cfg.Analysis.Benchmarks, err = loadBenchmarks(cfg.Benchmarks); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
 }
+
+// loadOpeningLots 读取 lots_file 或 lots_snapshot 指定的期初批次（--lots-file、--lots-from 优先），都未设置时返回 nil
+func loadOpeningLots(cfg *Config) (*analysis.OpeningLots, error) {
+	// 命令行指定了任一来源时替换配置中的两项
+	if flagLotsFile != "" || flagLotsFrom != "" {
+		cfg.LotsFile, cfg.LotsSnapshot = flagLotsFile, flagLotsFrom
+	}
+
+	var (
+		lots   *analysis.OpeningLots
+		source string
+	)
+	switch {
+	case cfg.LotsFile != "" && cfg.LotsSnapshot != "":
+		return nil, i18n.Errorf("lots_file 和 lots_snapshot 只能设置一个")
+	case cfg.LotsFile != "":
+		f, err := os.Open(cfg.LotsFile)
+		if err != nil {
+			return nil, i18n.Errorf("读取批次文件失败: %w", err)
+		}
+		defer f.Close()
+		if lots, err = analysis.ReadLotsCSV(f); err != nil {
+			return nil, err
+		}
+		source = cfg.LotsFile
+	case cfg.LotsSnapshot != "":
+		path := snapshotPath(cfg.DataDir, cfg.LotsSnapshot)
+		statements, err := loadSnapshot(path)
+		if err != nil {
+			return nil, err
+		}
+		lots = analysis.OpeningLotsFromStatements(statements)
+		source = filepath.Base(path)
+	default:
+		return nil, nil
+	}
+
+	i18n.Fprintf(os.Stderr, "期初批次: %d 个 (来自 %s)\n", len(lots.Lots), source)
+	return lots, nil
+}
+
+// loadSecurityMaster 读取 security_master 指定的证券主数据，未配置时不按行业、国家/地区分组
+func loadSecurityMaster(path string) (*analysis.SecurityMaster, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, i18n.Errorf("读取证券主数据失败: %w", err)
+	}
+	defer f.Close()
+	return analysis.ReadSecurityMaster(f)
+}
+
+// loadBenchmarks 读取 [[benchmarks]] 的价格文件
+func loadBenchmarks(configs []BenchmarkConfig) ([]*analysis.PriceSeries, error) {
+	var series []*analysis.PriceSeries
+	for _, b := range configs {
+		name := b.Name
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(b.File), filepath.Ext(b.File))
+		}
+		f, err := os.Open(b.File)
+		if err != nil {
+			return nil, i18n.Errorf("读取基准价格失败: %s: %w", name, err)
+		}
+		p, err := analysis.ReadPriceCSV(name, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		p.Currency = strings.ToUpper(b.Currency)
+		series = append(series, p)
+	}
+	return series, nil
+}
@@ -0,0 +1,182 @@
+// Package cron 解析标准五段 cron 表达式（分 时 日 月 周），计算下一次/上一次触发时间。
+// 支持 *、数字、范围 a-b、步长 */n 与 a-b/n、逗号列表，月份和星期可用英文缩写，星期 0 和 7 均为周日。
+// 日和周同时受限时按 cron 的惯例取并集
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 解析后的表达式，各字段为允许值的位图
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	domAny, dowAny bool // 日/周为 *，用于判断两者取交集还是并集
+}
+
+type field struct {
+	min, max int
+	names    []string // names[i] 对应 min+i
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dowField    = field{min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// Parse 解析 cron 表达式，也接受 @hourly、@daily、@weekly、@monthly
+func Parse(spec string) (*Schedule, error) {
+	switch strings.TrimSpace(spec) {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d in %q", len(parts), spec)
+	}
+
+	s := &Schedule{domAny: parts[2] == "*", dowAny: parts[4] == "*"}
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		def  field
+	}{
+		{&s.minute, minuteField},
+		{&s.hour, hourField},
+		{&s.dom, domField},
+		{&s.month, monthField},
+		{&s.dow, dowField},
+	} {
+		if *f.bits, err = parseField(parts[i], f.def); err != nil {
+			return nil, fmt.Errorf("cron: %q: %w", spec, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 也是周日
+	}
+	return s, nil
+}
+
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for item := range strings.SplitSeq(expr, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", item)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// matchDay 日和周都受限时任一满足即可，只有一个受限时以受限的为准
+func (s *Schedule) matchDay(t time.Time) bool {
+	domOK := s.dom&(1<<t.Day()) != 0
+	dowOK := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+func (s *Schedule) match(t time.Time) bool {
+	return s.minute&(1<<t.Minute()) != 0 &&
+		s.hour&(1<<t.Hour()) != 0 &&
+		s.month&(1<<int(t.Month())) != 0 &&
+		s.matchDay(t)
+}
+
+// searchLimit 超过此范围仍无匹配（如 2 月 30 日）时返回零值
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+// Next 严格晚于 t 的下一次触发时间（精确到分钟，使用 t 的时区）
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(searchLimit)
+	for t.Before(end) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			// 不用 Truncate(time.Hour)：它按绝对时间截断，在 +05:30 这类半点时区会落到整点之外
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Prev 不晚于 t 的上一次触发时间，用于补跑错过的任务；在 within 范围内没有时返回零值
+func (s *Schedule) Prev(t time.Time, within time.Duration) time.Time {
+	t = t.Truncate(time.Minute)
+	for end := t.Add(-within); !t.Before(end); t = t.Add(-time.Minute) {
+		if s.match(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
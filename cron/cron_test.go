@@ -0,0 +1,174 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("时区数据不可用: %v", err)
+	}
+	return loc
+}
+
+func TestNext(t *testing.T) {
+	kolkata := mustLocation(t, "Asia/Kolkata")     // +05:30
+	kathmandu := mustLocation(t, "Asia/Kathmandu") // +05:45
+	shanghai := mustLocation(t, "Asia/Shanghai")
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "half-hour offset zone",
+			spec: "0 11 * * *",
+			from: time.Date(2025, 1, 15, 10, 17, 0, 0, kolkata),
+			want: time.Date(2025, 1, 15, 11, 0, 0, 0, kolkata),
+		},
+		{
+			name: "quarter-hour offset zone",
+			spec: "30 9 * * *",
+			from: time.Date(2025, 1, 15, 8, 50, 0, 0, kathmandu),
+			want: time.Date(2025, 1, 15, 9, 30, 0, 0, kathmandu),
+		},
+		{
+			name: "next day in local zone",
+			spec: "0 8 * * *",
+			from: time.Date(2025, 1, 15, 8, 0, 0, 0, shanghai),
+			want: time.Date(2025, 1, 16, 8, 0, 0, 0, shanghai),
+		},
+		{
+			name: "strictly after from",
+			spec: "*/15 * * * *",
+			from: time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "step rolls over the hour",
+			spec: "*/20 * * * *",
+			from: time.Date(2025, 1, 15, 10, 41, 30, 0, time.UTC),
+			want: time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "range with step",
+			spec: "0 9-17/4 * * *",
+			from: time.Date(2025, 1, 15, 13, 1, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 15, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "dom and dow union picks the earlier weekday",
+			spec: "0 0 25 * mon",
+			from: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), // 周三
+			want: time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), // 周一早于 25 日
+		},
+		{
+			name: "dom and dow union picks the earlier dom",
+			spec: "0 0 17 * mon",
+			from: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "only dow restricted",
+			spec: "0 0 * * fri",
+			from: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "7 is sunday",
+			spec: "0 6 * * 7",
+			from: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 19, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "0 is sunday",
+			spec: "0 6 * * 0",
+			from: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 19, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "month names",
+			spec: "0 0 1 mar *",
+			from: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "never matches",
+			spec: "0 0 30 feb *",
+			from: time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+			want: time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrev(t *testing.T) {
+	kolkata := mustLocation(t, "Asia/Kolkata")
+	s, err := Parse("0 11 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2025, 1, 15, 10, 17, 0, 0, kolkata)
+	want := time.Date(2025, 1, 14, 11, 0, 0, 0, kolkata)
+	if got := s.Prev(from, 48*time.Hour); !got.Equal(want) {
+		t.Errorf("Prev = %v, want %v", got, want)
+	}
+	if got := s.Prev(from, time.Hour); !got.IsZero() {
+		t.Errorf("Prev within 1h = %v, want zero", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestParseShorthands(t *testing.T) {
+	from := time.Date(2025, 1, 15, 10, 17, 0, 0, time.UTC) // 周三
+	tests := map[string]time.Time{
+		"@hourly":  time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC),
+		"@daily":   time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC),
+		"@weekly":  time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC),
+		"@monthly": time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for spec, want := range tests {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", spec, err)
+		}
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("%s: Next = %v, want %v", spec, got, want)
+		}
+	}
+}
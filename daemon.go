@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/cron"
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+	"github.com/spf13/cobra"
+)
+
+// catchUpWindow 启动时回看多久内错过的调度
+const catchUpWindow = 7 * 24 * time.Hour
+
+func daemonCmd() *cobra.Command {
+	var once bool
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: i18n.T("按 [daemon] 配置的计划定时拉取数据，并按保留策略清理旧快照"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+			sched, err := cron.Parse(cfg.Daemon.Schedule)
+			if err != nil {
+				return i18n.Errorf("daemon.schedule 无效: %w", err)
+			}
+
+			logPath := cfg.Daemon.LogFile
+			if !filepath.IsAbs(logPath) {
+				logPath = filepath.Join(cfg.DataDir, logPath)
+			}
+			logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				return i18n.Errorf("打开运行日志失败: %w", err)
+			}
+			defer logFile.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			d := &daemon{cfg: cfg, client: flex.NewClient(cfg.Token), log: json.NewEncoder(logFile)}
+			if once {
+				return d.run(ctx, time.Now())
+			}
+
+			// 补跑：停机期间错过的最近一次调度，数据不存在时立即拉取
+			if prev := sched.Prev(time.Now(), catchUpWindow); !prev.IsZero() {
+				d.run(ctx, prev)
+			}
+			for {
+				next := sched.Next(time.Now())
+				if next.IsZero() {
+					return i18n.Errorf("daemon.schedule 无效: %s 不会触发", cfg.Daemon.Schedule)
+				}
+				i18n.Printf("下次运行: %s\n", i18n.DateTime(next))
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(time.Until(next)):
+				}
+				d.run(ctx, next)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&once, "once", false, i18n.T("立即执行一次后退出（可由系统 cron/systemd 调度）"))
+	return cmd
+}
+
+type daemon struct {
+	cfg    *Config
+	client *flex.Client
+	log    *json.Encoder
+}
+
+// runLogEntry 运行日志的一行（JSON Lines）
+type runLogEntry struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"` // fetched、skipped、failed、pruned、compressed
+	Query   string    `json:"query,omitempty"`
+	File    string    `json:"file,omitempty"`
+	Attempt int       `json:"attempt,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func (d *daemon) record(e runLogEntry) {
+	e.Time = time.Now().Truncate(time.Second)
+	if err := d.log.Encode(e); err != nil {
+		i18n.Fprintf(os.Stderr, "写入运行日志失败: %v\n", err)
+	}
+}
+
+// run 执行一次调度：拉取 at 当天还没有数据的 query，然后应用保留策略。
+// 有 query 最终失败时返回错误（--once 时作为退出码），常驻模式下只记录日志
+func (d *daemon) run(ctx context.Context, at time.Time) error {
+	i18n.Printf("── 运行 %s ──\n", i18n.DateTime(at))
+	names := make([]string, 0, len(d.cfg.Queries))
+	for name := range d.cfg.Queries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var failed []string
+	for _, name := range names {
+		if latest := latestDataFile(d.cfg.DataDir, name); latest != "" {
+			if t, ok := snapshotTime(latest, name); ok && t.Format("20060102") >= at.Format("20060102") {
+				i18n.Printf("跳过 %s: 已有 %s 的数据 (%s)\n", name, at.Format("2006-01-02"), filepath.Base(latest))
+				d.record(runLogEntry{Event: "skipped", Query: name, File: filepath.Base(latest)})
+				continue
+			}
+		}
+		if !d.fetch(ctx, name) {
+			failed = append(failed, name)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	d.applyRetention()
+	if len(failed) > 0 {
+		return i18n.Errorf("拉取失败: %v", failed)
+	}
+	return nil
+}
+
+// fetch 拉取一个 query，失败时按 retry_backoff 指数退避重试
+func (d *daemon) fetch(ctx context.Context, name string) bool {
+	backoff := d.cfg.Daemon.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		_, rawXML, err := d.client.FetchQuery(d.cfg.Queries[name])
+		var filename string
+		if err == nil {
+			filename, err = saveSnapshot(d.cfg.DataDir, name, rawXML)
+		}
+		if err == nil {
+			i18n.Printf("✓ %s: 已保存到 %s\n", name, filename)
+			d.record(runLogEntry{Event: "fetched", Query: name, File: filename, Attempt: attempt})
+			return true
+		}
+
+		i18n.Fprintf(os.Stderr, "拉取 %s 失败（第 %d 次）: %v\n", name, attempt, err)
+		d.record(runLogEntry{Event: "failed", Query: name, Attempt: attempt, Error: err.Error()})
+		if attempt > d.cfg.Daemon.Retries {
+			return false
+		}
+		i18n.Printf("%s 后重试\n", backoff)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// applyRetention 删除超过 keep_days 的快照，压缩超过 compress_after_days 的快照；每个 query 最新的快照始终保留
+func (d *daemon) applyRetention() {
+	policy := d.cfg.Daemon
+	if policy.KeepDays <= 0 && policy.CompressAfterDays <= 0 {
+		return
+	}
+	now := time.Now()
+	olderThan := func(t time.Time, days int) bool {
+		return days > 0 && now.Sub(t) > time.Duration(days)*24*time.Hour
+	}
+
+	for name := range d.cfg.Queries {
+		files := snapshotFiles(d.cfg.DataDir, name)
+		for _, path := range files[:max(len(files)-1, 0)] {
+			t, _ := snapshotTime(path, name)
+			switch {
+			case olderThan(t, policy.KeepDays):
+				if err := os.Remove(path); err != nil {
+					d.record(runLogEntry{Event: "pruned", Query: name, File: filepath.Base(path), Error: err.Error()})
+					continue
+				}
+				d.record(runLogEntry{Event: "pruned", Query: name, File: filepath.Base(path)})
+			case olderThan(t, policy.CompressAfterDays) && filepath.Ext(path) == ".xml":
+				gzPath, err := compressSnapshot(path)
+				if err != nil {
+					d.record(runLogEntry{Event: "compressed", Query: name, File: filepath.Base(path), Error: err.Error()})
+					continue
+				}
+				d.record(runLogEntry{Event: "compressed", Query: name, File: filepath.Base(gzPath)})
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/solarhell/ibkr-finance-analysis/analysis"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+	"github.com/spf13/cobra"
+)
+
+func diffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [old new]",
+		Short: i18n.T("比较两次拉取的快照：新增/删除/被更正的记录、持仓和现金变化"),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return i18n.Errorf("需要两个快照文件，或不带参数比较最近两次拉取")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+
+			var oldPath, newPath string
+			if len(args) == 2 {
+				oldPath, newPath = snapshotPath(cfg.DataDir, args[0]), snapshotPath(cfg.DataDir, args[1])
+			} else {
+				name := flagQuery
+				if name == "" {
+					if len(cfg.Queries) > 1 {
+						return i18n.Errorf("配置了多个 query，请用 --query 指定 (可用: %s)", availableQueries(cfg))
+					}
+					for n := range cfg.Queries {
+						name = n
+					}
+				}
+				if _, ok := cfg.Queries[name]; !ok {
+					return i18n.Errorf("未找到 query: %s (可用: %s)", name, availableQueries(cfg))
+				}
+				files := snapshotFiles(cfg.DataDir, name)
+				if len(files) < 2 {
+					return i18n.Errorf("%s 只有 %d 个快照，至少需要两个", name, len(files))
+				}
+				oldPath, newPath = files[len(files)-2], files[len(files)-1]
+			}
+
+			oldStatements, err := loadSnapshot(oldPath)
+			if err != nil {
+				return err
+			}
+			newStatements, err := loadSnapshot(newPath)
+			if err != nil {
+				return err
+			}
+			i18n.Fprintf(os.Stderr, "比较 %s → %s\n", filepath.Base(oldPath), filepath.Base(newPath))
+
+			d := analysis.DiffSnapshots(oldStatements, newStatements)
+			return writeReport("diff", d, func() { analysis.PrintSnapshotDiff(d) }, newStatements, "", "", flagFormat, cfg.DataDir)
+		},
+	}
+	cmd.Flags().StringVarP(&flagQuery, "query", "q", "", i18n.T("不带参数时比较此 query 最近两次拉取的快照"))
+	cmd.Flags().StringVarP(&flagOutput, "output", "o", "", i18n.T("导出路径：csv 为目录，xlsx 为文件（默认保存到 data 目录）"))
+	return cmd
+}
+
+// snapshotPath 参数可以是路径，也可以是 data 目录下的文件名
+func snapshotPath(dataDir, arg string) string {
+	if _, err := os.Stat(arg); err == nil || filepath.IsAbs(arg) {
+		return arg
+	}
+	return filepath.Join(dataDir, arg)
+}
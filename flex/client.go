@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 )
 
 const (
@@ -40,28 +43,28 @@ func (c *Client) SendRequest(queryID string) (string, error) {
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %w", err)
+		return "", i18n.Errorf("创建请求失败: %w", err)
 	}
 	req.Header.Set("User-Agent", userAgent)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return "", i18n.Errorf("发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return "", i18n.Errorf("读取响应失败: %w", err)
 	}
 
 	var result SendRequestResponse
 	if err := xml.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
+		return "", i18n.Errorf("解析响应失败: %w", err)
 	}
 
 	if result.Status != "Success" {
-		return "", fmt.Errorf("API 错误 [%d]: %s", result.ErrorCode, result.ErrorMessage)
+		return "", i18n.Errorf("API 错误 [%d]: %s", result.ErrorCode, result.ErrorMessage)
 	}
 
 	return result.ReferenceCode, nil
@@ -77,62 +80,62 @@ func (c *Client) GetStatement(referenceCode string) (*FlexQueryResponse, []byte,
 
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
-			return nil, nil, fmt.Errorf("创建请求失败: %w", err)
+			return nil, nil, i18n.Errorf("创建请求失败: %w", err)
 		}
 		req.Header.Set("User-Agent", userAgent)
 
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
-			return nil, nil, fmt.Errorf("发送请求失败: %w", err)
+			return nil, nil, i18n.Errorf("发送请求失败: %w", err)
 		}
 
 		body, err := io.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
-			return nil, nil, fmt.Errorf("读取响应失败: %w", err)
+			return nil, nil, i18n.Errorf("读取响应失败: %w", err)
 		}
 
 		// 先尝试解析为错误响应（FlexStatementResponse）
 		var errResp SendRequestResponse
 		if xml.Unmarshal(body, &errResp) == nil && errResp.XMLName.Local == "FlexStatementResponse" {
 			if errResp.ErrorCode == errStillGenerating {
-				fmt.Printf("  报表生成中，等待重试 (%d/%d)...\n", i+1, maxRetries)
+				i18n.Fprintf(os.Stderr, "  报表生成中，等待重试 (%d/%d)...\n", i+1, maxRetries)
 				time.Sleep(2 * time.Second)
 				continue
 			}
 			if errResp.ErrorCode == errRateLimit {
-				fmt.Println("  触发速率限制，等待 10 秒...")
+				i18n.Fprintf(os.Stderr, "  触发速率限制，等待 10 秒...\n")
 				time.Sleep(10 * time.Second)
 				continue
 			}
 			if errResp.Status != "Success" {
-				return nil, nil, fmt.Errorf("API 错误 [%d]: %s", errResp.ErrorCode, errResp.ErrorMessage)
+				return nil, nil, i18n.Errorf("API 错误 [%d]: %s", errResp.ErrorCode, errResp.ErrorMessage)
 			}
 		}
 
 		// 解析为完整的 FlexQueryResponse
 		var result FlexQueryResponse
 		if err := xml.Unmarshal(body, &result); err != nil {
-			return nil, nil, fmt.Errorf("解析报表失败: %w", err)
+			return nil, nil, i18n.Errorf("解析报表失败: %w", err)
 		}
 
 		return &result, body, nil
 	}
 
-	return nil, nil, fmt.Errorf("获取报表超时，已重试 %d 次", maxRetries)
+	return nil, nil, i18n.Errorf("获取报表超时，已重试 %d 次", maxRetries)
 }
 
 // FetchQuery 完整的获取流程：SendRequest + GetStatement
 func (c *Client) FetchQuery(queryID string) (*FlexQueryResponse, []byte, error) {
-	fmt.Printf("发送 Flex Query 请求 (QueryID: %s)...\n", queryID)
+	i18n.Fprintf(os.Stderr, "发送 Flex Query 请求 (QueryID: %s)...\n", queryID)
 
 	refCode, err := c.SendRequest(queryID)
 	if err != nil {
 		return nil, nil, err
 	}
-	fmt.Printf("获取到 ReferenceCode: %s\n", refCode)
+	i18n.Fprintf(os.Stderr, "获取到 ReferenceCode: %s\n", refCode)
 
-	fmt.Println("正在获取报表数据...")
+	i18n.Fprintf(os.Stderr, "正在获取报表数据...\n")
 	return c.GetStatement(refCode)
 }
 
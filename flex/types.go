@@ -26,16 +26,30 @@ type FlexStatement struct {
 	ToDate        string `xml:"toDate,attr"`
 	WhenGenerated string `xml:"whenGenerated,attr"`
 
+	AccountInformation *AccountInformation `xml:"AccountInformation"`
+
 	Trades           []Trade             `xml:"Trades>Trade"`
 	OpenPositions    []OpenPosition      `xml:"OpenPositions>OpenPosition"`
 	CashTransactions []CashTransaction   `xml:"CashTransactions>CashTransaction"`
 	CashReport       []CashReportCurrency `xml:"CashReport>CashReportCurrency"`
 	CorporateActions []CorporateAction   `xml:"CorporateActions>CorporateAction"`
 	Transfers        []Transfer          `xml:"Transfers>Transfer"`
+	TransactionTaxes []TransactionTax    `xml:"TransactionTaxes>TransactionTax"`
+	UnbundledCommissions []UnbundledCommissionDetail `xml:"UnbundledCommissionDetails>UnbundledCommissionDetail"`
+	EquitySummary    []EquitySummary     `xml:"EquitySummaryInBase>EquitySummaryByReportDateInBase"`
+	PriorPeriodPositions []PriorPeriodPosition `xml:"PriorPeriodPositions>PriorPeriodPosition"`
+}
+
+// AccountInformation 账户信息，currency 为账户基础货币
+type AccountInformation struct {
+	AccountID string `xml:"accountId,attr"`
+	Currency  string `xml:"currency,attr"`
+	Name      string `xml:"name,attr"`
 }
 
 type Trade struct {
 	Symbol          string  `xml:"symbol,attr"`
+	ISIN            string  `xml:"isin,attr"`
 	Description     string  `xml:"description,attr"`
 	AssetCategory   string  `xml:"assetCategory,attr"`
 	Currency        string  `xml:"currency,attr"`
@@ -47,6 +61,7 @@ type Trade struct {
 	Cost            float64 `xml:"cost,attr"`
 	RealizedPnL     float64 `xml:"fifoPnlRealized,attr"`
 	Commission      float64 `xml:"ibCommission,attr"`
+	Taxes           float64 `xml:"taxes,attr"` // 交易税（如印花税），已含在 netCash 中
 	CommissionCurr  string  `xml:"ibCommissionCurrency,attr"`
 	BuySell         string  `xml:"buySell,attr"`
 	OpenCloseInd    string  `xml:"openCloseIndicator,attr"`
@@ -54,13 +69,22 @@ type Trade struct {
 	NetCash         float64 `xml:"netCash,attr"`
 	FxRateToBase    float64 `xml:"fxRateToBase,attr"`
 	TransactionID   string  `xml:"transactionID,attr"`
+	TradeID         string  `xml:"tradeID,attr"`
 	OrderID         string  `xml:"ibOrderID,attr"`
+	Multiplier      float64 `xml:"multiplier,attr"`
+	Exchange        string  `xml:"exchange,attr"`
+	Notes           string  `xml:"notes,attr"` // 以 ; 分隔的代码，如 Ep（到期）、A（被行权）、Ex（行权）
+	PutCall         string  `xml:"putCall,attr"`
+	Strike          float64 `xml:"strike,attr"`
+	Expiry          string  `xml:"expiry,attr"`
 }
 
 type OpenPosition struct {
 	Symbol           string  `xml:"symbol,attr"`
+	ISIN             string  `xml:"isin,attr"`
 	Description      string  `xml:"description,attr"`
 	AssetCategory    string  `xml:"assetCategory,attr"`
+	UnderlyingSymbol string  `xml:"underlyingSymbol,attr"` // 期权、期货的标的
 	Currency         string  `xml:"currency,attr"`
 	Position         float64 `xml:"position,attr"`
 	MarkPrice        float64 `xml:"markPrice,attr"`
@@ -70,10 +94,15 @@ type OpenPosition struct {
 	FifoPnlUnrealized float64 `xml:"fifoPnlUnrealized,attr"`
 	FxRateToBase     float64 `xml:"fxRateToBase,attr"`
 	ReportDate       string  `xml:"reportDate,attr"`
+	LevelOfDetail    string  `xml:"levelOfDetail,attr"` // SUMMARY 或 LOT（按批次展开时）
+	OpenDateTime     string  `xml:"openDateTime,attr"`  // LOT 行的开仓时间
+	Strike           float64 `xml:"strike,attr"`        // 期权行权价
+	Multiplier       float64 `xml:"multiplier,attr"`
 }
 
 type CashTransaction struct {
 	Symbol          string  `xml:"symbol,attr"`
+	ISIN            string  `xml:"isin,attr"`
 	Description     string  `xml:"description,attr"`
 	Currency        string  `xml:"currency,attr"`
 	Amount          float64 `xml:"amount,attr"`
@@ -84,10 +113,16 @@ type CashTransaction struct {
 	TransactionID   string  `xml:"transactionID,attr"`
 }
 
+// CorporateAction 公司行动（拆股、合股、代码变更、分拆等），quantity 为持仓数量的变化，
+// 代码变更和合股通常是旧代码减少、新代码增加的两行
 type CorporateAction struct {
+	AccountID     string  `xml:"accountId,attr"`
+	AssetCategory string  `xml:"assetCategory,attr"`
 	Symbol        string  `xml:"symbol,attr"`
+	ISIN          string  `xml:"isin,attr"`
 	Description   string  `xml:"description,attr"`
 	DateTime      string  `xml:"dateTime,attr"`
+	ReportDate    string  `xml:"reportDate,attr"`
 	Type          string  `xml:"type,attr"`
 	Quantity      float64 `xml:"quantity,attr"`
 	Amount        float64 `xml:"amount,attr"`
@@ -95,17 +130,88 @@ type CorporateAction struct {
 	TransactionID string  `xml:"transactionID,attr"`
 }
 
-// Transfer 转账记录（入金/出金）
+// TransactionTax 交易税费（印花税、FTT、SEC/FINRA 规费等）
+type TransactionTax struct {
+	AccountID      string  `xml:"accountId,attr"`
+	Currency       string  `xml:"currency,attr"`
+	FxRateToBase   float64 `xml:"fxRateToBase,attr"`
+	AssetCategory  string  `xml:"assetCategory,attr"`
+	Symbol         string  `xml:"symbol,attr"`
+	Description    string  `xml:"description,attr"`
+	Date           string  `xml:"date,attr"`
+	TaxDescription string  `xml:"taxDescription,attr"`
+	Quantity       float64 `xml:"quantity,attr"`
+	TaxAmount      float64 `xml:"taxAmount,attr"`
+	TradeID        string  `xml:"tradeId,attr"`
+	TradePrice     float64 `xml:"tradePrice,attr"`
+}
+
+// UnbundledCommissionDetail 拆分后的佣金明细（IBKR 佣金、交易所费、清算费、监管规费）
+type UnbundledCommissionDetail struct {
+	AccountID                  string  `xml:"accountId,attr"`
+	Currency                   string  `xml:"currency,attr"`
+	FxRateToBase               float64 `xml:"fxRateToBase,attr"`
+	AssetCategory              string  `xml:"assetCategory,attr"`
+	Symbol                     string  `xml:"symbol,attr"`
+	DateTime                   string  `xml:"dateTime,attr"`
+	Exchange                   string  `xml:"exchange,attr"`
+	BuySell                    string  `xml:"buySell,attr"`
+	Quantity                   float64 `xml:"quantity,attr"`
+	Price                      float64 `xml:"price,attr"`
+	TransactionID              string  `xml:"transactionID,attr"`
+	TradeID                    string  `xml:"tradeID,attr"`
+	OrderID                    string  `xml:"ibOrderID,attr"`
+	TotalCommission            float64 `xml:"totalCommission,attr"`
+	BrokerExecutionCharge      float64 `xml:"brokerExecutionCharge,attr"`
+	BrokerClearingCharge       float64 `xml:"brokerClearingCharge,attr"`
+	ThirdPartyExecutionCharge  float64 `xml:"thirdPartyExecutionCharge,attr"`
+	ThirdPartyClearingCharge   float64 `xml:"thirdPartyClearingCharge,attr"`
+	ThirdPartyRegulatoryCharge float64 `xml:"thirdPartyRegulatoryCharge,attr"`
+	RegFINRATradingActivityFee float64 `xml:"regFINRATradingActivityFee,attr"`
+	RegSection31TransactionFee float64 `xml:"regSection31TransactionFee,attr"`
+	RegOther                   float64 `xml:"regOther,attr"`
+	Other                      float64 `xml:"other,attr"`
+}
+
+// Transfer 转账记录：入金/出金，以及 ACATS、FOP 等持仓转入转出（symbol、quantity 非空）
 type Transfer struct {
+	AccountID      string  `xml:"accountId,attr"`
+	Currency       string  `xml:"currency,attr"`
+	FxRateToBase   float64 `xml:"fxRateToBase,attr"`
+	AssetCategory  string  `xml:"assetCategory,attr"`
+	Symbol         string  `xml:"symbol,attr"`
+	ISIN           string  `xml:"isin,attr"`
+	Description    string  `xml:"description,attr"`
+	Type           string  `xml:"type,attr"`
+	Direction      string  `xml:"direction,attr"`      // IN 或 OUT
+	Quantity       float64 `xml:"quantity,attr"`
+	TransferPrice  float64 `xml:"transferPrice,attr"`
+	PositionAmount float64 `xml:"positionAmount,attr"` // 持仓转账的市值（交易币种）
+	Amount         float64 `xml:"amount,attr"`
+	DateTime       string  `xml:"dateTime,attr"`
+	TransactionID  string  `xml:"transactionID,attr"`
+}
+
+// EquitySummary Net Asset Value (NAV) in Base 段的每日净资产（基础货币）
+type EquitySummary struct {
+	AccountID  string  `xml:"accountId,attr"`
+	ReportDate string  `xml:"reportDate,attr"`
+	Cash       float64 `xml:"cash,attr"`
+	Stock      float64 `xml:"stock,attr"`
+	Options    float64 `xml:"options,attr"`
+	Total      float64 `xml:"total,attr"`
+}
+
+// PriorPeriodPosition Prior Period Positions 段：期间内每日收盘时持仓的价格
+type PriorPeriodPosition struct {
 	AccountID     string  `xml:"accountId,attr"`
 	Currency      string  `xml:"currency,attr"`
 	FxRateToBase  float64 `xml:"fxRateToBase,attr"`
-	Description   string  `xml:"description,attr"`
-	Type          string  `xml:"type,attr"`
-	Direction     string  `xml:"direction,attr"`
-	Amount        float64 `xml:"amount,attr"`
-	DateTime      string  `xml:"dateTime,attr"`
-	TransactionID string  `xml:"transactionID,attr"`
+	AssetCategory string  `xml:"assetCategory,attr"`
+	Symbol        string  `xml:"symbol,attr"`
+	Multiplier    float64 `xml:"multiplier,attr"`
+	Date          string  `xml:"date,attr"`
+	Price         float64 `xml:"price,attr"`
 }
 
 // CashReport 中的货币明细行（区别于 CashTransaction）
@@ -137,6 +243,17 @@ type CashReportCurrency struct {
 	DepositsYTD     float64 `xml:"depositsYTD,attr"`
 	Withdrawals     float64 `xml:"withdrawals,attr"`
 	WithdrawalsYTD  float64 `xml:"withdrawalsYTD,attr"`
+	NetTradesSales  float64 `xml:"netTradesSales,attr"`
+	NetTradesPurchases float64 `xml:"netTradesPurchases,attr"`
 	NetTradesSalesYTD float64 `xml:"netTradesSalesYTD,attr"`
 	NetTradesPurchasesYTD float64 `xml:"netTradesPurchasesYTD,attr"`
+	AccountTransfers float64 `xml:"accountTransfers,attr"`
+	InternalTransfers float64 `xml:"internalTransfers,attr"`
+	BondInterest    float64 `xml:"bondInterest,attr"`
+	PaymentInLieu   float64 `xml:"paymentInLieu,attr"`
+	TransactionTax  float64 `xml:"transactionTax,attr"`
+	SalesTax        float64 `xml:"salesTax,attr"`
+	AdvisorFees     float64 `xml:"advisorFees,attr"`
+	FxTranslationGainLoss float64 `xml:"fxTranslationGainLoss,attr"`
+	Other           float64 `xml:"other,attr"`
 }
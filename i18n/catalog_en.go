@@ -0,0 +1,530 @@
+package i18n
+
+// en 英文目录，键为中文原文。
+// 对齐用的格式串（如 "总佣金:     %s\n"）按同一组输出的最长标签补齐空格。
+var en = map[string]string{
+	"不支持的语言: %s (可用: zh, en)": "unsupported language: %s (available: zh, en)",
+
+	// 命令行
+	"IBKR 交易记录分析工具":                        "IBKR trade record analysis tool",
+	"起始日期 (YYYYMMDD)":                      "Start date (YYYYMMDD)",
+	"结束日期 (YYYYMMDD)":                      "End date (YYYYMMDD)",
+	"输出格式: table, json, ndjson, csv, xlsx": "Output format: table, json, ndjson, csv, xlsx",
+	"导出路径：csv 为目录，xlsx 为文件（默认保存到 data 目录）": "Export path: a directory for csv, a file for xlsx (defaults to the data directory)",
+	"输出语言: zh, en":                                          "Output language: zh, en",
+	"按订单合并部分成交后再分析":                                         "Merge partial fills into orders before analysis",
+	"拉取 Flex Query 数据并保存为本地 XML":                            "Fetch Flex Query data and save it as local XML",
+	"指定拉取的 query 名称":                                        "Name of the query to fetch",
+	"分析已拉取的数据":                                              "Analyze fetched data",
+	"拉取数据并分析（fetch + analyze）":                              "Fetch and analyze (fetch + analyze)",
+	"生成综合报告（--format markdown|html）":                        "Generate a full report (--format markdown|html)",
+	"输出文件路径（默认保存到 data 目录）":                                 "Output file path (defaults to the data directory)",
+	"自定义 text/template 模板文件（数据模型见 README）":                  "Custom text/template file (see README for the data model)",
+	"导出为记账分录、OFX 或 Portfolio Performance / Ghostfolio 导入文件": "Export journal entries, OFX, or Portfolio Performance / Ghostfolio import files",
+	"输出路径：默认 data 目录下的 ibkr.beancount / ibkr.ledger / ibkr.ofx / ghostfolio.json，pp 为目录": "Output path: defaults to ibkr.beancount / ibkr.ledger / ibkr.ofx / ghostfolio.json in the data directory; a directory for pp",
+	"Ghostfolio 账户 ID（为空时导入时选择）":                                                         "Ghostfolio account ID (choose during import if empty)",
+	"不生成期初余额和余额断言":                                                                       "Do not emit opening balances and balance assertions",
+
+	"未找到 query: %s (可用: %s)":          "query not found: %s (available: %s)",
+	"拉取 %s 失败: %w":                    "failed to fetch %s: %w",
+	"保存文件失败: %w":                      "failed to save file: %w",
+	"✓ %s: 已保存到 %s (%d 账户, %d 笔交易)\n": "✓ %s: saved to %s (%d accounts, %d trades)\n",
+	"✓ %s: 已保存到 %s\n":                 "✓ %s: saved to %s\n",
+	"未知分析类型: %s (可用: trades, orders, dividends, commissions, fees, fx, summary, allocation, attribution)": "unknown analysis type: %s (available: trades, orders, dividends, commissions, fees, fx, summary, allocation, attribution)",
+	"跳过 %s: 无本地数据，请先执行 fetch\n":                                                                           "Skipping %s: no local data, run fetch first\n",
+	"不支持的输出格式: %s (可用: table, json, ndjson, csv, xlsx)":                                                   "unsupported output format: %s (available: table, json, ndjson, csv, xlsx)",
+	"创建目录失败: %w":                       "failed to create directory: %w",
+	"导出 CSV 失败: %w":                    "failed to export CSV: %w",
+	"导出 XLSX 失败: %w":                   "failed to export XLSX: %w",
+	"✓ 已导出: %s\n":                      "✓ Exported: %s\n",
+	"读取 %s 失败: %w":                     "failed to read %s: %w",
+	"解析 %s 失败: %w":                     "failed to parse %s: %w",
+	"使用数据文件: %s\n":                     "Using data file: %s\n",
+	"无可用数据，请先执行 ibkr fetch":            "no data available, run ibkr fetch first",
+	"读取模板失败: %w":                       "failed to read template: %w",
+	"报告不支持格式: %s (可用: markdown, html)": "unsupported report format: %s (available: markdown, html)",
+	"保存报告失败: %w":                       "failed to save report: %w",
+	"✓ 报告已生成: %s\n":                    "✓ Report generated: %s\n",
+
+	// 记账导出
+	"不支持的记账格式: %s (可用: beancount, ledger)":                      "unsupported journal format: %s (available: beancount, ledger)",
+	"不支持的导出格式: %s (可用: beancount, ledger, pp, ghostfolio, ofx)": "unsupported export format: %s (available: beancount, ledger, pp, ghostfolio, ofx)",
+	"生成 OFX 失败: %w":      "failed to generate OFX: %w",
+	"没有新的分录: %s\n":       "No new entries: %s\n",
+	"✓ 已追加 %d 条分录到 %s\n": "✓ Appended %d entries to %s\n",
+	"期初余额":               "Opening balance",
+	"期初持仓":               "Opening positions",
+	"余额断言":               "Balance assertion",
+
+	// 看板
+	"启动本地网页看板（页面、JSON 接口和 /metrics）":                                          "Start the local web dashboard (pages, JSON API and /metrics)",
+	"输出 Prometheus 指标（供 node_exporter textfile 收集器使用，抓取接口见 serve 的 /metrics）": "Print Prometheus metrics (for the node_exporter textfile collector; serve exposes /metrics for scraping)",
+	"写入文件（如 textfile 目录下的 ibkr.prom），默认输出到终端":                                 "Write to a file (e.g. ibkr.prom in the textfile directory) instead of stdout",
+	"监听地址（默认只监听本机）":                                                           "Listen address (localhost only by default)",
+	"监听地址无效: %w":   "invalid listen address: %w",
+	"监听 %s 失败: %w": "failed to listen on %s: %w",
+	"⚠ 监听 %s，局域网内的其他设备也能访问账户数据\n": "⚠ Listening on %s; other devices on the network can read your account data\n",
+	"✓ 看板已启动: http://%s/\n":       "✓ Dashboard running at http://%s/\n",
+	"日期格式无效: %s=%s":               "invalid date: %s=%s",
+	"账户概览":                        "Overview",
+	"持仓":                          "Positions",
+	"盈亏":                          "P&L",
+	"股息":                          "Dividends",
+	"起始日期":                        "From",
+	"结束日期":                        "To",
+	"查询":                          "Apply",
+	"全部":                          "All",
+	"报告期间：%s — %s":                "Period: %s — %s",
+	"无持仓":                         "No open positions",
+	"无股息记录":                       "No dividends",
+
+	// 定时拉取
+	"按 [daemon] 配置的计划定时拉取数据，并按保留策略清理旧快照": "Fetch on the [daemon] schedule and apply the snapshot retention policy",
+	"立即执行一次后退出（可由系统 cron/systemd 调度）":    "Run once now and exit (for system cron/systemd timers)",
+	"daemon.schedule 无效: %w":      "invalid daemon.schedule: %w",
+	"daemon.schedule 无效: %s 不会触发": "invalid daemon.schedule: %s never fires",
+	"打开运行日志失败: %w":                "failed to open run log: %w",
+	"写入运行日志失败: %v\n":              "Failed to write run log: %v\n",
+	"下次运行: %s\n":                  "Next run: %s\n",
+	"── 运行 %s ──\n":               "── Run %s ──\n",
+	"跳过 %s: 已有 %s 的数据 (%s)\n":     "Skipping %s: data for %s already exists (%s)\n",
+	"拉取 %s 失败（第 %d 次）: %v\n":      "Fetching %s failed (attempt %d): %v\n",
+	"%s 后重试\n":                    "Retrying in %s\n",
+	"拉取失败: %v":                    "fetch failed: %v",
+
+	// 快照对比
+	"比较两次拉取的快照：新增/删除/被更正的记录、持仓和现金变化":     "Compare two fetched snapshots: added/removed/restated records, position and cash changes",
+	"需要两个快照文件，或不带参数比较最近两次拉取":             "requires two snapshot files, or no arguments to compare the two latest fetches",
+	"不带参数时比较此 query 最近两次拉取的快照":           "Without arguments, compare the two latest snapshots of this query",
+	"%s 只有 %d 个快照，至少需要两个":                "%s has only %d snapshot(s), at least two are required",
+	"配置了多个 query，请用 --query 指定 (可用: %s)": "multiple queries configured, choose one with --query (available: %s)",
+	"比较 %s → %s\n":               "Comparing %s → %s\n",
+	"快照对比":                       "Snapshot Diff",
+	"旧快照期间: %s — %s\n":           "Old snapshot period: %s — %s\n",
+	"新快照期间: %s — %s\n":           "New snapshot period: %s — %s\n",
+	"⚠ IBKR 更正了 %d 条旧快照期间内的记录\n": "⚠ IBKR restated %d record(s) within the old snapshot period\n",
+	"两个快照没有差异":                   "The two snapshots are identical",
+	"新增":                         "Added",
+	"删除":                         "Removed",
+	"修改":                         "Amended",
+	"(更正)":                       "(restated)",
+	"更正":                         "Restated",
+	"变化":                         "Change",
+	"交易":                         "Trades",
+	"现金流水":                       "Cash transactions",
+	"持仓变化":                       "Position changes",
+	"现金变化":                       "Cash changes",
+	"修改的字段":                      "Changed fields",
+	"描述":                         "Description",
+	"账户":                         "Account",
+	"旧数量":                        "Old qty",
+	"新数量":                        "New qty",
+	"旧市值":                        "Old value",
+	"市值变化":                       "Value change",
+	"新市值":                        "New value",
+	"旧余额":                        "Old balance",
+	"新余额":                        "New balance",
+
+	// 持仓重建
+	"按交易、公司行动和转仓重建任意日期的持仓，并与 OpenPositions 核对":     "Rebuild holdings as of any date from trades, corporate actions and transfers, and check them against OpenPositions",
+	"重建哪一天收盘后的持仓 (YYYY-MM-DD 或 YYYYMMDD)，默认为数据截止日": "Rebuild holdings at the close of this date (YYYY-MM-DD or YYYYMMDD), defaults to the end of the data",
+	"持仓重建":               "Rebuilt Holdings",
+	"截至 %s\n\n":          "As of %s\n\n",
+	"与 OpenPositions 核对": "Check against OpenPositions",
+	"✓ 期末持仓与 OpenPositions 一致（%d 个持仓）\n": "✓ Ending holdings match OpenPositions (%d positions)\n",
+	"报告日":  "Report date",
+	"重建数量": "Rebuilt qty",
+	"报告数量": "Reported qty",
+	"差异":   "Difference",
+	"⚠ %d/%d 个持仓不一致。数据期间之前已持有、未列入期初批次（--lots-file、--lots-from）的标的，或 Flex Query 缺少 Corporate Actions、Transfers 段时会出现差异\n": "⚠ %d/%d positions differ. Holdings opened before the data period and missing from the opening lots (--lots-file, --lots-from), or a Flex Query without the Corporate Actions and Transfers sections, cause differences\n",
+
+	// 核对
+	"核对数据完整性：用明细记录重建 IBKR 的汇总数据并列出差异": "Check data integrity: rebuild IBKR's summary figures from detail records and list differences",
+	"未知核对类型: %s (可用: cash, pnl)":      "unknown reconciliation: %s (available: cash, pnl)",
+	"现金核对": "Cash Reconciliation",
+	"无 CashReport 数据，请在 Flex Query 中添加 Cash Report 段": "No CashReport data; add the Cash Report section to the Flex Query",
+	"期间":          "Period",
+	"期初":          "Starting",
+	"期末":          "Ending",
+	"按汇总项":        "From summary",
+	"汇总差异":        "Summary diff",
+	"按明细":         "From details",
+	"明细差异":        "Detail diff",
+	"交易（含佣金、交易税）": "Trades (incl. commissions and taxes)",
+	"利息":          "Interest",
+	"出入金":         "Deposits & withdrawals",
+	"未解析的汇总项":     "Unparsed summary items",
+	"✓ 所有币种的期末现金均可由汇总项和明细记录重建": "✓ Ending cash of every currency is rebuilt from both summary items and detail records",
+	"未解释的差异": "Unexplained differences",
+	"明细合计":   "Detail total",
+	"汇总项与明细": "Summary vs details",
+	"⚠ %d 个币种的现金对不上：明细合计偏离说明 Flex Query 缺少对应的段（如 Cash Transactions、Transfers），未解析的汇总项说明 CashReport 有尚未支持的字段\n": "⚠ Cash does not reconcile for %d currencies: a detail total that differs points to a missing Flex section (e.g. Cash Transactions, Transfers); unparsed summary items point to CashReport fields not yet supported\n",
+	"盈亏核对": "P&L Reconciliation",
+	"不支持的盈亏来源: %s (可用: computed, ibkr)":                                  "unsupported P&L source: %s (available: computed, ibkr)",
+	"已实现盈亏来源: computed（FIFO 重算）, ibkr（fifoPnlRealized），默认取配置 pnl_source": "Realized P&L source: computed (FIFO recomputed), ibkr (fifoPnlRealized); defaults to pnl_source in the config",
+	"已实现盈亏采用 IBKR 的 fifoPnlRealized":                                     "Realized P&L uses IBKR's fifoPnlRealized",
+	"FIFO 重算:             %s\n":                                          "FIFO recomputed:       %s\n",
+	"差异:                  %s\n":                                          "Difference:            %s\n",
+	"报告采用:              %s\n":                                            "Reports use:           %s\n",
+	"FIFO 重算":                                                            "FIFO",
+	"不一致/交易数":                                                            "Mismatched/Trades",
+	"不一致":                                                                "Mismatched",
+	"不一致的交易":                                                             "Mismatched trades",
+	"原因":                                                                 "Reason",
+	"缺少开仓批次":                                                             "Missing opening lots",
+	"公司行动":                                                               "Corporate action",
+	"期权行权/被指派":                                                           "Option exercise/assignment",
+	"未知":                                                                 "Unknown",
+	"✓ 每笔交易的已实现盈亏都与 IBKR 一致":                                             "✓ Realized P&L of every trade matches IBKR",
+	"缺少开仓批次：开仓早于数据期间，FIFO 无法得知成本，IBKR 的结果更可信":                           "Missing opening lots: the position was opened before the data period, so FIFO lacks its cost and IBKR's figure is more reliable",
+	"公司行动、期权行权/被指派：IBKR 调整了批次的数量或成本，本程序的 FIFO 未处理":                      "Corporate action, option exercise/assignment: IBKR adjusted lot quantity or cost, which this FIFO does not model",
+	"可用 --pnl-source ibkr（或配置 pnl_source = \"ibkr\"）让各报告采用 IBKR 的已实现盈亏": "Use --pnl-source ibkr (or pnl_source = \"ibkr\" in the config) to make reports use IBKR's realized P&L",
+
+	// 期初批次
+	"期初批次 CSV（symbol,date,quantity,cost），开仓早于最早交易时作为 FIFO 的起点": "Opening lots CSV (symbol,date,quantity,cost), used as the FIFO starting point when positions were opened before the earliest trade",
+	"用较早快照的 OpenPositions 作为期初批次，该快照日期及之前的交易不再重放":              "Use OpenPositions from an earlier snapshot as opening lots; trades on or before that snapshot's date are not replayed",
+	"lots_file 和 lots_snapshot 只能设置一个":                         "only one of lots_file and lots_snapshot may be set",
+	"期初批次: %d 个 (来自 %s)\n":                                     "Opening lots: %d (from %s)\n",
+	"读取批次文件失败: %w":                                             "failed to read lots file: %w",
+	"批次文件缺少列: %s":                                              "lots file is missing column: %s",
+	"批次文件第 %d 行: 日期无效: %s":                                     "lots file line %d: invalid date: %s",
+	"批次文件第 %d 行: 数量无效: %s":                                     "lots file line %d: invalid quantity: %s",
+	"批次文件第 %d 行: 成本无效: %s":                                     "lots file line %d: invalid cost: %s",
+	"批次文件第 %d 行: 缺少 symbol":                                    "lots file line %d: missing symbol",
+
+	// 资产配置
+	"资产配置":       "Asset Allocation",
+	"截至 %s\n":    "As of %s\n",
+	"净资产: %s\n":  "Net liquidation: %s\n",
+	"现金:   %s\n": "Cash:            %s\n",
+	"按行业":        "By Sector",
+	"按国家/地区":     "By Country/Region",
+	"按单一标的":      "By Single Name",
+	"名称":         "Name",
+	"目标":         "Target",
+	"偏离":         "Drift",
+	"价格":         "Price",
+	"当前占比":       "Current %",
+	"目标占比":       "Target %",
+	"调整后占比":      "After %",
+	"占比 (%)":     "Weight (%)",
+	"目标 (%)":     "Target (%)",
+	"偏离 (%)":     "Drift (%)",
+	"当前占比 (%)":   "Current (%)",
+	"目标占比 (%)":   "Target (%)",
+	"调整后占比 (%)":  "After (%)",
+	"净资产":        "Net liquidation",
+	"现金":         "Cash",
+	"未分类":        "Unclassified",
+	"调仓计划":       "Rebalance Plan",
+	"调仓后现金":      "Cash after rebalance",
+	"证券主数据中没有，归入未分类: %s\n\n":                  "Not in the security master, grouped as unclassified: %s\n\n",
+	"未配置目标权重（[[allocation.targets]]），不生成调仓计划": "No target weights configured ([[allocation.targets]]); no rebalance plan",
+	"调仓后现金: %s（单笔最小金额 %s）\n":                  "Cash after rebalance: %s (minimum trade %s)\n",
+	"⚠ 没有价格（未持有也没有交易记录），未列入调仓计划: %s\n":        "⚠ No price (not held and never traded), left out of the rebalance plan: %s\n",
+	"目标权重缺少 symbol":                           "target weight is missing symbol",
+	"目标权重重复: %s":                              "duplicate target weight: %s",
+	"目标权重无效: %s=%g":                           "invalid target weight: %s=%g",
+	"目标权重合计 %.2f%% 超过 100%%":                  "target weights add up to %.2f%%, more than 100%%",
+	"min_trade 不能为负: %g":                      "min_trade must not be negative: %g",
+	"读取证券主数据失败: %w":                           "failed to read security master: %w",
+	"证券主数据缺少列: %s":                            "security master is missing column: %s",
+	"证券主数据第 %d 行: 缺少 symbol":                  "security master line %d: missing symbol",
+
+	// 风险检查
+	"按 [risk] 配置的限额检查最新数据，有超限时以退出码 2 退出并可通知 webhook": "Check the latest data against the [risk] limits; exit with code 2 and optionally notify a webhook on violations",
+	"有超限时 POST 检查结果的 URL，默认取配置 risk.webhook":         "URL to POST the results to on violations (defaults to risk.webhook in the config)",
+	"通知 webhook 失败: %w":      "failed to notify webhook: %w",
+	"通知 webhook 失败: HTTP %d": "failed to notify webhook: HTTP %d",
+	"✓ 已通知 webhook\n":        "✓ Webhook notified\n",
+	"风险检查":                   "Risk Check",
+	"规则":                     "Rule",
+	"对象":                     "Subject",
+	"当前值":                    "Value",
+	"限额":                     "Limit",
+	"结果":                     "Result",
+	"跳过":                     "skipped",
+	"单一标的占比上限":               "Max single-name weight",
+	"单一行业占比上限":               "Max sector weight",
+	"融资使用率上限":                "Max margin usage",
+	"卖出期权名义金额上限":             "Max short option notional",
+	"现金下限":                   "Min cash",
+	"未配置风险规则（[risk]）":        "No risk rules configured ([risk])",
+	"单一行业占比需要证券主数据（security_master），已跳过": "Sector weight needs a security master (security_master); skipped",
+	"✗ %d 项超限\n":            "✗ %d limit(s) breached\n",
+	"✓ 所有规则均未超限":            "✓ All rules within limits",
+	"风险检查通过（%s）":            "Risk check passed (%s)",
+	"风险检查（%s）发现 %d 项超限: %s": "Risk check (%s) found %d breach(es): %s",
+
+	// 基准对比
+	"基准对比":         "Benchmark Comparison",
+	"按月份对比":        "Monthly Comparison",
+	"按月份对比 %s":     "Monthly Comparison %s",
+	"基准":           "Benchmark",
+	"状态":           "Status",
+	"组合":           "Portfolio",
+	"超额":           "Excess",
+	"组合收益":         "Portfolio return",
+	"基准收益":         "Benchmark return",
+	"超额收益":         "Excess return",
+	"期末净资产":        "Ending NAV",
+	"出入金都买基准":      "All flows into benchmark",
+	"Alpha (年化)":   "Alpha (ann.)",
+	"跟踪误差":         "Tracking error",
+	"组合收益 (%)":     "Portfolio return (%)",
+	"基准收益 (%)":     "Benchmark return (%)",
+	"Alpha (年化 %)": "Alpha (ann. %)",
+	"跟踪误差 (%)":     "Tracking error (%)",
+	"组合 (%)":       "Portfolio (%)",
+	"基准 (%)":       "Benchmark (%)",
+	"超额 (%)":       "Excess (%)",
+	"缺少每日净资产，请在 Flex Query 中勾选 Net Asset Value (NAV) in Base": "no daily NAV; enable Net Asset Value (NAV) in Base in the Flex Query",
+	"价格数据不覆盖组合的起始日期":                                          "price data does not cover the portfolio start date",
+	"数据中没有价格币种的汇率":                                            "no exchange rate for the price currency in the data",
+	"读取基准价格失败: %s: %w":                                        "failed to read benchmark prices: %s: %w",
+	"基准价格 %s 需要 date 和 close 列":                               "benchmark prices %s need date and close columns",
+	"基准价格 %s 第 %d 行: 日期无效: %s":                                "benchmark prices %s line %d: invalid date: %s",
+	"基准价格 %s 没有有效的价格":                                         "benchmark prices %s contain no valid prices",
+
+	// 业绩归因
+	"业绩归因":                      "Performance Attribution",
+	"期间: %s ~ %s\n":             "Period: %s ~ %s\n",
+	"净资产: %s → %s\n":            "NAV: %s → %s\n",
+	"收益率: %s（Modified Dietz）\n": "Return: %s (Modified Dietz)\n",
+	"净资产变化分解":                   "NAV Change Breakdown",
+	"收入":                        "Income",
+	"汇率影响":                      "FX Effect",
+	"残差":                        "Residual",
+	"净资产变化":                     "NAV Change",
+	"期初净资产":                     "Starting NAV",
+	"贡献":                        "Contribution",
+	"贡献 (%)":                    "Contribution (%)",
+	"收益率 (%)":                   "Return (%)",
+	"⚠ 期初或期末没有价格，价格和汇率贡献计入残差: %s\n":                        "⚠ No price at the start or end, price and FX contributions go to the residual: %s\n",
+	"⚠ 数据中没有这些币种的汇率，按 1:1 折算为基础货币: %s\n":                   "⚠ No FX rates for these currencies in the data, converted to base at 1:1: %s\n",
+	"⚠ 各分项合计与净资产变化相差 %s（残差），这部分没有归因\n":                     "⚠ The components differ from the NAV change by %s (residual), which is not attributed\n",
+	"  常见原因：应计股息和利息的变化、没有价格的持仓、Flex Query 缺少交易、现金流水或转账段\n": "  Common causes: changes in accrued dividends and interest, unpriced positions, or missing Trades, Cash Transactions or Transfers sections in the Flex Query\n",
+	"  期初已持有的标的需要 Flex Query 勾选 Prior Period Positions\n":  "  Symbols held at the start need the Prior Period Positions section in the Flex Query\n",
+
+	// 配置
+	"读取配置文件失败: %w": "failed to read config file: %w",
+	"解析配置失败: %w":   "failed to parse config: %w",
+	"配置缺少 token":   "config is missing token",
+	"配置缺少 queries": "config is missing queries",
+	"创建数据目录失败: %w": "failed to create data directory: %w",
+
+	// Flex 客户端
+	"创建请求失败: %w":                          "failed to create request: %w",
+	"发送请求失败: %w":                          "failed to send request: %w",
+	"读取响应失败: %w":                          "failed to read response: %w",
+	"解析响应失败: %w":                          "failed to parse response: %w",
+	"API 错误 [%d]: %s":                     "API error [%d]: %s",
+	"  报表生成中，等待重试 (%d/%d)...\n":           "  Statement is being generated, retrying (%d/%d)...\n",
+	"  触发速率限制，等待 10 秒...\n":               "  Rate limited, waiting 10 seconds...\n",
+	"解析报表失败: %w":                          "failed to parse statement: %w",
+	"获取报表超时，已重试 %d 次":                     "timed out fetching statement after %d retries",
+	"发送 Flex Query 请求 (QueryID: %s)...\n": "Sending Flex Query request (QueryID: %s)...\n",
+	"获取到 ReferenceCode: %s\n":             "Got ReferenceCode: %s\n",
+	"正在获取报表数据...\n":                       "Fetching statement data...\n",
+
+	// 报告模板
+	"解析模板失败: %w": "failed to parse template: %w",
+	"渲染模板失败: %w": "failed to render template: %w",
+
+	// 标题
+	"盈亏分析":   "P&L Analysis",
+	"订单明细":   "Orders",
+	"股息统计":   "Dividends",
+	"佣金统计":   "Commissions",
+	"交易成本":   "Trading Costs",
+	"外汇分析":   "FX Analysis",
+	"账户综合汇总": "Account Summary",
+
+	// 小节
+	"按标的":   "By Symbol",
+	"按月份":   "By Month",
+	"按费用类型": "By Fee Type",
+	"按资产类别": "By Asset Category",
+	"按交易所":  "By Exchange",
+	"按订单规模": "By Order Size",
+	"按币种":   "By Currency",
+	"换汇记录":  "Conversions",
+	"佣金构成":  "Commission Breakdown",
+	"当前持仓":  "Current Positions",
+	"── 计费方案模拟（美股/美股期权）──":              "── Pricing Plan Simulation (US stocks/options) ──",
+	"差额为正表示该方案比实际更便宜；阶梯方案的交易所/清算费为估算值。": "A positive difference means the plan is cheaper than actual; exchange/clearing fees for Tiered are estimates.",
+	"有 %d 条佣金明细未找到对应交易\n\n":             "%d commission details could not be matched to a trade\n\n",
+
+	// 表头
+	"日期":              "Date",
+	"标的":              "Symbol",
+	"方向":              "Side",
+	"数量":              "Quantity",
+	"均价":              "Avg Price",
+	"成交额":             "Notional",
+	"佣金":              "Commission",
+	"成交笔数":            "Fills",
+	"首笔成交":            "First Fill",
+	"末笔成交":            "Last Fill",
+	"类型":              "Type",
+	"金额":              "Amount",
+	"笔数":              "Count",
+	"类别":              "Category",
+	"费用":              "Fees",
+	"交易税费":            "Taxes",
+	"合计":              "Total",
+	"月份":              "Month",
+	"货币对":             "Pair",
+	"卖出":              "Sold",
+	"买入":              "Bought",
+	"汇率":              "Rate",
+	"已实现损益":           "Realized",
+	"币种":              "Currency",
+	"推算余额":            "Derived Balance",
+	"期末余额":            "Ending Balance",
+	"平均汇率":            "Avg Rate",
+	"当前汇率":            "Current Rate",
+	"换汇已实现":           "Realized (Conversion)",
+	"其他已实现":           "Realized (Other)",
+	"已实现":             "Realized",
+	"未实现":             "Unrealized",
+	"费率(bps)":         "Rate (bps)",
+	"每股/张":            "Per Unit",
+	"交易数":             "Trades",
+	"交易所":             "Exchange",
+	"方案":              "Plan",
+	"模拟佣金":            "Simulated",
+	"实际佣金":            "Actual",
+	"差额":              "Difference",
+	"总股息":             "Gross",
+	"税前股息":            "Gross Dividends",
+	"预扣税":             "Withholding",
+	"净收入":             "Net",
+	"次数":              "Payments",
+	"现价":              "Price",
+	"成本价":             "Cost Basis",
+	"市值":              "Value",
+	"未实现P&L":          "Unrealized P&L",
+	"已实现P&L":          "Realized P&L",
+	"占比":              "Weight",
+	"胜率":              "Win Rate",
+	"IBKR 佣金":         "IBKR Execution",
+	"IBKR 清算":         "IBKR Clearing",
+	"交易所费":            "Exchange Fees",
+	"清算费":             "Clearing Fees",
+	"监管费":             "Regulatory Fees",
+	"其他":              "Other",
+	"项目":              "Item",
+	"数值":              "Value",
+	"汇总":              "Totals",
+	"卖出币种":            "Sold Currency",
+	"买入币种":            "Bought Currency",
+	"胜率 (%)":          "Win Rate (%)",
+	"费率按资产类别":         "Rate by Asset Category",
+	"计费方案模拟":          "Pricing Plans",
+	"总交易数":            "Total Trades",
+	"总佣金":             "Total Commission",
+	"总成交额":            "Total Notional",
+	"平均费率 (bps)":      "Avg Rate (bps)",
+	"总股息收入":           "Gross Dividends",
+	"派息次数":            "Payments",
+	"订单数":             "Orders",
+	"平均成交笔数":          "Avg Fills per Order",
+	"平均佣金/单":          "Avg Commission per Order",
+	"总成本":             "Total Cost",
+	"CashReport 其他费用": "CashReport Other Fees",
+	"基础货币":            "Base Currency",
+	"已实现汇兑损益":         "Realized FX Gain",
+	"未实现折算损益":         "Unrealized Translation",
+	"换汇佣金":            "Conversion Commission",
+
+	// 费用类别、计费方案
+	"其他费用":        "Other fees",
+	"行情订阅费":       "Market data",
+	"ADR 托管费":     "ADR custody fee",
+	"融券费":         "Borrow fee",
+	"印花税":         "Stamp duty",
+	"金融交易税":       "Financial transaction tax",
+	"SEC 规费":      "SEC fee",
+	"FINRA 规费":    "FINRA TAF",
+	"其他交易税":       "Other transaction tax",
+	"(账户)":        "(account)",
+	"固定 (Fixed)":  "Fixed",
+	"阶梯 (Tiered)": "Tiered",
+
+	// 汇总行（同组标签对齐）
+	"已实现盈亏: %s\n":        "Realized P&L:     %s\n",
+	"总交易数:   %d\n":       "Total trades:     %d\n",
+	"胜率:       %.1f%%\n": "Win rate:         %.1f%%\n",
+	"总佣金:     %s\n":      "Total commission: %s\n",
+	"平均佣金:   %s\n":       "Avg commission:   %s\n",
+	"总成交额:   %s\n":       "Total notional:   %s\n",
+	"平均费率:   %s bps\n":   "Avg rate:         %s bps\n",
+
+	"订单数:       %d\n": "Orders:           %d\n",
+	"成交笔数:     %d\n":  "Fills:            %d\n",
+	"平均成交笔数: %s\n":    "Avg fills/order:  %s\n",
+	"总佣金:       %s\n": "Total commission: %s\n",
+	"平均佣金/单:  %s\n":   "Avg comm/order:   %s\n",
+
+	"佣金:       %s\n":        "Commission:  %s\n",
+	"费用:       %s\n":        "Fees:        %s\n",
+	"交易税费:   %s\n":          "Taxes:       %s\n",
+	"总成本:     %s\n":         "Total cost:  %s\n",
+	"CashReport 其他费用: %s\n": "CashReport other fees: %s\n",
+
+	"基础货币:       %s\n": "Base currency:          %s\n",
+	"已实现汇兑损益: %s\n":    "Realized FX gain:       %s\n",
+	"未实现折算损益: %s\n":    "Unrealized translation: %s\n",
+	"换汇佣金:       %s\n": "Conversion commission:  %s\n",
+
+	"总股息收入:   %s\n":   "Gross dividends: %s\n",
+	"预扣税:       %s\n": "Withholding tax: %s\n",
+	"净股息收入:   %s\n":   "Net dividends:   %s\n",
+	"派息次数:     %d\n":  "Payments:        %d\n",
+
+	"账户总值:       %s\n":  "Account value:      %s\n",
+	"  持仓市值:     %s\n":  "  Positions value:  %s\n",
+	"  现金余额:     %s\n":  "  Cash balance:     %s\n",
+	"未实现盈亏:     %s\n":   "Unrealized P&L:     %s\n",
+	"已实现盈亏:     %s\n":   "Realized P&L:       %s\n",
+	"净股息收入:     %s\n":   "Net dividends:      %s\n",
+	"总佣金:         %s\n": "Total commission:   %s\n",
+	"总入金:         %s\n": "Total deposits:     %s\n",
+	"总出金:         %s\n": "Total withdrawals:  %s\n",
+
+	// 报告
+	"IBKR 账户报告":            "IBKR Account Report",
+	"报告期间：%s — %s　生成时间：%s": "Period: %s — %s   Generated: %s",
+	"报告期间：":                "Period:",
+	"生成时间：":                "Generated:",
+	"账户总值":                 "Account Value",
+	"持仓市值":                 "Positions Value",
+	"现金余额":                 "Cash Balance",
+	"已实现盈亏":                "Realized P&L",
+	"未实现盈亏":                "Unrealized P&L",
+	"净股息收入":                "Net Dividends",
+	"佣金支出":                 "Commissions Paid",
+	"资金流动":                 "Cash Flows",
+	"总入金":                  "Total Deposits",
+	"总出金":                  "Total Withdrawals",
+	"净入金":                  "Net Deposits",
+	"收益总览":                 "Returns Overview",
+	"综合收益":                 "Total Return",
+	"收益率（基于入金）":            "Return on Deposits",
+	"累计已实现盈亏":              "Cumulative Realized P&L",
+	"月度已实现盈亏":              "Monthly Realized P&L",
+	"持仓分布":                 "Allocation",
+	"月度股息收入":               "Monthly Dividend Income",
+	"已实现盈亏明细":              "Realized P&L Details",
+	"月度收益":                 "Monthly Returns",
+	"股息收入":                 "Dividend Income",
+	"外汇":                   "FX",
+	"总股息：%s　预扣税：%s　净收入：%s　派息次数：%d":                           "Gross: %s   Withholding: %s   Net: %s   Payments: %d",
+	"总股息：%s　预扣税：%s　**净收入：%s**　派息次数：%d":                       "Gross: %s   Withholding: %s   **Net: %s**   Payments: %d",
+	"平仓交易数：%d 笔　胜率：%.1f%%　总佣金：%s":                            "Closing trades: %d   Win rate: %.1f%%   Total commission: %s",
+	"佣金：%s　费用：%s　交易税费：%s　**合计：%s**":                          "Commission: %s   Fees: %s   Taxes: %s   **Total: %s**",
+	"基础货币：%s　已实现汇兑损益：%s　未实现折算损益：%s　换汇佣金：%s":                  "Base currency: %s   Realized FX gain: %s   Unrealized translation: %s   Conversion commission: %s",
+	"净股息收入（含预扣税）：":                                           "Net dividend income (after withholding): ",
+	"详细股息明细需在 AllData365 Flex Query 中添加 Cash Transactions 段": "Add the Cash Transactions section to the AllData365 Flex Query for dividend details",
+}
@@ -0,0 +1,164 @@
+// Package i18n 提供中英文消息目录和按语言区域的数字、日期格式化。
+//
+// 消息以中文原文为键：T("盈亏分析") 在中文下原样返回，在英文下查 en 目录，
+// 目录中没有的键原样返回，因此新增消息时只需在 catalog_en.go 中补充翻译。
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// 支持的语言
+const (
+	Chinese = "zh"
+	English = "en"
+)
+
+var lang = Chinese
+
+// SetLang 设置输出语言，接受 zh、zh-CN、en、en_US 等写法
+func SetLang(l string) error {
+	switch strings.ToLower(strings.SplitN(strings.ReplaceAll(l, "_", "-"), "-", 2)[0]) {
+	case "", "zh":
+		lang = Chinese
+	case "en":
+		lang = English
+	default:
+		return fmt.Errorf(T("不支持的语言: %s (可用: zh, en)"), l)
+	}
+	return nil
+}
+
+// Lang 返回当前语言
+func Lang() string {
+	return lang
+}
+
+// T 翻译一条消息
+func T(msg string) string {
+	if lang == English {
+		if s, ok := en[msg]; ok {
+			return s
+		}
+	}
+	return msg
+}
+
+// Sprintf 翻译格式串后格式化
+func Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Printf 翻译格式串后输出到标准输出
+func Printf(format string, args ...any) {
+	fmt.Printf(T(format), args...)
+}
+
+// Fprintf 翻译格式串后输出到 w
+func Fprintf(w io.Writer, format string, args ...any) {
+	fmt.Fprintf(w, T(format), args...)
+}
+
+// Println 翻译后输出一行
+func Println(msg string) {
+	fmt.Println(T(msg))
+}
+
+// Errorf 翻译格式串后构造错误，支持 %w
+func Errorf(format string, args ...any) error {
+	return fmt.Errorf(T(format), args...)
+}
+
+// Money 金额保留两位小数；英文使用千位分隔符
+func Money(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	if lang != English {
+		return s
+	}
+	return groupThousands(s)
+}
+
+// PnL 同 Money，正数带 "+"
+func PnL(v float64) string {
+	if v > 0 {
+		return "+" + Money(v)
+	}
+	return Money(v)
+}
+
+// groupThousands "-1234567.89" → "-1,234,567.89"
+func groupThousands(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+	if len(intPart) <= 3 {
+		return sign + intPart + frac
+	}
+
+	var b strings.Builder
+	lead := len(intPart) % 3
+	if lead > 0 {
+		b.WriteString(intPart[:lead])
+	}
+	for i := lead; i < len(intPart); i += 3 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(intPart[i : i+3])
+	}
+	return sign + b.String() + frac
+}
+
+// Number 通用数值，prec 为小数位
+func Number(v float64, prec int) string {
+	s := fmt.Sprintf("%.*f", prec, v)
+	if lang != English || math.Abs(v) < 1000 {
+		return s
+	}
+	return groupThousands(s)
+}
+
+// Date 格式化 YYYYMMDD 或 YYYY-MM-DD 日期：中文 "2025-01-15"，英文 "Jan 15, 2025"
+func Date(date string) string {
+	yyyymmdd := strings.ReplaceAll(date, "-", "")
+	if len(yyyymmdd) != 8 {
+		return date
+	}
+	if lang == English {
+		if t, err := time.Parse("20060102", yyyymmdd); err == nil {
+			return t.Format("Jan 2, 2006")
+		}
+	}
+	return yyyymmdd[:4] + "-" + yyyymmdd[4:6] + "-" + yyyymmdd[6:]
+}
+
+// Month 格式化 YYYYMM 或 YYYY-MM 月份：中文 "2025-01"，英文 "Jan 2025"
+func Month(month string) string {
+	yyyymm := strings.ReplaceAll(month, "-", "")
+	if len(yyyymm) < 6 {
+		return month
+	}
+	if lang == English {
+		if t, err := time.Parse("200601", yyyymm[:6]); err == nil {
+			return t.Format("Jan 2006")
+		}
+	}
+	return yyyymm[:4] + "-" + yyyymm[4:6]
+}
+
+// DateTime 格式化时间
+func DateTime(t time.Time) string {
+	if lang == English {
+		return t.Format("Jan 2, 2006 15:04:05")
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -1,8 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
-	"encoding/xml"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,32 +12,62 @@ import (
 
 	"github.com/solarhell/ibkr-finance-analysis/analysis"
 	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
 	"github.com/spf13/cobra"
 )
 
 var (
-	flagFrom   string
-	flagTo     string
-	flagFormat string
-	flagQuery  string
+	flagFrom      string
+	flagTo        string
+	flagFormat    string
+	flagQuery     string
+	flagLang      string
+	flagOutput    string
+	flagPnLSource string
+	flagLotsFile  string
+	flagLotsFrom  string
+
+	flagByOrder bool
 )
 
 func main() {
+	// 帮助文本在构建命令时翻译，需先确定语言
+	if err := i18n.SetLang(detectLang()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	root := &cobra.Command{
 		Use:   "ibkr",
-		Short: "IBKR 交易记录分析工具",
+		Short: i18n.T("IBKR 交易记录分析工具"),
 	}
 
-	root.PersistentFlags().StringVar(&flagFrom, "from", "", "起始日期 (YYYYMMDD)")
-	root.PersistentFlags().StringVar(&flagTo, "to", "", "结束日期 (YYYYMMDD)")
-	root.PersistentFlags().StringVar(&flagFormat, "format", "table", "输出格式: table, json")
+	root.PersistentFlags().StringVar(&flagFrom, "from", "", i18n.T("起始日期 (YYYYMMDD)"))
+	root.PersistentFlags().StringVar(&flagTo, "to", "", i18n.T("结束日期 (YYYYMMDD)"))
+	root.PersistentFlags().StringVar(&flagFormat, "format", "table", i18n.T("输出格式: table, json, ndjson, csv, xlsx"))
+	root.PersistentFlags().StringVar(&flagLang, "lang", i18n.Lang(), i18n.T("输出语言: zh, en"))
+	root.PersistentFlags().BoolVar(&flagByOrder, "by-order", false, i18n.T("按订单合并部分成交后再分析"))
+	root.PersistentFlags().StringVar(&flagPnLSource, "pnl-source", "", i18n.T("已实现盈亏来源: computed（FIFO 重算）, ibkr（fifoPnlRealized），默认取配置 pnl_source"))
+	root.PersistentFlags().StringVar(&flagLotsFile, "lots-file", "", i18n.T("期初批次 CSV（symbol,date,quantity,cost），开仓早于最早交易时作为 FIFO 的起点"))
+	root.PersistentFlags().StringVar(&flagLotsFrom, "lots-from", "", i18n.T("用较早快照的 OpenPositions 作为期初批次，该快照日期及之前的交易不再重放"))
 
 	root.AddCommand(fetchCmd())
 	root.AddCommand(analyzeCmd())
 	root.AddCommand(syncCmd())
 	root.AddCommand(reportCmd())
+	root.AddCommand(exportCmd())
+	root.AddCommand(serveCmd())
+	root.AddCommand(metricsCmd())
+	root.AddCommand(daemonCmd())
+	root.AddCommand(diffCmd())
+	root.AddCommand(positionsCmd())
+	root.AddCommand(reconcileCmd())
+	root.AddCommand(checkCmd())
 
 	if err := root.Execute(); err != nil {
+		if errors.Is(err, errRiskViolations) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
@@ -44,7 +75,7 @@ func main() {
 func fetchCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "fetch",
-		Short: "拉取 Flex Query 数据并保存为本地 XML",
+		Short: i18n.T("拉取 Flex Query 数据并保存为本地 XML"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := LoadConfig()
 			if err != nil {
@@ -58,7 +89,7 @@ func fetchCmd() *cobra.Command {
 				// 只拉取指定的 query
 				qid, ok := cfg.Queries[flagQuery]
 				if !ok {
-					return fmt.Errorf("未找到 query: %s (可用: %s)", flagQuery, availableQueries(cfg))
+					return i18n.Errorf("未找到 query: %s (可用: %s)", flagQuery, availableQueries(cfg))
 				}
 				queries = map[string]string{flagQuery: qid}
 			}
@@ -66,13 +97,12 @@ func fetchCmd() *cobra.Command {
 			for name, qid := range queries {
 				resp, rawXML, err := client.FetchQuery(qid)
 				if err != nil {
-					return fmt.Errorf("拉取 %s 失败: %w", name, err)
+					return i18n.Errorf("拉取 %s 失败: %w", name, err)
 				}
 
-				filename := fmt.Sprintf("%s_%s.xml", name, time.Now().Format("20060102_150405"))
-				path := filepath.Join(cfg.DataDir, filename)
-				if err := os.WriteFile(path, rawXML, 0644); err != nil {
-					return fmt.Errorf("保存文件失败: %w", err)
+				filename, err := saveSnapshot(cfg.DataDir, name, rawXML)
+				if err != nil {
+					return err
 				}
 
 				stmtCount := len(resp.FlexStatements)
@@ -80,19 +110,19 @@ func fetchCmd() *cobra.Command {
 				for _, s := range resp.FlexStatements {
 					tradeCount += len(s.Trades)
 				}
-				fmt.Printf("✓ %s: 已保存到 %s (%d 账户, %d 笔交易)\n", name, filename, stmtCount, tradeCount)
+				i18n.Fprintf(os.Stderr, "✓ %s: 已保存到 %s (%d 账户, %d 笔交易)\n", name, filename, stmtCount, tradeCount)
 			}
 			return nil
 		},
 	}
-	cmd.Flags().StringVarP(&flagQuery, "query", "q", "", "指定拉取的 query 名称")
+	cmd.Flags().StringVarP(&flagQuery, "query", "q", "", i18n.T("指定拉取的 query 名称"))
 	return cmd
 }
 
 func analyzeCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "analyze [trades|dividends|commissions|summary]",
-		Short: "分析已拉取的数据",
+		Use:   "analyze [trades|orders|dividends|commissions|fees|fx|summary|allocation|attribution]",
+		Short: i18n.T("分析已拉取的数据"),
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := LoadConfig()
@@ -105,16 +135,17 @@ func analyzeCmd() *cobra.Command {
 				return err
 			}
 
-			return runAnalysis(args[0], statements, flagFrom, flagTo, flagFormat)
+			return runAnalysis(args[0], statements, flagFrom, flagTo, flagFormat, cfg)
 		},
 	}
+	cmd.Flags().StringVarP(&flagOutput, "output", "o", "", i18n.T("导出路径：csv 为目录，xlsx 为文件（默认保存到 data 目录）"))
 	return cmd
 }
 
 func syncCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "sync [trades|dividends|commissions|summary]",
-		Short: "拉取数据并分析（fetch + analyze）",
+	cmd := &cobra.Command{
+		Use:   "sync [trades|orders|dividends|commissions|fees|fx|summary|allocation|attribution]",
+		Short: i18n.T("拉取数据并分析（fetch + analyze）"),
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := LoadConfig()
@@ -128,58 +159,143 @@ func syncCmd() *cobra.Command {
 			for name, qid := range cfg.Queries {
 				resp, rawXML, err := client.FetchQuery(qid)
 				if err != nil {
-					return fmt.Errorf("拉取 %s 失败: %w", name, err)
+					return i18n.Errorf("拉取 %s 失败: %w", name, err)
 				}
 
-				filename := fmt.Sprintf("%s_%s.xml", name, time.Now().Format("20060102_150405"))
-				path := filepath.Join(cfg.DataDir, filename)
-				if err := os.WriteFile(path, rawXML, 0644); err != nil {
-					return fmt.Errorf("保存文件失败: %w", err)
+				filename, err := saveSnapshot(cfg.DataDir, name, rawXML)
+				if err != nil {
+					return err
 				}
-				fmt.Printf("✓ %s: 已保存到 %s\n", name, filename)
+				i18n.Fprintf(os.Stderr, "✓ %s: 已保存到 %s\n", name, filename)
 
 				allStatements = append(allStatements, resp.FlexStatements...)
 			}
 
-			fmt.Println()
-			return runAnalysis(args[0], allStatements, flagFrom, flagTo, flagFormat)
+			fmt.Fprintln(os.Stderr)
+			return runAnalysis(args[0], allStatements, flagFrom, flagTo, flagFormat, cfg)
 		},
 	}
+	cmd.Flags().StringVarP(&flagOutput, "output", "o", "", i18n.T("导出路径：csv 为目录，xlsx 为文件（默认保存到 data 目录）"))
+	return cmd
 }
 
-func runAnalysis(mode string, statements []flex.FlexStatement, from, to, format string) error {
+func runAnalysis(mode string, statements []flex.FlexStatement, from, to, format string, cfg *Config) error {
+	if flagByOrder {
+		statements = analysis.MergeOrderFills(statements)
+	}
+
+	kind, report, show, err := analyze(mode, statements, from, to, cfg.Analysis)
+	if err != nil {
+		return err
+	}
+	return writeReport(kind, report, show, statements, from, to, format, cfg.DataDir)
+}
+
+// writeReport 按 --format 输出分析结果：表格、JSON/NDJSON（带 envelope）或 CSV/XLSX 文件
+func writeReport(kind string, report any, show func(), statements []flex.FlexStatement, from, to, format, dataDir string) error {
+	switch format {
+	case "table":
+		show()
+	case "json":
+		return printJSON(analysis.NewJSONEnvelope(kind, statements, from, to, report))
+	case "ndjson":
+		return analysis.WriteNDJSON(os.Stdout, analysis.NewJSONEnvelope(kind, statements, from, to, report))
+	case "csv", "xlsx":
+		return exportTables(kind, report.(analysis.Tabular).Tables(), format, dataDir)
+	default:
+		return i18n.Errorf("不支持的输出格式: %s (可用: table, json, ndjson, csv, xlsx)", format)
+	}
+	return nil
+}
+
+// analyze 运行一种分析，返回 JSON 中的 kind、报告结构和表格输出函数
+func analyze(mode string, statements []flex.FlexStatement, from, to string, opts analysis.Options) (kind string, report any, show func(), err error) {
 	switch mode {
 	case "trades", "pnl":
-		r := analysis.AnalyzePnL(statements, from, to)
-		if format == "json" {
-			return printJSON(r)
-		}
-		analysis.PrintPnLReport(r)
+		r := analysis.AnalyzePnL(statements, from, to, opts)
+		kind, report, show = "pnl", r, func() { analysis.PrintPnLReport(r) }
+
+	case "orders":
+		r := analysis.AnalyzeOrders(statements, from, to)
+		kind, report, show = "orders", r, func() { analysis.PrintOrderReport(r) }
 
 	case "dividends":
 		r := analysis.AnalyzeDividends(statements, from, to)
-		if format == "json" {
-			return printJSON(r)
-		}
-		analysis.PrintDividendReport(r)
+		kind, report, show = "dividends", r, func() { analysis.PrintDividendReport(r) }
 
 	case "commissions":
 		r := analysis.AnalyzeCommissions(statements, from, to)
-		if format == "json" {
-			return printJSON(r)
-		}
-		analysis.PrintCommissionReport(r)
+		kind, report, show = "commissions", r, func() { analysis.PrintCommissionReport(r) }
+
+	case "fees":
+		r := analysis.AnalyzeFees(statements, from, to)
+		kind, report, show = "fees", r, func() { analysis.PrintFeeReport(r) }
+
+	case "fx":
+		r := analysis.AnalyzeFX(statements, from, to)
+		kind, report, show = "fx", r, func() { analysis.PrintFXReport(r) }
 
 	case "summary":
-		r := analysis.AnalyzeSummary(statements, from, to)
-		if format == "json" {
-			return printJSON(r)
+		r := analysis.AnalyzeSummary(statements, from, to, opts)
+		kind, report, show = "summary", r, func() { analysis.PrintSummaryReport(r) }
+
+	case "allocation":
+		r := analysis.AnalyzeAllocation(statements, opts)
+		kind, report, show = "allocation", r, func() { analysis.PrintAllocationReport(r) }
+
+	case "attribution":
+		r := analysis.AnalyzeAttribution(statements, from, to)
+		if r == nil {
+			err = i18n.Errorf("缺少每日净资产，请在 Flex Query 中勾选 Net Asset Value (NAV) in Base")
+			return
 		}
-		analysis.PrintSummaryReport(r)
+		r.PrintWarnings(os.Stderr) // 任何输出格式都提示
+		kind, report, show = "attribution", r, func() { analysis.PrintAttributionReport(r) }
 
 	default:
-		return fmt.Errorf("未知分析类型: %s (可用: trades, dividends, commissions, summary)", mode)
+		err = i18n.Errorf("未知分析类型: %s (可用: trades, orders, dividends, commissions, fees, fx, summary, allocation, attribution)", mode)
 	}
+	return
+}
+
+// exportTables 写出 CSV（--output 为目录）或 XLSX（--output 为文件），默认保存到 data 目录
+func exportTables(kind string, tables []analysis.Table, format, dataDir string) error {
+	stamp := time.Now().Format("20060102_150405")
+
+	if format == "csv" {
+		dir := flagOutput
+		if dir == "" {
+			dir = dataDir
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return i18n.Errorf("创建目录失败: %w", err)
+		}
+		paths, err := analysis.WriteCSV(dir, kind+"_"+stamp, tables, true)
+		if err != nil {
+			return i18n.Errorf("导出 CSV 失败: %w", err)
+		}
+		for _, p := range paths {
+			i18n.Printf("✓ 已导出: %s\n", p)
+		}
+		return nil
+	}
+
+	path := flagOutput
+	if path == "" {
+		path = filepath.Join(dataDir, fmt.Sprintf("%s_%s.xlsx", kind, stamp))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return i18n.Errorf("导出 XLSX 失败: %w", err)
+	}
+	defer f.Close()
+	if err := analysis.WriteXLSX(f, tables); err != nil {
+		return i18n.Errorf("导出 XLSX 失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return i18n.Errorf("导出 XLSX 失败: %w", err)
+	}
+	i18n.Printf("✓ 已导出: %s\n", path)
 	return nil
 }
 
@@ -187,35 +303,22 @@ func loadLatestData(cfg *Config) ([]flex.FlexStatement, error) {
 	var allStatements []flex.FlexStatement
 
 	for name := range cfg.Queries {
-		pattern := filepath.Join(cfg.DataDir, name+"_*.xml")
-		matches, err := filepath.Glob(pattern)
-		if err != nil || len(matches) == 0 {
-			fmt.Printf("跳过 %s: 无本地数据，请先执行 fetch\n", name)
+		latest := latestDataFile(cfg.DataDir, name)
+		if latest == "" {
+			i18n.Fprintf(os.Stderr, "跳过 %s: 无本地数据，请先执行 fetch\n", name)
 			continue
 		}
-		// 文件名含时间戳，字典序最大即最新
-		latest := matches[0]
-		for _, m := range matches[1:] {
-			if filepath.Base(m) > filepath.Base(latest) {
-				latest = m
-			}
-		}
 
-		data, err := os.ReadFile(latest)
+		statements, err := loadSnapshot(latest)
 		if err != nil {
-			return nil, fmt.Errorf("读取 %s 失败: %w", latest, err)
+			return nil, err
 		}
-
-		var resp flex.FlexQueryResponse
-		if err := xml.Unmarshal(data, &resp); err != nil {
-			return nil, fmt.Errorf("解析 %s 失败: %w", latest, err)
-		}
-		fmt.Printf("使用数据文件: %s\n", filepath.Base(latest))
-		allStatements = append(allStatements, resp.FlexStatements...)
+		i18n.Fprintf(os.Stderr, "使用数据文件: %s\n", filepath.Base(latest))
+		allStatements = append(allStatements, statements...)
 	}
 
 	if len(allStatements) == 0 {
-		return nil, fmt.Errorf("无可用数据，请先执行 ibkr fetch")
+		return nil, i18n.Errorf("无可用数据，请先执行 ibkr fetch")
 	}
 	return allStatements, nil
 }
@@ -227,10 +330,10 @@ func printJSON(v any) error {
 }
 
 func reportCmd() *cobra.Command {
-	var outputFile string
+	var outputFile, templateFile string
 	cmd := &cobra.Command{
 		Use:   "report",
-		Short: "生成 Markdown 格式的综合报告",
+		Short: i18n.T("生成综合报告（--format markdown|html）"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := LoadConfig()
 			if err != nil {
@@ -242,22 +345,189 @@ func reportCmd() *cobra.Command {
 				return err
 			}
 
-			md := analysis.GenerateMarkdownReport(statements, flagFrom, flagTo)
+			if flagByOrder {
+				statements = analysis.MergeOrderFills(statements)
+			}
+
+			var content, ext string
+			switch {
+			case templateFile != "":
+				tmplText, err := os.ReadFile(templateFile)
+				if err != nil {
+					return i18n.Errorf("读取模板失败: %w", err)
+				}
+				data := analysis.BuildReportData(statements, flagFrom, flagTo, cfg.Analysis)
+				if content, err = analysis.RenderReport(data, string(tmplText)); err != nil {
+					return err
+				}
+				ext = strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(templateFile, ".tmpl")), ".")
+				if ext == "" {
+					ext = "md"
+				}
+			case flagFormat == "html":
+				content, ext = analysis.GenerateHTMLReport(statements, flagFrom, flagTo, cfg.Analysis), "html"
+			case flagFormat == "table", flagFormat == "markdown", flagFormat == "md":
+				if content, err = analysis.GenerateMarkdownReport(statements, flagFrom, flagTo, cfg.Analysis); err != nil {
+					return err
+				}
+				ext = "md"
+			default:
+				return i18n.Errorf("报告不支持格式: %s (可用: markdown, html)", flagFormat)
+			}
 
 			if outputFile == "" {
-				outputFile = filepath.Join(cfg.DataDir, fmt.Sprintf("report_%s.md", time.Now().Format("20060102_150405")))
+				outputFile = filepath.Join(cfg.DataDir, fmt.Sprintf("report_%s.%s", time.Now().Format("20060102_150405"), ext))
 			}
-			if err := os.WriteFile(outputFile, []byte(md), 0644); err != nil {
-				return fmt.Errorf("保存报告失败: %w", err)
+			if err := os.WriteFile(outputFile, []byte(content), 0644); err != nil {
+				return i18n.Errorf("保存报告失败: %w", err)
 			}
-			fmt.Printf("✓ 报告已生成: %s\n", outputFile)
+			i18n.Printf("✓ 报告已生成: %s\n", outputFile)
 			return nil
 		},
 	}
-	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "输出文件路径（默认保存到 data 目录）")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", i18n.T("输出文件路径（默认保存到 data 目录）"))
+	cmd.Flags().StringVarP(&templateFile, "template", "t", "", i18n.T("自定义 text/template 模板文件（数据模型见 README）"))
+	return cmd
+}
+
+func exportCmd() *cobra.Command {
+	var outputFile, accountID string
+	var noBalance bool
+	cmd := &cobra.Command{
+		Use:   "export [beancount|ledger|pp|ghostfolio|ofx]",
+		Short: i18n.T("导出为记账分录、OFX 或 Portfolio Performance / Ghostfolio 导入文件"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+
+			statements, err := loadLatestData(cfg)
+			if err != nil {
+				return err
+			}
+
+			if flagByOrder {
+				statements = analysis.MergeOrderFills(statements)
+			}
+
+			switch format := args[0]; format {
+			case "beancount", "ledger":
+				if outputFile == "" {
+					outputFile = filepath.Join(cfg.DataDir, "ibkr."+format)
+				}
+				return exportJournal(statements, format, outputFile, cfg.Accounts, !noBalance, cfg.Analysis)
+
+			case "pp":
+				dir := outputFile
+				if dir == "" {
+					dir = cfg.DataDir
+				}
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return i18n.Errorf("创建目录失败: %w", err)
+				}
+				paths, err := analysis.WriteCSV(dir, "pp", analysis.PortfolioPerformanceTables(statements, flagFrom, flagTo), false)
+				if err != nil {
+					return i18n.Errorf("导出 CSV 失败: %w", err)
+				}
+				for _, p := range paths {
+					i18n.Printf("✓ 已导出: %s\n", p)
+				}
+				return nil
+
+			case "ghostfolio":
+				if outputFile == "" {
+					outputFile = filepath.Join(cfg.DataDir, "ghostfolio.json")
+				}
+				data, err := json.MarshalIndent(analysis.BuildGhostfolioExport(statements, flagFrom, flagTo, accountID), "", "  ")
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(outputFile, append(data, '\n'), 0644); err != nil {
+					return i18n.Errorf("保存文件失败: %w", err)
+				}
+				i18n.Printf("✓ 已导出: %s\n", outputFile)
+				return nil
+
+			case "ofx":
+				if outputFile == "" {
+					outputFile = filepath.Join(cfg.DataDir, "ibkr.ofx")
+				}
+				var buf bytes.Buffer
+				if err := analysis.WriteOFX(&buf, statements, flagFrom, flagTo); err != nil {
+					return i18n.Errorf("生成 OFX 失败: %w", err)
+				}
+				if err := os.WriteFile(outputFile, buf.Bytes(), 0644); err != nil {
+					return i18n.Errorf("保存文件失败: %w", err)
+				}
+				i18n.Printf("✓ 已导出: %s\n", outputFile)
+				return nil
+
+			default:
+				return i18n.Errorf("不支持的导出格式: %s (可用: beancount, ledger, pp, ghostfolio, ofx)", format)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", i18n.T("输出路径：默认 data 目录下的 ibkr.beancount / ibkr.ledger / ibkr.ofx / ghostfolio.json，pp 为目录"))
+	cmd.Flags().BoolVar(&noBalance, "no-balance", false, i18n.T("不生成期初余额和余额断言"))
+	cmd.Flags().StringVar(&accountID, "account-id", "", i18n.T("Ghostfolio 账户 ID（为空时导入时选择）"))
 	return cmd
 }
 
+// exportJournal 追加 beancount/ledger 分录，已导出过的分录（按 ibkr_id）不再重复写入
+func exportJournal(statements []flex.FlexStatement, format, outputFile string, accounts analysis.JournalAccounts, balances bool, opts analysis.Options) error {
+	existing, err := os.ReadFile(outputFile)
+	if err != nil && !os.IsNotExist(err) {
+		return i18n.Errorf("读取 %s 失败: %w", outputFile, err)
+	}
+	content, count, err := analysis.ExportJournal(statements, flagFrom, flagTo, format, accounts, balances, analysis.JournalIDs(existing), opts)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		i18n.Printf("没有新的分录: %s\n", outputFile)
+		return nil
+	}
+
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return i18n.Errorf("保存文件失败: %w", err)
+	}
+	defer f.Close()
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n\n") {
+		content = "\n" + content
+	}
+	if _, err := f.WriteString(content); err != nil {
+		return i18n.Errorf("保存文件失败: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return i18n.Errorf("保存文件失败: %w", err)
+	}
+	i18n.Printf("✓ 已追加 %d 条分录到 %s\n", count, outputFile)
+	return nil
+}
+
+// detectLang 按 --lang 参数、IBKR_LANG 环境变量、配置文件 lang 的顺序确定语言
+func detectLang() string {
+	args := os.Args[1:]
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		if v, ok := strings.CutPrefix(a, "--lang="); ok {
+			return v
+		}
+		if a == "--lang" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	if v := os.Getenv("IBKR_LANG"); v != "" {
+		return v
+	}
+	return configLang()
+}
+
 func availableQueries(cfg *Config) string {
 	var names []string
 	for name := range cfg.Queries {
@@ -0,0 +1,83 @@
+// Package ofx 生成 OFX 2.x（XML）文档，并按规范的聚合结构检查生成结果。
+// 只覆盖投资对账单（INVSTMTRS）用到的聚合，见 schema。
+package ofx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Header OFX 2.2 文档头：XML 声明和 OFX 处理指令
+const Header = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" +
+	`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+
+// Node 一个元素：有 Children 的是聚合，否则是带 Value 的数据元素
+type Node struct {
+	Name     string
+	Value    string
+	Children []*Node
+}
+
+// Agg 聚合元素，nil 子元素（未提供的可选项）会被跳过
+func Agg(name string, children ...*Node) *Node {
+	n := &Node{Name: name}
+	for _, c := range children {
+		if c != nil {
+			n.Children = append(n.Children, c)
+		}
+	}
+	return n
+}
+
+// Elem 数据元素
+func Elem(name, value string) *Node {
+	return &Node{Name: name, Value: value}
+}
+
+// OptElem 可选数据元素，值为空时返回 nil
+func OptElem(name, value string) *Node {
+	if value == "" {
+		return nil
+	}
+	return Elem(name, value)
+}
+
+// Add 追加子元素（nil 跳过）
+func (n *Node) Add(children ...*Node) *Node {
+	for _, c := range children {
+		if c != nil {
+			n.Children = append(n.Children, c)
+		}
+	}
+	return n
+}
+
+// Write 写出带文档头的 OFX，每层缩进两个空格
+func Write(w io.Writer, root *Node) error {
+	var b strings.Builder
+	b.WriteString(Header)
+	writeNode(&b, root, 0)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeNode(b *strings.Builder, n *Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	if len(n.Children) == 0 {
+		b.WriteString(indent + "<" + n.Name + ">" + escape(n.Value) + "</" + n.Name + ">\n")
+		return
+	}
+	b.WriteString(indent + "<" + n.Name + ">\n")
+	for _, c := range n.Children {
+		writeNode(b, c, depth+1)
+	}
+	b.WriteString(indent + "</" + n.Name + ">\n")
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,263 @@
+package ofx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// group 聚合中按顺序出现的一组子元素：Names 中任选其一，出现 min..max 次（max < 0 表示不限）
+type group struct {
+	names    []string
+	min, max int
+}
+
+func one(name string) group       { return group{[]string{name}, 1, 1} }
+func opt(names ...string) group   { return group{names, 0, 1} }
+func many(names ...string) group  { return group{names, 0, -1} }
+func some(names ...string) group  { return group{names, 1, -1} }
+func seq(groups ...group) []group { return groups }
+
+// schema OFX 2.2 规范中投资对账单相关聚合的子元素顺序（未列出的元素视为数据元素）
+var schema = map[string][]group{
+	"OFX":             seq(one("SIGNONMSGSRSV1"), opt("INVSTMTMSGSRSV1"), opt("SECLISTMSGSRSV1")),
+	"SIGNONMSGSRSV1":  seq(one("SONRS")),
+	"SONRS":           seq(one("STATUS"), one("DTSERVER"), opt("USERKEY"), opt("TSKEYEXPIRE"), one("LANGUAGE"), opt("DTPROFUP"), opt("DTACCTUP"), opt("FI"), opt("SESSCOOKIE"), opt("ACCESSKEY")),
+	"STATUS":          seq(one("CODE"), one("SEVERITY"), opt("MESSAGE")),
+	"FI":              seq(one("ORG"), opt("FID")),
+	"INVSTMTMSGSRSV1": seq(many("INVSTMTTRNRS")),
+	"INVSTMTTRNRS":    seq(one("TRNUID"), one("STATUS"), opt("CLTCOOKIE"), opt("OFXEXTENSION"), opt("INVSTMTRS")),
+	"INVSTMTRS":       seq(one("DTASOF"), one("CURDEF"), one("INVACCTFROM"), opt("INVTRANLIST"), opt("INVPOSLIST"), opt("INVBAL"), opt("INVOOLIST"), opt("MKTGINFO")),
+	"INVACCTFROM":     seq(one("BROKERID"), one("ACCTID")),
+	"INVTRANLIST": seq(one("DTSTART"), one("DTEND"), many(
+		"BUYDEBT", "BUYMF", "BUYOPT", "BUYOTHER", "BUYSTOCK", "CLOSUREOPT", "INCOME", "INVEXPENSE",
+		"JRNLFUND", "JRNLSEC", "MARGININTEREST", "REINVEST", "RETOFCAP", "SELLDEBT", "SELLMF",
+		"SELLOPT", "SELLOTHER", "SELLSTOCK", "SPLIT", "TRANSFER", "INVBANKTRAN")),
+
+	"BUYSTOCK":   seq(one("INVBUY"), one("BUYTYPE")),
+	"BUYOPT":     seq(one("INVBUY"), one("OPTBUYTYPE"), one("SHPERCTRCT")),
+	"BUYOTHER":   seq(one("INVBUY")),
+	"SELLSTOCK":  seq(one("INVSELL"), one("SELLTYPE")),
+	"SELLOPT":    seq(one("INVSELL"), one("OPTSELLTYPE"), one("SHPERCTRCT"), opt("RELFITID"), opt("RELTYPE"), opt("SECURED")),
+	"SELLOTHER":  seq(one("INVSELL")),
+	"CLOSUREOPT": seq(one("INVTRAN"), one("SECID"), one("OPTACTION"), one("UNITS"), one("SHPERCTRCT"), one("SUBACCTSEC"), opt("RELFITID"), opt("GAIN")),
+	"INVBUY": seq(one("INVTRAN"), one("SECID"), one("UNITS"), one("UNITPRICE"), opt("MARKUP"), opt("COMMISSION"), opt("TAXES"), opt("FEES"), opt("LOAD"),
+		one("TOTAL"), opt("CURRENCY", "ORIGCURRENCY"), one("SUBACCTSEC"), one("SUBACCTFUND"), opt("LOANID"), opt("LOANPRINCIPAL"), opt("LOANINTEREST"),
+		opt("INV401KSOURCE"), opt("DTPAYROLL"), opt("PRIORYEARCONTRIB")),
+	"INVSELL": seq(one("INVTRAN"), one("SECID"), one("UNITS"), one("UNITPRICE"), opt("MARKDOWN"), opt("COMMISSION"), opt("TAXES"), opt("FEES"), opt("LOAD"),
+		opt("WITHHOLDING"), opt("TAXEXEMPT"), one("TOTAL"), opt("GAIN"), opt("CURRENCY", "ORIGCURRENCY"), one("SUBACCTSEC"), one("SUBACCTFUND"),
+		opt("LOANID"), opt("STATEWITHHOLDING"), opt("PENALTY"), opt("INV401KSOURCE")),
+	"INCOME": seq(one("INVTRAN"), one("SECID"), one("INCOMETYPE"), one("TOTAL"), one("SUBACCTSEC"), one("SUBACCTFUND"), opt("TAXEXEMPT"), opt("WITHHOLDING"),
+		opt("CURRENCY", "ORIGCURRENCY"), opt("INV401KSOURCE")),
+	"INVTRAN":      seq(one("FITID"), opt("SRVRTID"), one("DTTRADE"), opt("DTSETTLE"), opt("REVERSALFITID"), opt("MEMO")),
+	"SECID":        seq(one("UNIQUEID"), one("UNIQUEIDTYPE")),
+	"CURRENCY":     seq(one("CURRATE"), one("CURSYM")),
+	"ORIGCURRENCY": seq(one("CURRATE"), one("CURSYM")),
+	"INVBANKTRAN":  seq(one("STMTTRN"), one("SUBACCTFUND")),
+	"STMTTRN": seq(one("TRNTYPE"), one("DTPOSTED"), opt("DTUSER"), opt("DTAVAIL"), one("TRNAMT"), one("FITID"), opt("CORRECTFITID"), opt("CORRECTACTION"),
+		opt("SRVRTID"), opt("CHECKNUM"), opt("REFNUM"), opt("SIC"), opt("PAYEEID"), opt("NAME", "EXTDNAME", "PAYEE"), opt("BANKACCTTO", "CCACCTTO"),
+		opt("MEMO"), many("IMAGEDATA"), opt("CURRENCY", "ORIGCURRENCY"), opt("INV401KSOURCE")),
+
+	"INVPOSLIST": seq(many("POSDEBT", "POSMF", "POSOPT", "POSOTHER", "POSSTOCK")),
+	"POSSTOCK":   seq(one("INVPOS"), opt("UNITSSTREET"), opt("UNITSUSER"), opt("REINVDIV")),
+	"POSOPT":     seq(one("INVPOS"), opt("SECURED")),
+	"POSOTHER":   seq(one("INVPOS")),
+	"INVPOS": seq(one("SECID"), one("HELDINACCT"), one("POSTYPE"), one("UNITS"), one("UNITPRICE"), one("MKTVAL"), opt("AVGCOSTBASIS"), one("DTPRICEASOF"),
+		opt("CURRENCY", "ORIGCURRENCY"), opt("MEMO"), opt("INV401KSOURCE")),
+	"INVBAL":  seq(one("AVAILCASH"), one("MARGINBALANCE"), one("SHORTBALANCE"), opt("BUYPOWER"), opt("BALLIST")),
+	"BALLIST": seq(some("BAL")),
+	"BAL":     seq(one("NAME"), one("DESC"), one("BALTYPE"), one("VALUE"), opt("DTASOF"), opt("CURRENCY")),
+
+	"SECLISTMSGSRSV1": seq(many("SECLISTTRNRS"), opt("SECLIST")),
+	"SECLIST":         seq(many("DEBTINFO", "MFINFO", "OPTINFO", "OTHERINFO", "STOCKINFO")),
+	"STOCKINFO":       seq(one("SECINFO"), opt("STOCKTYPE"), opt("YIELD"), opt("DTYIELDASOF"), opt("ASSETCLASS"), opt("FIASSETCLASS")),
+	"OPTINFO":         seq(one("SECINFO"), one("OPTTYPE"), one("STRIKEPRICE"), one("DTEXPIRE"), one("SHPERCTRCT"), opt("SECID"), opt("ASSETCLASS"), opt("FIASSETCLASS")),
+	"OTHERINFO":       seq(one("SECINFO"), opt("TYPEDESC"), opt("ASSETCLASS"), opt("FIASSETCLASS"), opt("PERCENT")),
+	"SECINFO": seq(one("SECID"), one("SECNAME"), opt("TICKER"), opt("FIID"), opt("RATING"), opt("UNITPRICE"), opt("DTASOF"),
+		opt("CURRENCY", "ORIGCURRENCY"), opt("MEMO")),
+}
+
+// enums 枚举型数据元素的取值
+var enums = map[string][]string{
+	"SEVERITY":     {"INFO", "WARN", "ERROR"},
+	"BUYTYPE":      {"BUY", "BUYTOCOVER"},
+	"SELLTYPE":     {"SELL", "SELLSHORT"},
+	"OPTBUYTYPE":   {"BUYTOOPEN", "BUYTOCLOSE"},
+	"OPTSELLTYPE":  {"SELLTOCLOSE", "SELLTOOPEN"},
+	"OPTACTION":    {"EXERCISE", "ASSIGN", "EXPIRE"},
+	"OPTTYPE":      {"PUT", "CALL"},
+	"INCOMETYPE":   {"CGLONG", "CGSHORT", "DIV", "INTEREST", "MISC"},
+	"SUBACCTSEC":   {"CASH", "MARGIN", "SHORT", "OTHER"},
+	"SUBACCTFUND":  {"CASH", "MARGIN", "SHORT", "OTHER"},
+	"HELDINACCT":   {"CASH", "MARGIN", "SHORT", "OTHER"},
+	"POSTYPE":      {"SHORT", "LONG"},
+	"BALTYPE":      {"DOLLAR", "PERCENT", "NUMBER"},
+	"UNIQUEIDTYPE": {"CUSIP", "ISIN", "CONID", "TICKER"},
+	"TRNTYPE": {"CREDIT", "DEBIT", "INT", "DIV", "FEE", "SRVCHG", "DEP", "ATM", "POS", "XFER", "CHECK",
+		"PAYMENT", "CASH", "DIRECTDEP", "DIRECTDEBIT", "REPEATPMT", "HOLD", "OTHER"},
+}
+
+// amounts 数值型数据元素
+var amounts = map[string]bool{
+	"UNITS": true, "UNITPRICE": true, "MARKUP": true, "MARKDOWN": true, "COMMISSION": true, "TAXES": true, "FEES": true,
+	"LOAD": true, "WITHHOLDING": true, "TOTAL": true, "GAIN": true, "TRNAMT": true, "MKTVAL": true, "AVGCOSTBASIS": true,
+	"AVAILCASH": true, "MARGINBALANCE": true, "SHORTBALANCE": true, "BUYPOWER": true, "VALUE": true, "CURRATE": true,
+	"STRIKEPRICE": true, "SHPERCTRCT": true, "CODE": true,
+}
+
+// maxLen 规范规定的字符串最大长度
+var maxLen = map[string]int{
+	"FITID": 255, "TRNUID": 36, "NAME": 32, "MEMO": 255, "SECNAME": 120, "TICKER": 32, "UNIQUEID": 32,
+	"BROKERID": 22, "ACCTID": 22, "DESC": 80, "MESSAGE": 255,
+}
+
+var (
+	datePattern     = regexp.MustCompile(`^\d{8}(\d{6}(\.\d{3})?)?(\[[+-]?\d+(\.\d+)?(:[A-Za-z]+)?\])?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Validate 检查文档头、XML 格式，以及各聚合的子元素顺序、必需项、枚举值、日期和数值格式；
+// 同一对账单（INVSTMTRS）内 FITID 不得重复
+func Validate(data []byte) error {
+	if !bytes.Contains(data, []byte(`<?OFX OFXHEADER="200"`)) {
+		return fmt.Errorf("ofx: missing OFX 2.x processing instruction")
+	}
+	root, err := parse(data)
+	if err != nil {
+		return err
+	}
+	if root.Name != "OFX" {
+		return fmt.Errorf("ofx: root element is %s, want OFX", root.Name)
+	}
+	return validateNode(root, "OFX")
+}
+
+func parse(data []byte) (*Node, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*Node
+	var root *Node
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("ofx: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &Node{Name: t.Name.Local}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, n)
+			} else if root == nil {
+				root = n
+			} else {
+				return nil, fmt.Errorf("ofx: multiple root elements")
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Value += string(t)
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("ofx: empty document")
+	}
+	return root, nil
+}
+
+func validateNode(n *Node, path string) error {
+	groups, isAgg := schema[n.Name]
+	if !isAgg {
+		return validateLeaf(n, path)
+	}
+	if strings.TrimSpace(n.Value) != "" {
+		return fmt.Errorf("ofx: %s: aggregate contains text", path)
+	}
+
+	i := 0
+	for _, g := range groups {
+		count := 0
+		for i < len(n.Children) && (g.max < 0 || count < g.max) && slices.Contains(g.names, n.Children[i].Name) {
+			i++
+			count++
+		}
+		if count < g.min {
+			return fmt.Errorf("ofx: %s: missing %s", path, strings.Join(g.names, "|"))
+		}
+	}
+	if i < len(n.Children) {
+		return fmt.Errorf("ofx: %s: unexpected %s", path, n.Children[i].Name)
+	}
+
+	if n.Name == "INVSTMTRS" {
+		if err := checkFITIDs(n, path); err != nil {
+			return err
+		}
+	}
+	for idx, c := range n.Children {
+		if err := validateNode(c, fmt.Sprintf("%s/%s[%d]", path, c.Name, idx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateLeaf(n *Node, path string) error {
+	if len(n.Children) > 0 {
+		return fmt.Errorf("ofx: %s: unknown aggregate", path)
+	}
+	v := strings.TrimSpace(n.Value)
+	if v == "" {
+		return fmt.Errorf("ofx: %s: empty value", path)
+	}
+	if values, ok := enums[n.Name]; ok && !slices.Contains(values, v) {
+		return fmt.Errorf("ofx: %s: invalid value %q", path, v)
+	}
+	if amounts[n.Name] {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("ofx: %s: invalid number %q", path, v)
+		}
+	}
+	if strings.HasPrefix(n.Name, "DT") && !datePattern.MatchString(v) {
+		return fmt.Errorf("ofx: %s: invalid date %q", path, v)
+	}
+	if (n.Name == "CURDEF" || n.Name == "CURSYM") && !currencyPattern.MatchString(v) {
+		return fmt.Errorf("ofx: %s: invalid currency %q", path, v)
+	}
+	if limit, ok := maxLen[n.Name]; ok && len([]rune(v)) > limit {
+		return fmt.Errorf("ofx: %s: longer than %d characters", path, limit)
+	}
+	return nil
+}
+
+// checkFITIDs 同一对账单中交易的 FITID 必须唯一，客户端据此去重
+func checkFITIDs(stmt *Node, path string) error {
+	seen := make(map[string]bool)
+	var walk func(n *Node) error
+	walk = func(n *Node) error {
+		if n.Name == "FITID" {
+			id := strings.TrimSpace(n.Value)
+			if seen[id] {
+				return fmt.Errorf("ofx: %s: duplicate FITID %s", path, id)
+			}
+			seen[id] = true
+		}
+		for _, c := range n.Children {
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(stmt)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/analysis"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+	"github.com/spf13/cobra"
+)
+
+func positionsCmd() *cobra.Command {
+	var asOf string
+	cmd := &cobra.Command{
+		Use:   "positions",
+		Short: i18n.T("按交易、公司行动和转仓重建任意日期的持仓，并与 OpenPositions 核对"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			date := strings.ReplaceAll(asOf, "-", "")
+			if date != "" {
+				if _, err := time.Parse("20060102", date); err != nil {
+					return i18n.Errorf("日期格式无效: %s=%s", "--as-of", asOf)
+				}
+			}
+
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+			statements, err := loadLatestData(cfg)
+			if err != nil {
+				return err
+			}
+
+			r := analysis.AnalyzeHoldings(statements, date, cfg.Analysis)
+			return writeReport("positions", r, func() { analysis.PrintHoldingsReport(r) }, statements, "", date, flagFormat, cfg.DataDir)
+		},
+	}
+	cmd.Flags().StringVar(&asOf, "as-of", "", i18n.T("重建哪一天收盘后的持仓 (YYYY-MM-DD 或 YYYYMMDD)，默认为数据截止日"))
+	cmd.Flags().StringVarP(&flagOutput, "output", "o", "", i18n.T("导出路径：csv 为目录，xlsx 为文件（默认保存到 data 目录）"))
+	return cmd
+}
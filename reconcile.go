@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/solarhell/ibkr-finance-analysis/analysis"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+	"github.com/spf13/cobra"
+)
+
+func reconcileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reconcile [cash|pnl]",
+		Short: i18n.T("核对数据完整性：用明细记录重建 IBKR 的汇总数据并列出差异"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+			statements, err := loadLatestData(cfg)
+			if err != nil {
+				return err
+			}
+
+			switch args[0] {
+			case "cash":
+				r := analysis.ReconcileCash(statements)
+				return writeReport("reconcile_cash", r, func() { analysis.PrintCashReconciliation(r) }, statements, "", "", flagFormat, cfg.DataDir)
+			case "pnl":
+				r := analysis.ReconcilePnL(statements, flagFrom, flagTo, cfg.Analysis)
+				return writeReport("reconcile_pnl", r, func() { analysis.PrintPnLReconciliation(r) }, statements, flagFrom, flagTo, flagFormat, cfg.DataDir)
+			default:
+				return i18n.Errorf("未知核对类型: %s (可用: cash, pnl)", args[0])
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&flagOutput, "output", "o", "", i18n.T("导出路径：csv 为目录，xlsx 为文件（默认保存到 data 目录）"))
+	return cmd
+}
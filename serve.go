@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/analysis"
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+	"github.com/spf13/cobra"
+)
+
+func serveCmd() *cobra.Command {
+	var addr string
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: i18n.T("启动本地网页看板（页面、JSON 接口和 /metrics）"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+
+			d := &dashboard{cfg: cfg}
+			if _, err := d.statements(); err != nil {
+				return err
+			}
+
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				return i18n.Errorf("监听地址无效: %w", err)
+			}
+			loopback := isLoopbackHost(host)
+			if !loopback {
+				i18n.Fprintf(os.Stderr, "⚠ 监听 %s，局域网内的其他设备也能访问账户数据\n", addr)
+			}
+
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return i18n.Errorf("监听 %s 失败: %w", addr, err)
+			}
+			i18n.Printf("✓ 看板已启动: http://%s/\n", ln.Addr())
+
+			srv := &http.Server{
+				Handler:           d.handler(loopback),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			return srv.Serve(ln)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", i18n.T("监听地址（默认只监听本机）"))
+	return cmd
+}
+
+func metricsCmd() *cobra.Command {
+	var outputFile string
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: i18n.T("输出 Prometheus 指标（供 node_exporter textfile 收集器使用，抓取接口见 serve 的 /metrics）"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+			statements, err := loadLatestData(cfg)
+			if err != nil {
+				return err
+			}
+
+			if outputFile == "" {
+				return analysis.WriteMetrics(os.Stdout, statements, cfg.Analysis)
+			}
+			// 先写临时文件再改名，避免收集器读到写了一半的文件
+			var buf bytes.Buffer
+			if err := analysis.WriteMetrics(&buf, statements, cfg.Analysis); err != nil {
+				return err
+			}
+			tmp := outputFile + ".tmp"
+			if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+				return i18n.Errorf("保存文件失败: %w", err)
+			}
+			if err := os.Rename(tmp, outputFile); err != nil {
+				return i18n.Errorf("保存文件失败: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", i18n.T("写入文件（如 textfile 目录下的 ibkr.prom），默认输出到终端"))
+	return cmd
+}
+
+// dashboard 缓存已加载的数据，data 目录出现更新的数据文件（如另开终端执行了 fetch）时重新加载
+type dashboard struct {
+	cfg *Config
+
+	mu     sync.Mutex
+	files  string
+	cached []flex.FlexStatement
+}
+
+func (d *dashboard) statements() ([]flex.FlexStatement, error) {
+	var files []string
+	for name := range d.cfg.Queries {
+		files = append(files, latestDataFile(d.cfg.DataDir, name))
+	}
+	slices.Sort(files)
+	key := strings.Join(files, "\n")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cached != nil && key == d.files {
+		return d.cached, nil
+	}
+	statements, err := loadLatestData(d.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if flagByOrder {
+		statements = analysis.MergeOrderFills(statements)
+	}
+	d.files, d.cached = key, statements
+	return statements, nil
+}
+
+func (d *dashboard) handler(loopback bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", d.serveAPI)
+	mux.HandleFunc("/metrics", d.serveMetrics)
+	mux.HandleFunc("/", d.servePage)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		// 只监听本机时拒绝其他 Host，防止 DNS rebinding 让外部网页读取数据
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host // 默认端口时不带端口号
+		}
+		if loopback && !isLoopbackHost(host) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// servePage 看板页面，?from=&to= 与命令行的 --from/--to 相同
+func (d *dashboard) servePage(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRangeParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	statements, err := d.statements()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page, ok := analysis.RenderDashboardPage(r.URL.Path, statements, from, to, d.cfg.Analysis)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(page))
+}
+
+// serveAPI /api/<kind>：与 analyze <kind> --format json 相同的 JSON
+func (d *dashboard) serveAPI(w http.ResponseWriter, r *http.Request) {
+	from, to, err := dateRangeParams(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	statements, err := d.statements()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	kind, report, _, err := analyze(strings.TrimPrefix(r.URL.Path, "/api/"), statements, from, to, d.cfg.Analysis)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(analysis.NewJSONEnvelope(kind, statements, from, to, report))
+}
+
+// serveMetrics Prometheus 抓取接口，数据随 data 目录中的新快照更新
+func (d *dashboard) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	statements, err := d.statements()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	analysis.WriteMetrics(w, statements, d.cfg.Analysis)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// dateRangeParams 读取 from/to 参数，接受 YYYYMMDD 或 YYYY-MM-DD（日期选择器的格式），返回 YYYYMMDD
+func dateRangeParams(r *http.Request) (string, string, error) {
+	var dates [2]string
+	for i, name := range []string{"from", "to"} {
+		v := strings.ReplaceAll(r.URL.Query().Get(name), "-", "")
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse("20060102", v); err != nil {
+			return "", "", i18n.Errorf("日期格式无效: %s=%s", name, r.URL.Query().Get(name))
+		}
+		dates[i] = v
+	}
+	return dates[0], dates[1], nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/solarhell/ibkr-finance-analysis/flex"
+	"github.com/solarhell/ibkr-finance-analysis/i18n"
+)
+
+// snapshotTimeFormat 数据文件名中的时间戳：<query>_20060102_150405.xml，
+// daemon 压缩后的旧快照为 .xml.gz
+const snapshotTimeFormat = "20060102_150405"
+
+// saveSnapshot 保存拉取到的原始 XML，返回文件名
+func saveSnapshot(dataDir, name string, rawXML []byte) (string, error) {
+	filename := fmt.Sprintf("%s_%s.xml", name, time.Now().Format(snapshotTimeFormat))
+	if err := os.WriteFile(filepath.Join(dataDir, filename), rawXML, 0644); err != nil {
+		return "", i18n.Errorf("保存文件失败: %w", err)
+	}
+	return filename, nil
+}
+
+// readSnapshot 读取数据文件，.gz 自动解压
+func readSnapshot(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasSuffix(path, ".gz") {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// loadSnapshot 读取并解析一个数据文件
+func loadSnapshot(path string) ([]flex.FlexStatement, error) {
+	data, err := readSnapshot(path)
+	if err != nil {
+		return nil, i18n.Errorf("读取 %s 失败: %w", path, err)
+	}
+	var resp flex.FlexQueryResponse
+	if err := xml.Unmarshal(data, &resp); err != nil {
+		return nil, i18n.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return resp.FlexStatements, nil
+}
+
+// snapshotFiles query 的所有数据文件，按时间从旧到新
+func snapshotFiles(dataDir, name string) []string {
+	var files []string
+	for _, pattern := range []string{name + "_*.xml", name + "_*.xml.gz"} {
+		matches, _ := filepath.Glob(filepath.Join(dataDir, pattern))
+		for _, m := range matches {
+			if _, ok := snapshotTime(m, name); ok {
+				files = append(files, m)
+			}
+		}
+	}
+	// 文件名含时间戳，字典序即时间顺序
+	sort.Slice(files, func(i, j int) bool { return filepath.Base(files[i]) < filepath.Base(files[j]) })
+	return files
+}
+
+// latestDataFile 返回 query 最新的数据文件，没有时返回空串
+func latestDataFile(dataDir, name string) string {
+	files := snapshotFiles(dataDir, name)
+	if len(files) == 0 {
+		return ""
+	}
+	return files[len(files)-1]
+}
+
+// snapshotTime 从文件名解析拉取时间（本地时区）
+func snapshotTime(path, name string) (time.Time, bool) {
+	stamp := strings.TrimPrefix(filepath.Base(path), name+"_")
+	stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ".xml")
+	t, err := time.ParseInLocation(snapshotTimeFormat, stamp, time.Local)
+	return t, err == nil
+}
+
+// compressSnapshot 将 .xml 压缩为 .xml.gz 并删除原文件
+func compressSnapshot(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = filepath.Base(path)
+	if _, err := zw.Write(data); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	gzPath := path + ".gz"
+	if err := os.WriteFile(gzPath, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return gzPath, os.Remove(path)
+}